@@ -15,12 +15,14 @@ import (
 	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/gorilla/websocket"
 	"github.com/ringecosystem/degov-apps/graph"
 	"github.com/ringecosystem/degov-apps/internal"
 	"github.com/ringecosystem/degov-apps/internal/config"
 	"github.com/ringecosystem/degov-apps/internal/directives"
 	"github.com/ringecosystem/degov-apps/internal/middleware"
 	"github.com/ringecosystem/degov-apps/routes"
+	"github.com/ringecosystem/degov-apps/services"
 	"github.com/ringecosystem/degov-apps/tasks"
 	"github.com/rs/cors"
 	"github.com/vektah/gqlparser/v2/ast"
@@ -49,7 +51,7 @@ func main() {
 	go handleGracefulShutdown(cancel)
 
 	// Start the web server
-	startServer()
+	startServer(ctx)
 }
 
 // startBackgroundTasks starts all background tasks
@@ -106,10 +108,21 @@ func handleGracefulShutdown(cancel context.CancelFunc) {
 }
 
 // startServer starts the GraphQL server
-func startServer() {
+func startServer(ctx context.Context) {
 	cfg := config.GetConfig()
 	port := cfg.GetPort()
 
+	corsHandler := cors.New(cors.Options{
+		AllowedOrigins:   config.GetCorsAllowedOrigins(),
+		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowCredentials: true,
+		Debug:            config.GetAppEnv().IsDevelopment(),
+	})
+
+	// subscriptions receive the live events published by the tasks of any replica
+	services.NewLiveEventService().Listen(ctx)
+
 	// Configure directives
 	graphqlConfig := graph.Config{
 		Resolvers: graph.NewResolver(),
@@ -124,6 +137,15 @@ func startServer() {
 	gqlSrv.AddTransport(transport.Options{})
 	gqlSrv.AddTransport(transport.GET{})
 	gqlSrv.AddTransport(transport.POST{})
+	// graphql-ws transport for subscriptions, browsers may only connect from the allowed cors origins
+	gqlSrv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		Upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				return r.Header.Get("Origin") == "" || corsHandler.OriginAllowed(r)
+			},
+		},
+	})
 
 	gqlSrv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
 
@@ -157,13 +179,10 @@ func startServer() {
 	mux.Handle("/dao/config", middlewareChain.Then(http.HandlerFunc(daoRoute.ConfigHandler)))
 	mux.Handle("/dao/config/{dao}", middlewareChain.Then(http.HandlerFunc(daoRoute.ConfigHandler)))
 
-	corsHandler := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type"},
-		AllowCredentials: true,
-		Debug:            config.GetAppEnv().IsDevelopment(),
-	})
+	// One-click unsubscribe links of notification emails
+	unsubscribeRoute := routes.NewUnsubscribeRoute(config.GetDegovSiteConfig().Apps)
+	mux.Handle("/unsubscribe", middlewareChain.Then(http.HandlerFunc(unsubscribeRoute.Handler)))
+
 	httpHandler := corsHandler.Handler(mux)
 
 	slog.Info(
@@ -38,6 +38,9 @@ type NotificationRecord struct {
 	Payload         *string                 `gorm:"column:payload;type:text" json:"payload,omitempty"`
 	TimesRetry      int                     `gorm:"column:times_retry;not null;default:0" json:"times_retry"`
 	TimeNextExecute time.Time               `gorm:"column:time_next_execute;" json:"time_next_execute"`
+	InboxTitle      *string                 `gorm:"column:inbox_title;type:varchar(255)" json:"inbox_title,omitempty"`
+	InboxBody       *string                 `gorm:"column:inbox_body;type:text" json:"inbox_body,omitempty"`
+	TimeRead        *time.Time              `gorm:"column:time_read" json:"time_read,omitempty"`
 	CTime           time.Time               `gorm:"column:ctime;default:now()" json:"ctime"`
 	UTime           time.Time               `gorm:"column:utime;default:now()" json:"utime"`
 }
@@ -71,6 +74,14 @@ func (NotificationEvent) TableName() string {
 const (
 	NotificationChannelTypeEmail   NotificationChannelType = "EMAIL"
 	NotificationChannelTypeWebhook NotificationChannelType = "WEBHOOK"
+	// NotificationChannelTypeTelegram channel value is the telegram chat id once linked
+	NotificationChannelTypeTelegram NotificationChannelType = "TELEGRAM"
+	// NotificationChannelTypeDiscord and NotificationChannelTypeSlack channel value is the incoming webhook url
+	NotificationChannelTypeDiscord NotificationChannelType = "DISCORD"
+	NotificationChannelTypeSlack   NotificationChannelType = "SLACK"
+	// NotificationChannelTypeInbox is a pseudo channel every user has, it renders the records into the in-app inbox.
+	// it is not stored in dgv_notification_channel
+	NotificationChannelTypeInbox NotificationChannelType = "INBOX"
 )
 
 type NotificationChannel struct {
@@ -87,3 +98,32 @@ type NotificationChannel struct {
 func (NotificationChannel) TableName() string {
 	return "dgv_notification_channel"
 }
+
+type NotificationDeliveryState string
+
+const (
+	NotificationDeliveryStatePending NotificationDeliveryState = "PENDING"
+	NotificationDeliveryStateSentOk  NotificationDeliveryState = "SENT_OK"
+	// NotificationDeliveryStateSentFail the last attempt failed, it is retried while the record is pending
+	NotificationDeliveryStateSentFail NotificationDeliveryState = "SENT_FAIL"
+)
+
+type NotificationDelivery struct {
+	ID                string                    `gorm:"column:id;type:varchar(50);primaryKey" json:"id"`
+	RecordID          string                    `gorm:"column:record_id;type:varchar(50);not null;uniqueIndex:uq_notification_delivery_record_channel" json:"record_id"`
+	ChannelID         string                    `gorm:"column:channel_id;type:varchar(50);not null;uniqueIndex:uq_notification_delivery_record_channel" json:"channel_id"`
+	ChannelType       NotificationChannelType   `gorm:"column:channel_type;type:varchar(50);not null" json:"channel_type"`
+	ChannelValue      string                    `gorm:"column:channel_value;type:varchar(500);not null" json:"channel_value"`
+	UserID            string                    `gorm:"column:user_id;type:varchar(50);not null" json:"user_id"`
+	State             NotificationDeliveryState `gorm:"column:state;type:varchar(50);not null" json:"state"`
+	TimesAttempt      int                       `gorm:"column:times_attempt;not null;default:0" json:"times_attempt"`
+	LastError         *string                   `gorm:"column:last_error;type:text" json:"last_error,omitempty"`
+	ProviderMessageID *string                   `gorm:"column:provider_message_id;type:varchar(255)" json:"provider_message_id,omitempty"`
+	TimeLastAttempt   *time.Time                `gorm:"column:time_last_attempt" json:"time_last_attempt,omitempty"`
+	CTime             time.Time                 `gorm:"column:ctime;default:now()" json:"ctime"`
+	UTime             *time.Time                `gorm:"column:utime" json:"utime,omitempty"`
+}
+
+func (NotificationDelivery) TableName() string {
+	return "dgv_notification_delivery"
+}
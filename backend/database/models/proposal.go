@@ -30,6 +30,9 @@ type ProposalTracking struct {
 	TimeNextTrack      *time.Time    `gorm:"column:time_next_track" json:"time_next_track,omitempty"`         // Next tracking time
 	Message            string        `gorm:"column:message;type:text" json:"message,omitempty"`               // Additional message or notes
 	OffsetTrackingVote int           `gorm:"column:offset_tracking_vote;default:0" json:"offset_tracking_vote"`
+	Eta                *time.Time    `gorm:"column:eta" json:"eta,omitempty"`                   // Time the queued proposal becomes executable
+	ExpiresAt          *time.Time    `gorm:"column:expires_at" json:"expires_at,omitempty"`     // Time the queued proposal expires, nil without grace period
+	ActionsJSON        *string       `gorm:"column:actions;type:text" json:"actions,omitempty"` // Decoded actions as json, nil until they are decoded
 	CTime              time.Time     `gorm:"column:ctime;default:now()" json:"ctime"`
 	UTime              *time.Time    `gorm:"column:utime" json:"utime,omitempty"`
 }
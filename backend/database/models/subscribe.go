@@ -9,6 +9,8 @@ const (
 	SubscribeFeatureProposalStateChanged SubscribeFeatureName = "PROPOSAL_STATE_CHANGED"
 	SubscribeFeatureVoteEnd              SubscribeFeatureName = "VOTE_END"
 	SubscribeFeatureVoteEmitted          SubscribeFeatureName = "VOTE_EMITTED"
+	SubscribeFeatureProposalExecutable   SubscribeFeatureName = "PROPOSAL_EXECUTABLE"
+	SubscribeFeatureProposalExpiring     SubscribeFeatureName = "PROPOSAL_EXPIRING"
 )
 
 type SubscribeState string
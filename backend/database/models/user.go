@@ -14,3 +14,14 @@ type User struct {
 func (User) TableName() string {
 	return "dgv_user"
 }
+
+type UserAddress struct {
+	ID      string    `gorm:"column:id;type:varchar(50);primaryKey" json:"id"`
+	UserID  string    `gorm:"column:user_id;type:varchar(50);not null" json:"user_id"`
+	Address string    `gorm:"column:address;type:varchar(255);not null;uniqueIndex:uq_user_address_address" json:"address"`
+	CTime   time.Time `gorm:"column:ctime;default:now()" json:"ctime"`
+}
+
+func (UserAddress) TableName() string {
+	return "dgv_user_address"
+}
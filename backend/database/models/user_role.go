@@ -0,0 +1,24 @@
+package dbmodels
+
+import "time"
+
+type UserRoleType string
+
+const (
+	UserRoleTypeAdmin    UserRoleType = "ADMIN"
+	UserRoleTypeDaoAdmin UserRoleType = "DAO_ADMIN"
+)
+
+type UserRole struct {
+	ID        string       `gorm:"column:id;type:varchar(50);primaryKey" json:"id"`
+	Address   string       `gorm:"column:address;type:varchar(255);not null" json:"address"`
+	Role      UserRoleType `gorm:"column:role;type:varchar(50);not null" json:"role"`
+	DaoCode   *string      `gorm:"column:dao_code;type:varchar(255)" json:"dao_code,omitempty"`
+	GrantedBy *string      `gorm:"column:granted_by;type:varchar(255)" json:"granted_by,omitempty"`
+	CTime     time.Time    `gorm:"column:ctime;default:now()" json:"ctime"`
+	UTime     *time.Time   `gorm:"column:utime" json:"utime,omitempty"`
+}
+
+func (UserRole) TableName() string {
+	return "dgv_user_role"
+}
@@ -0,0 +1,28 @@
+package dbmodels
+
+import "time"
+
+type UserSessionState string
+
+const (
+	UserSessionStateActive  UserSessionState = "ACTIVE"
+	UserSessionStateRevoked UserSessionState = "REVOKED"
+)
+
+type UserSession struct {
+	ID               string           `gorm:"column:id;type:varchar(50);primaryKey" json:"id"`
+	UserID           string           `gorm:"column:user_id;type:varchar(50);not null" json:"user_id"`
+	UserAddress      string           `gorm:"column:user_address;type:varchar(255);not null" json:"user_address"`
+	DaoCode          *string          `gorm:"column:dao_code;type:varchar(255)" json:"dao_code,omitempty"`
+	Site             *string          `gorm:"column:site;type:varchar(255)" json:"site,omitempty"`
+	RefreshTokenHash string           `gorm:"column:refresh_token_hash;type:varchar(64);not null" json:"-"`
+	State            UserSessionState `gorm:"column:state;type:varchar(50);not null" json:"state"`
+	TimeExpire       time.Time        `gorm:"column:time_expire;not null" json:"time_expire"`
+	TimeRevoked      *time.Time       `gorm:"column:time_revoked" json:"time_revoked,omitempty"`
+	CTime            time.Time        `gorm:"column:ctime;default:now()" json:"ctime"`
+	UTime            *time.Time       `gorm:"column:utime" json:"utime,omitempty"`
+}
+
+func (UserSession) TableName() string {
+	return "dgv_user_session"
+}
@@ -22,6 +22,14 @@ type Resolver struct {
 	userInteractionService *services.UserInteractionService
 	evmChainService        *services.EvmChainService
 	subscribeService       *services.SubscribeService
+	notificationService    *services.NotificationService
+	userRoleService        *services.UserRoleService
+	liveEventService       *services.LiveEventService
+	proposalService        *services.ProposalService
+	feedService            *services.FeedService
+	inboxService           *services.InboxService
+	trackingSourceService  *services.TrackingSourceService
+	proposalActionService  *services.ProposalActionService
 }
 
 func NewResolver() *Resolver {
@@ -36,5 +44,13 @@ func NewResolver() *Resolver {
 		userInteractionService: services.NewUserInteractionService(),
 		evmChainService:        services.NewEvmChainService(),
 		subscribeService:       services.NewSubscribeService(),
+		notificationService:    services.NewNotificationService(),
+		userRoleService:        services.NewUserRoleService(),
+		liveEventService:       services.NewLiveEventService(),
+		proposalService:        services.NewProposalService(),
+		feedService:            services.NewFeedService(),
+		inboxService:           services.NewInboxService(),
+		trackingSourceService:  services.NewTrackingSourceService(),
+		proposalActionService:  services.NewProposalActionService(),
 	}
 }
@@ -8,15 +8,22 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/jinzhu/copier"
 	dbmodels "github.com/ringecosystem/degov-apps/database/models"
 	gqlmodels "github.com/ringecosystem/degov-apps/graph/models"
+	"github.com/ringecosystem/degov-apps/internal/middleware"
+	"github.com/ringecosystem/degov-apps/internal/utils"
 	"github.com/ringecosystem/degov-apps/types"
 )
 
 // Login is the resolver for the login field.
 func (r *mutationResolver) Login(ctx context.Context, input gqlmodels.LoginInput) (*gqlmodels.LoginOutput, error) {
-	output, err := r.authService.Login(input)
+	daoCode, site := middleware.GetDegovOrigin(ctx)
+	output, err := r.authService.Login(input, types.LoginOrigin{
+		DaoCode: utils.StringPtr(daoCode),
+		Site:    utils.StringPtr(site),
+	})
 	if err != nil {
 		return nil, fmt.Errorf("login failed: %v", err)
 	}
@@ -24,6 +31,59 @@ func (r *mutationResolver) Login(ctx context.Context, input gqlmodels.LoginInput
 	return &output, nil
 }
 
+// RefreshToken is the resolver for the refreshToken field.
+func (r *mutationResolver) RefreshToken(ctx context.Context, input gqlmodels.RefreshTokenInput) (*gqlmodels.LoginOutput, error) {
+	output, err := r.authService.RefreshToken(input)
+	if err != nil {
+		return nil, fmt.Errorf("refresh token failed: %v", err)
+	}
+
+	return &output, nil
+}
+
+// Logout is the resolver for the logout field.
+func (r *mutationResolver) Logout(ctx context.Context) (bool, error) {
+	claims, err := r.authUtils.GetAuthClaims(ctx)
+	if err != nil {
+		return false, err
+	}
+	return r.authService.Logout(types.BasicInput[string]{
+		User:  claims.User,
+		Input: claims.SessionID,
+	})
+}
+
+// LogoutAllSessions is the resolver for the logoutAllSessions field.
+func (r *mutationResolver) LogoutAllSessions(ctx context.Context) (bool, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	return r.authService.LogoutAllSessions(types.BasicInput[*string]{
+		User:  user,
+		Input: nil,
+	})
+}
+
+// LinkAddress is the resolver for the linkAddress field.
+func (r *mutationResolver) LinkAddress(ctx context.Context, input gqlmodels.LinkAddressInput) ([]*gqlmodels.LinkedAddress, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	daoCode, site := middleware.GetDegovOrigin(ctx)
+	return r.authService.LinkAddress(types.BasicInput[gqlmodels.LinkAddressInput]{
+		User:  user,
+		Input: input,
+	}, types.LoginOrigin{
+		DaoCode: utils.StringPtr(daoCode),
+		Site:    utils.StringPtr(site),
+	})
+}
+
+// UnlinkAddress is the resolver for the unlinkAddress field.
+func (r *mutationResolver) UnlinkAddress(ctx context.Context, input gqlmodels.UnlinkAddressInput) ([]*gqlmodels.LinkedAddress, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	return r.authService.UnlinkAddress(types.BasicInput[gqlmodels.UnlinkAddressInput]{
+		User:  user,
+		Input: input,
+	})
+}
+
 // ModifyLikeDao is the resolver for the modifyLikeDao field.
 func (r *mutationResolver) ModifyLikeDao(ctx context.Context, input gqlmodels.ModifyLikeDaoInput) (bool, error) {
 	user, _ := r.authUtils.GetUser(ctx)
@@ -52,6 +112,24 @@ func (r *mutationResolver) ResendOtp(ctx context.Context, input gqlmodels.BaseNo
 	})
 }
 
+// DeleteNotificationChannel is the resolver for the deleteNotificationChannel field.
+func (r *mutationResolver) DeleteNotificationChannel(ctx context.Context, input gqlmodels.DeleteNotificationChannelInput) (bool, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	return r.userInteractionService.DeleteChannel(types.BasicInput[gqlmodels.DeleteNotificationChannelInput]{
+		User:  user,
+		Input: input,
+	})
+}
+
+// MarkNotificationsRead is the resolver for the markNotificationsRead field.
+func (r *mutationResolver) MarkNotificationsRead(ctx context.Context, input gqlmodels.MarkNotificationsReadInput) (*gqlmodels.MarkNotificationsReadOutput, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	return r.inboxService.MarkRead(types.BasicInput[gqlmodels.MarkNotificationsReadInput]{
+		User:  user,
+		Input: input,
+	})
+}
+
 // SubscribeDao is the resolver for the subscribeDao field.
 func (r *mutationResolver) SubscribeDao(ctx context.Context, input gqlmodels.SubscribeDaoInput) (*gqlmodels.SubscribedDaoOutput, error) {
 	user, _ := r.authUtils.GetUser(ctx)
@@ -90,6 +168,73 @@ func (r *mutationResolver) UnsubscribeProposal(ctx context.Context, input gqlmod
 	})
 }
 
+// RequeueNotificationEvents is the resolver for the requeueNotificationEvents field.
+func (r *mutationResolver) RequeueNotificationEvents(ctx context.Context, input gqlmodels.RequeueNotificationInput) (*gqlmodels.RequeueNotificationOutput, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	count, err := r.notificationService.RequeueFailedEvents(types.BasicInput[gqlmodels.RequeueNotificationInput]{
+		User:  user,
+		Input: input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &gqlmodels.RequeueNotificationOutput{Count: int32(count)}, nil
+}
+
+// RequeueNotificationRecords is the resolver for the requeueNotificationRecords field.
+func (r *mutationResolver) RequeueNotificationRecords(ctx context.Context, input gqlmodels.RequeueNotificationInput) (*gqlmodels.RequeueNotificationOutput, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	count, err := r.notificationService.RequeueFailedRecords(types.BasicInput[gqlmodels.RequeueNotificationInput]{
+		User:  user,
+		Input: input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &gqlmodels.RequeueNotificationOutput{Count: int32(count)}, nil
+}
+
+// GrantUserRole is the resolver for the grantUserRole field.
+func (r *mutationResolver) GrantUserRole(ctx context.Context, input gqlmodels.GrantUserRoleInput) (*gqlmodels.UserRole, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	role, err := r.userRoleService.GrantRole(types.BasicInput[gqlmodels.GrantUserRoleInput]{
+		User:  user,
+		Input: input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result gqlmodels.UserRole
+	copier.Copy(&result, role)
+	return &result, nil
+}
+
+// RevokeUserRole is the resolver for the revokeUserRole field.
+func (r *mutationResolver) RevokeUserRole(ctx context.Context, input gqlmodels.RevokeUserRoleInput) (bool, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	return r.userRoleService.RevokeRole(types.BasicInput[gqlmodels.RevokeUserRoleInput]{
+		User:  user,
+		Input: input,
+	})
+}
+
+// Actions is the resolver for the actions field.
+func (r *proposalResolver) Actions(ctx context.Context, obj *gqlmodels.Proposal) ([]*gqlmodels.ProposalAction, error) {
+	// decoding reads the log, abis and token metadata of the proposal, a list of proposals would fan out
+	// into that work per item. Only a single proposal is decoded, lists return the stored or cached actions
+	for fieldCtx := graphql.GetFieldContext(ctx); fieldCtx != nil; fieldCtx = fieldCtx.Parent {
+		if fieldCtx.Index == nil {
+			continue
+		}
+		if obj.Actions != nil {
+			return obj.Actions, nil
+		}
+		actions, _ := r.proposalActionService.CachedActions(obj.DaoCode, obj.ProposalID)
+		return actions, nil
+	}
+	return r.proposalActionService.Actions(ctx, obj.DaoCode, obj.ProposalID)
+}
+
 // Nonce is the resolver for the nonce field.
 func (r *queryResolver) Nonce(ctx context.Context, input gqlmodels.GetNonceInput) (string, error) {
 	nonce, err := r.authService.Nonce(input)
@@ -133,12 +278,39 @@ func (r *queryResolver) DaoConfig(ctx context.Context, input *gqlmodels.GetDaoCo
 	})
 }
 
+// Proposals is the resolver for the proposals field.
+func (r *queryResolver) Proposals(ctx context.Context, filter *gqlmodels.ProposalFilter, first *int32, after *string) (*gqlmodels.ProposalConnection, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	return r.proposalService.ListProposals(types.BasicInput[types.ListProposalsInput]{
+		User: user,
+		Input: types.ListProposalsInput{
+			Filter: filter,
+			First:  first,
+			After:  after,
+		},
+	})
+}
+
+// Proposal is the resolver for the proposal field.
+func (r *queryResolver) Proposal(ctx context.Context, daoCode string, proposalID string) (*gqlmodels.Proposal, error) {
+	return r.proposalService.GetProposal(daoCode, proposalID)
+}
+
 // EvmAbi is the resolver for the evmAbi field.
 func (r *queryResolver) EvmAbi(ctx context.Context, input gqlmodels.EvmAbiInput) ([]*gqlmodels.EvmAbiOutput, error) {
 	// panic(fmt.Errorf("not implemented: EvmAbi - evmAbi"))
 	return r.evmChainService.GetAbi(input)
 }
 
+// LinkedAddresses is the resolver for the linkedAddresses field.
+func (r *queryResolver) LinkedAddresses(ctx context.Context) ([]*gqlmodels.LinkedAddress, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	return r.authService.LinkedAddresses(types.BasicInput[*string]{
+		User:  user,
+		Input: nil,
+	})
+}
+
 // ListNotificationChannels is the resolver for the listNotificationChannels field.
 func (r *queryResolver) ListNotificationChannels(ctx context.Context) ([]*gqlmodels.NotificationChannel, error) {
 	user, _ := r.authUtils.GetUser(ctx)
@@ -154,6 +326,36 @@ func (r *queryResolver) ListNotificationChannels(ctx context.Context) ([]*gqlmod
 	return result, nil
 }
 
+// Notifications is the resolver for the notifications field.
+func (r *queryResolver) Notifications(ctx context.Context, first *int32, after *string, unreadOnly *bool) (*gqlmodels.InboxNotificationConnection, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	return r.inboxService.ListNotifications(types.BasicInput[types.ListInboxInput]{
+		User: user,
+		Input: types.ListInboxInput{
+			First:      first,
+			After:      after,
+			UnreadOnly: unreadOnly != nil && *unreadOnly,
+		},
+	})
+}
+
+// NotificationHistory is the resolver for the notificationHistory field.
+func (r *queryResolver) NotificationHistory(ctx context.Context, first *int32, after *string, state *gqlmodels.NotificationDeliveryState) (*gqlmodels.NotificationHistoryConnection, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	input := types.ListNotificationHistoryInput{
+		First: first,
+		After: after,
+	}
+	if state != nil {
+		recordState := dbmodels.NotificationRecordState(*state)
+		input.State = &recordState
+	}
+	return r.notificationService.NotificationHistory(types.BasicInput[types.ListNotificationHistoryInput]{
+		User:  user,
+		Input: input,
+	})
+}
+
 // SubscribedDaos is the resolver for the subscribedDaos field.
 func (r *queryResolver) SubscribedDaos(ctx context.Context) ([]*gqlmodels.SubscribedDao, error) {
 	user, _ := r.authUtils.GetUser(ctx)
@@ -172,27 +374,101 @@ func (r *queryResolver) SubscribedProposals(ctx context.Context) ([]*gqlmodels.S
 	})
 }
 
-// Mutation returns MutationResolver implementation.
-func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+// MyFeed is the resolver for the myFeed field.
+func (r *queryResolver) MyFeed(ctx context.Context, first *int32, after *string) (*gqlmodels.FeedConnection, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	return r.feedService.MyFeed(types.BasicInput[types.ListFeedInput]{
+		User: user,
+		Input: types.ListFeedInput{
+			First: first,
+			After: after,
+		},
+	})
+}
 
-// Query returns QueryResolver implementation.
-func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+// FailedNotificationEvents is the resolver for the failedNotificationEvents field.
+func (r *queryResolver) FailedNotificationEvents(ctx context.Context, input *gqlmodels.FailedNotificationFilter) ([]*gqlmodels.FailedNotificationEvent, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	items, err := r.notificationService.ListFailedEvents(types.BasicInput[*gqlmodels.FailedNotificationFilter]{
+		User:  user,
+		Input: input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result []*gqlmodels.FailedNotificationEvent
+	copier.Copy(&result, &items)
+	return result, nil
+}
 
-type mutationResolver struct{ *Resolver }
-type queryResolver struct{ *Resolver }
+// FailedNotificationRecords is the resolver for the failedNotificationRecords field.
+func (r *queryResolver) FailedNotificationRecords(ctx context.Context, input *gqlmodels.FailedNotificationFilter) ([]*gqlmodels.FailedNotificationRecord, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	items, err := r.notificationService.ListFailedRecords(types.BasicInput[*gqlmodels.FailedNotificationFilter]{
+		User:  user,
+		Input: input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result []*gqlmodels.FailedNotificationRecord
+	copier.Copy(&result, &items)
+	return result, nil
+}
 
-// !!! WARNING !!!
-// The code below was going to be deleted when updating resolvers. It has been copied here so you have
-// one last chance to move it out of harms way if you want. There are two reasons this happens:
-//  - When renaming or deleting a resolver the old code will be put in here. You can safely delete
-//    it when you're done.
-//  - You have helper methods in this file. Move them out to keep these resolver files clean.
-/*
-	func (r *mutationResolver) BindNotificationChannel(ctx context.Context, input gqlmodels.BindNotificationChannelInput) (*gqlmodels.ResendOTPOutput, error) {
+// UserRoles is the resolver for the userRoles field.
+func (r *queryResolver) UserRoles(ctx context.Context, input *gqlmodels.ListUserRolesInput) ([]*gqlmodels.UserRole, error) {
 	user, _ := r.authUtils.GetUser(ctx)
-	return r.userInteractionService.BindNotificationChannel(types.BasicInput[gqlmodels.BindNotificationChannelInput]{
+	roles, err := r.userRoleService.ListRoles(types.BasicInput[*gqlmodels.ListUserRolesInput]{
 		User:  user,
 		Input: input,
 	})
+	if err != nil {
+		return nil, err
+	}
+	var result []*gqlmodels.UserRole
+	copier.Copy(&result, &roles)
+	return result, nil
+}
+
+// RPCHealth is the resolver for the rpcHealth field.
+func (r *queryResolver) RPCHealth(ctx context.Context) ([]*gqlmodels.RPCPoolHealth, error) {
+	return r.evmChainService.RPCHealth(), nil
+}
+
+// ReconcileTrackingSources is the resolver for the reconcileTrackingSources field.
+func (r *queryResolver) ReconcileTrackingSources(ctx context.Context, daoCode string) (*gqlmodels.TrackingSourceReconciliation, error) {
+	return r.trackingSourceService.Reconcile(daoCode)
+}
+
+// ProposalCreated is the resolver for the proposalCreated field.
+func (r *subscriptionResolver) ProposalCreated(ctx context.Context, daoCode string) (<-chan *gqlmodels.Proposal, error) {
+	return r.liveEventService.ProposalCreated(ctx, daoCode), nil
 }
-*/
+
+// ProposalStateChanged is the resolver for the proposalStateChanged field.
+func (r *subscriptionResolver) ProposalStateChanged(ctx context.Context, daoCode string, proposalID *string) (<-chan *gqlmodels.ProposalStateChangedEvent, error) {
+	return r.liveEventService.ProposalStateChanged(ctx, daoCode, proposalID), nil
+}
+
+// VoteCast is the resolver for the voteCast field.
+func (r *subscriptionResolver) VoteCast(ctx context.Context, daoCode string, proposalID *string) (<-chan *gqlmodels.VoteCastEvent, error) {
+	return r.liveEventService.VoteCast(ctx, daoCode, proposalID), nil
+}
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// Proposal returns ProposalResolver implementation.
+func (r *Resolver) Proposal() ProposalResolver { return &proposalResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+type mutationResolver struct{ *Resolver }
+type proposalResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
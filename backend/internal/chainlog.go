@@ -0,0 +1,508 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// chainLogPageSize matches the page size of the indexer, so offsets are interchangeable between the sources
+	chainLogPageSize = 30
+	// chainLogDefaultBlockRange is the initial number of blocks queried by one eth_getLogs
+	chainLogDefaultBlockRange = 2000
+	// chainLogMinBlockRange is the smallest range the scan shrinks to when the node rejects a range
+	chainLogMinBlockRange = 10
+	// chainLogDefaultMaxRanges bounds the number of eth_getLogs per sync, a long history is caught up over several syncs
+	chainLogDefaultMaxRanges = 200
+	// chainLogSyncInterval is how long a sync is reused by the queries before the chain is scanned again
+	chainLogSyncInterval = 30 * time.Second
+	// chainLogBlockIntervalSample is the number of blocks the block time is estimated over
+	chainLogBlockIntervalSample = 1000
+)
+
+const governorEventsABIJSON = `[
+	{
+		"anonymous": false,
+		"inputs": [
+			{"indexed": false, "internalType": "uint256", "name": "proposalId", "type": "uint256"},
+			{"indexed": false, "internalType": "address", "name": "proposer", "type": "address"},
+			{"indexed": false, "internalType": "address[]", "name": "targets", "type": "address[]"},
+			{"indexed": false, "internalType": "uint256[]", "name": "values", "type": "uint256[]"},
+			{"indexed": false, "internalType": "string[]", "name": "signatures", "type": "string[]"},
+			{"indexed": false, "internalType": "bytes[]", "name": "calldatas", "type": "bytes[]"},
+			{"indexed": false, "internalType": "uint256", "name": "voteStart", "type": "uint256"},
+			{"indexed": false, "internalType": "uint256", "name": "voteEnd", "type": "uint256"},
+			{"indexed": false, "internalType": "string", "name": "description", "type": "string"}
+		],
+		"name": "ProposalCreated",
+		"type": "event"
+	},
+	{
+		"anonymous": false,
+		"inputs": [
+			{"indexed": true, "internalType": "address", "name": "voter", "type": "address"},
+			{"indexed": false, "internalType": "uint256", "name": "proposalId", "type": "uint256"},
+			{"indexed": false, "internalType": "uint8", "name": "support", "type": "uint8"},
+			{"indexed": false, "internalType": "uint256", "name": "weight", "type": "uint256"},
+			{"indexed": false, "internalType": "string", "name": "reason", "type": "string"}
+		],
+		"name": "VoteCast",
+		"type": "event"
+	},
+	{
+		"anonymous": false,
+		"inputs": [
+			{"indexed": true, "internalType": "address", "name": "voter", "type": "address"},
+			{"indexed": false, "internalType": "uint256", "name": "proposalId", "type": "uint256"},
+			{"indexed": false, "internalType": "uint8", "name": "support", "type": "uint8"},
+			{"indexed": false, "internalType": "uint256", "name": "weight", "type": "uint256"},
+			{"indexed": false, "internalType": "string", "name": "reason", "type": "string"},
+			{"indexed": false, "internalType": "bytes", "name": "params", "type": "bytes"}
+		],
+		"name": "VoteCastWithParams",
+		"type": "event"
+	},
+	{
+		"inputs": [],
+		"name": "CLOCK_MODE",
+		"outputs": [{"internalType": "string", "name": "", "type": "string"}],
+		"stateMutability": "view",
+		"type": "function"
+	}
+]`
+
+var governorEventsABI = mustParseABI(governorEventsABIJSON)
+
+// ErrChainLogBehind is returned instead of an empty page while the chain log scan has not reached the confirmed head,
+// so that callers do not take it for the end of the list
+var ErrChainLogBehind = errors.New("chain log scan has not caught up with the chain")
+
+// GovernanceSource discovers the proposals and votes of a dao. Both queries page by offset in block order,
+// so the tracking offsets stay valid when a dao switches between sources
+type GovernanceSource interface {
+	QueryProposalsOffset(ctx context.Context, offset int) ([]Proposal, error)
+	QueryVotesOffset(ctx context.Context, offset int, proposalId string) ([]VoteCast, error)
+}
+
+// ChainLogBackend is the part of the rpc api the chain log source needs, implemented by RPCPool
+type ChainLogBackend interface {
+	ContractBackend
+	BlockNumber(ctx context.Context) (uint64, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// ChainLogSourceConfig describes the governor a chain log source scans
+type ChainLogSourceConfig struct {
+	Governor   common.Address
+	StartBlock uint64
+	// BlockRange is the initial number of blocks per eth_getLogs, the scan halves it when the node rejects a range
+	BlockRange uint64
+	// MaxRanges bounds the number of eth_getLogs per sync
+	MaxRanges int
+	// Confirmations is the number of blocks the scan stays behind the chain head, so that logs of blocks
+	// which are reorged out are never read
+	Confirmations uint64
+}
+
+// ChainLogSource reads ProposalCreated, VoteCast and VoteCastWithParams logs of the governor with eth_getLogs,
+// so proposals and votes are still discovered when the indexer is stale or offline. The logs are scanned
+// incrementally from the start block and kept in memory, a restart scans the history again. Until the scan
+// caught up, a query past the scanned logs fails with ErrChainLogBehind
+type ChainLogSource struct {
+	backend ChainLogBackend
+	config  ChainLogSourceConfig
+
+	// syncMu serializes the syncs, the clock and the block range are only used under it
+	syncMu        sync.Mutex
+	clockMode     string
+	blockInterval time.Duration
+	blockRange    uint64
+
+	// mu guards the scanned logs and the progress
+	mu        sync.Mutex
+	nextBlock uint64
+	headBlock uint64
+	safeBlock uint64
+	lastSync  time.Time
+	proposals []Proposal
+	votes     map[string][]VoteCast
+}
+
+var (
+	chainLogSources   = make(map[string]*ChainLogSource)
+	chainLogSourcesMu sync.Mutex
+)
+
+// GetChainLogSource returns the chain log source of the dao, the scanned logs are shared by all callers.
+// The source is recreated when the governor or the start block of the dao changes
+func GetChainLogSource(daoCode string, backend ChainLogBackend, config ChainLogSourceConfig) *ChainLogSource {
+	chainLogSourcesMu.Lock()
+	defer chainLogSourcesMu.Unlock()
+
+	source, ok := chainLogSources[daoCode]
+	if !ok || source.config.Governor != config.Governor || source.config.StartBlock != config.StartBlock {
+		source = NewChainLogSource(backend, config)
+		chainLogSources[daoCode] = source
+	}
+	return source
+}
+
+func NewChainLogSource(backend ChainLogBackend, config ChainLogSourceConfig) *ChainLogSource {
+	if config.BlockRange == 0 {
+		config.BlockRange = chainLogDefaultBlockRange
+	}
+	if config.MaxRanges <= 0 {
+		config.MaxRanges = chainLogDefaultMaxRanges
+	}
+	return &ChainLogSource{
+		backend:    backend,
+		config:     config,
+		blockRange: config.BlockRange,
+		nextBlock:  config.StartBlock,
+		votes:      make(map[string][]VoteCast),
+	}
+}
+
+// QueryProposalsOffset returns the proposals found so far in block order, after syncing when the last sync is stale
+func (s *ChainLogSource) QueryProposalsOffset(ctx context.Context, offset int) ([]Proposal, error) {
+	if err := s.syncIfStale(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	page := pageOf(s.proposals, offset)
+	if len(page) == 0 && !s.complete() {
+		return nil, s.behindError()
+	}
+	return page, nil
+}
+
+// QueryVotesOffset returns the votes of the proposal found so far in block order, after syncing when the last sync is stale
+func (s *ChainLogSource) QueryVotesOffset(ctx context.Context, offset int, proposalId string) ([]VoteCast, error) {
+	if err := s.syncIfStale(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	page := pageOf(s.votes[NormalizeProposalID(proposalId)], offset)
+	if len(page) == 0 && !s.complete() {
+		return nil, s.behindError()
+	}
+	return page, nil
+}
+
+// Progress returns the last scanned block and the chain head seen by the last sync
+func (s *ChainLogSource) Progress() (scannedBlock uint64, headBlock uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nextBlock == 0 {
+		return 0, s.headBlock
+	}
+	return s.nextBlock - 1, s.headBlock
+}
+
+// Complete reports whether the last sync reached the confirmed head of the chain
+func (s *ChainLogSource) Complete() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.complete()
+}
+
+func (s *ChainLogSource) complete() bool {
+	return !s.lastSync.IsZero() && s.nextBlock > s.safeBlock
+}
+
+// behindError reports an empty page while the scan has not caught up, the logs of the page may not be scanned yet
+func (s *ChainLogSource) behindError() error {
+	return fmt.Errorf("%w: scanned up to block %d, the confirmed head is %d", ErrChainLogBehind, max(s.nextBlock, 1)-1, s.safeBlock)
+}
+
+// Proposals returns the proposals found so far in block order
+func (s *ChainLogSource) Proposals() []Proposal {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Proposal(nil), s.proposals...)
+}
+
+// VotesCount returns the number of votes of the proposal found so far
+func (s *ChainLogSource) VotesCount(proposalId string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.votes[NormalizeProposalID(proposalId)])
+}
+
+// syncIfStale syncs when the last sync is stale. A sync in progress is not waited for, the queries
+// are answered from the logs scanned so far
+func (s *ChainLogSource) syncIfStale(ctx context.Context) error {
+	if !s.syncMu.TryLock() {
+		return nil
+	}
+	defer s.syncMu.Unlock()
+
+	s.mu.Lock()
+	stale := time.Since(s.lastSync) >= chainLogSyncInterval
+	s.mu.Unlock()
+	if !stale {
+		return nil
+	}
+	return s.sync(ctx)
+}
+
+// Sync scans the blocks after the last scanned one up to the confirmed head, at most MaxRanges eth_getLogs per call
+func (s *ChainLogSource) Sync(ctx context.Context) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	return s.sync(ctx)
+}
+
+// sync runs with syncMu held. The rpc calls run without mu, each scanned range is applied under it,
+// so the queries and the progress are not blocked by a long scan
+func (s *ChainLogSource) sync(ctx context.Context) error {
+	head, err := s.backend.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get chain head: %w", err)
+	}
+	if err := s.loadClock(ctx, head); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.headBlock = head
+	if head < s.config.Confirmations {
+		s.lastSync = time.Now()
+		s.mu.Unlock()
+		return nil
+	}
+	s.safeBlock = head - s.config.Confirmations
+	safeBlock, nextBlock := s.safeBlock, s.nextBlock
+	s.mu.Unlock()
+
+	blockTimes := make(map[uint64]uint64)
+	for ranges := 0; nextBlock <= safeBlock && ranges < s.config.MaxRanges; ranges++ {
+		to := min(nextBlock+s.blockRange-1, safeBlock)
+		batch, err := s.scanRange(ctx, nextBlock, to, blockTimes)
+		if err != nil {
+			if ctx.Err() != nil && ranges > 0 {
+				// keep the progress of this sync, the next one continues from here
+				break
+			}
+			// nodes limit the range or the result size of eth_getLogs, retry the range in smaller pieces
+			if s.blockRange > chainLogMinBlockRange && ctx.Err() == nil {
+				s.blockRange = max(s.blockRange/2, chainLogMinBlockRange)
+				slog.Warn("Failed to scan governor logs, shrinking block range", "governor", s.config.Governor.Hex(), "from", nextBlock, "to", to, "block_range", s.blockRange, "error", err)
+				continue
+			}
+			return fmt.Errorf("failed to scan governor logs from %d to %d: %w", nextBlock, to, err)
+		}
+
+		s.mu.Lock()
+		s.proposals = append(s.proposals, batch.proposals...)
+		for _, vote := range batch.votes {
+			key := NormalizeProposalID(vote.ProposalID)
+			s.votes[key] = append(s.votes[key], vote)
+		}
+		s.nextBlock = to + 1
+		s.mu.Unlock()
+		nextBlock = to + 1
+	}
+
+	s.mu.Lock()
+	s.lastSync = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// chainLogBatch holds the decoded logs of one range, it is only applied when the whole range succeeded
+type chainLogBatch struct {
+	proposals []Proposal
+	votes     []VoteCast
+}
+
+func (s *ChainLogSource) scanRange(ctx context.Context, from, to uint64, blockTimes map[uint64]uint64) (*chainLogBatch, error) {
+	logs, err := s.backend.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: []common.Address{s.config.Governor},
+		Topics: [][]common.Hash{{
+			governorEventsABI.Events["ProposalCreated"].ID,
+			governorEventsABI.Events["VoteCast"].ID,
+			governorEventsABI.Events["VoteCastWithParams"].ID,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &chainLogBatch{}
+	for _, log := range logs {
+		if log.Removed {
+			continue
+		}
+		if err := s.processLog(ctx, log, blockTimes, batch); err != nil {
+			return nil, err
+		}
+	}
+	return batch, nil
+}
+
+// loadClock reads the EIP-6372 clock mode of the governor and estimates the block time of the chain
+func (s *ChainLogSource) loadClock(ctx context.Context, headBlock uint64) error {
+	if s.clockMode == "" {
+		s.clockMode = "blocknumber"
+		callData, err := governorEventsABI.Pack("CLOCK_MODE")
+		if err != nil {
+			return fmt.Errorf("failed to pack CLOCK_MODE call: %w", err)
+		}
+		// governors without EIP-6372 revert, they count in blocks
+		if output, err := s.backend.CallContract(ctx, ethereum.CallMsg{To: &s.config.Governor, Data: callData}, nil); err == nil {
+			var mode string
+			if err := governorEventsABI.UnpackIntoInterface(&mode, "CLOCK_MODE", output); err == nil && strings.Contains(mode, "mode=timestamp") {
+				s.clockMode = "timestamp"
+			}
+		}
+	}
+
+	if s.clockMode == "blocknumber" && headBlock > chainLogBlockIntervalSample {
+		head, err := s.backend.HeaderByNumber(ctx, new(big.Int).SetUint64(headBlock))
+		if err != nil {
+			return fmt.Errorf("failed to get head block: %w", err)
+		}
+		sample, err := s.backend.HeaderByNumber(ctx, new(big.Int).SetUint64(headBlock-chainLogBlockIntervalSample))
+		if err != nil {
+			return fmt.Errorf("failed to get sample block: %w", err)
+		}
+		s.blockInterval = time.Duration(head.Time-sample.Time) * time.Second / chainLogBlockIntervalSample
+	}
+	return nil
+}
+
+func (s *ChainLogSource) processLog(ctx context.Context, log types.Log, blockTimes map[uint64]uint64, batch *chainLogBatch) error {
+	if len(log.Topics) == 0 {
+		return nil
+	}
+	event, err := governorEventsABI.EventByID(log.Topics[0])
+	if err != nil {
+		return nil
+	}
+
+	blockTime, ok := blockTimes[log.BlockNumber]
+	if !ok {
+		header, err := s.backend.HeaderByNumber(ctx, new(big.Int).SetUint64(log.BlockNumber))
+		if err != nil {
+			return fmt.Errorf("failed to get block %d: %w", log.BlockNumber, err)
+		}
+		blockTime = header.Time
+		blockTimes[log.BlockNumber] = blockTime
+	}
+
+	values := make(map[string]any)
+	if err := governorEventsABI.UnpackIntoMap(values, event.Name, log.Data); err != nil {
+		slog.Warn("Failed to decode governor log", "event", event.Name, "tx", log.TxHash.Hex(), "index", log.Index, "error", err)
+		return nil
+	}
+	proposalID, _ := values["proposalId"].(*big.Int)
+	if proposalID == nil {
+		return nil
+	}
+
+	id := fmt.Sprintf("%s-%d", log.TxHash.Hex(), log.Index)
+	blockNumber := strconv.FormatUint(log.BlockNumber, 10)
+	blockTimestamp := strconv.FormatUint(blockTime*1000, 10)
+
+	if event.Name == "ProposalCreated" {
+		proposer, _ := values["proposer"].(common.Address)
+		voteStart, _ := values["voteStart"].(*big.Int)
+		voteEnd, _ := values["voteEnd"].(*big.Int)
+		description, _ := values["description"].(string)
+		batch.proposals = append(batch.proposals, Proposal{
+			ID:                 id,
+			ProposalID:         hexutil.EncodeBig(proposalID),
+			Title:              proposalTitle(description),
+			VoteStart:          voteStart.String(),
+			VoteEnd:            voteEnd.String(),
+			VoteStartTimestamp: s.clockTimestamp(voteStart, log.BlockNumber, blockTime),
+			VoteEndTimestamp:   s.clockTimestamp(voteEnd, log.BlockNumber, blockTime),
+			ClockMode:          s.clockMode,
+			Proposer:           strings.ToLower(proposer.Hex()),
+			BlockNumber:        blockNumber,
+			BlockTimestamp:     blockTimestamp,
+			TransactionHash:    log.TxHash.Hex(),
+			Description:        description,
+		})
+		return nil
+	}
+
+	if len(log.Topics) < 2 {
+		return nil
+	}
+	support, _ := values["support"].(uint8)
+	weight, _ := values["weight"].(*big.Int)
+	reason, _ := values["reason"].(string)
+	batch.votes = append(batch.votes, VoteCast{
+		ID:              id,
+		ProposalID:      hexutil.EncodeBig(proposalID),
+		Voter:           strings.ToLower(common.BytesToAddress(log.Topics[1].Bytes()).Hex()),
+		Support:         int(support),
+		Weight:          weight.String(),
+		Reason:          reason,
+		TransactionHash: log.TxHash.Hex(),
+		BlockNumber:     blockNumber,
+		BlockTimestamp:  blockTimestamp,
+	})
+	return nil
+}
+
+// clockTimestamp converts a timepoint of the governor clock to a millisecond timestamp. Block numbers are
+// estimated from the block the proposal was created in and the average block time
+func (s *ChainLogSource) clockTimestamp(timepoint *big.Int, blockNumber uint64, blockTime uint64) string {
+	if s.clockMode == "timestamp" {
+		return new(big.Int).Mul(timepoint, big.NewInt(1000)).String()
+	}
+	blocks := new(big.Int).Sub(timepoint, new(big.Int).SetUint64(blockNumber)).Int64()
+	timestamp := time.Unix(int64(blockTime), 0).Add(time.Duration(blocks) * s.blockInterval)
+	return strconv.FormatInt(timestamp.UnixMilli(), 10)
+}
+
+// proposalTitle returns the first non empty line of the description without markdown heading marks
+func proposalTitle(description string) string {
+	for _, line := range strings.Split(description, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+		if line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// NormalizeProposalID returns the canonical form of a hex proposal id, so ids with leading zeros or upper case digits match
+func NormalizeProposalID(proposalID string) string {
+	if id, ok := new(big.Int).SetString(strings.TrimPrefix(strings.ToLower(proposalID), "0x"), 16); ok {
+		return hexutil.EncodeBig(id)
+	}
+	return strings.ToLower(proposalID)
+}
+
+func pageOf[T any](items []T, offset int) []T {
+	if offset < 0 || offset >= len(items) {
+		return nil
+	}
+	end := min(offset+chainLogPageSize, len(items))
+	page := make([]T, end-offset)
+	copy(page, items[offset:end])
+	return page
+}
@@ -0,0 +1,428 @@
+package internal
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/program"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+)
+
+// testLogEmitterCode emits the log described by the calldata, a word with the number of topics (1 or 2),
+// the topics and the log data. Any other call reverts, so the emitter has no CLOCK_MODE and counts in blocks
+func testLogEmitterCode() []byte {
+	return assemble(func(p *program.Program, labels map[string]uint64) {
+		p.Push(0).Op(vm.CALLDATALOAD)
+		p.Op(vm.DUP1).Push(1).Op(vm.EQ)
+		push2(p, labels["log1"]).Op(vm.JUMPI)
+		p.Push(2).Op(vm.EQ)
+		push2(p, labels["log2"]).Op(vm.JUMPI)
+		p.Push(0).Push(0).Op(vm.REVERT)
+
+		label(p, labels, "log1")
+		p.Op(vm.POP)
+		p.Push(0x20).Op(vm.CALLDATALOAD)
+		p.Push(0x40).Op(vm.CALLDATASIZE, vm.SUB)
+		p.Op(vm.DUP1).Push(0x40).Push(0).Op(vm.CALLDATACOPY)
+		p.Push(0).Op(vm.LOG1, vm.STOP)
+
+		label(p, labels, "log2")
+		p.Push(0x40).Op(vm.CALLDATALOAD)
+		p.Push(0x20).Op(vm.CALLDATALOAD)
+		p.Push(0x60).Op(vm.CALLDATASIZE, vm.SUB)
+		p.Op(vm.DUP1).Push(0x60).Push(0).Op(vm.CALLDATACOPY)
+		p.Push(0).Op(vm.LOG2, vm.STOP)
+	})
+}
+
+// emitterCall returns the calldata making the emitter log the event
+func emitterCall(t *testing.T, event string, topics []common.Hash, args ...any) []byte {
+	t.Helper()
+	data, err := governorEventsABI.Events[event].Inputs.NonIndexed().Pack(args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callData := common.BigToHash(big.NewInt(int64(len(topics) + 1))).Bytes()
+	callData = append(callData, governorEventsABI.Events[event].ID.Bytes()...)
+	for _, topic := range topics {
+		callData = append(callData, topic.Bytes()...)
+	}
+	return append(callData, data...)
+}
+
+func proposalCreatedCall(t *testing.T, proposalID int64, proposer common.Address, voteStart, voteEnd int64, description string) []byte {
+	return emitterCall(t, "ProposalCreated", nil,
+		big.NewInt(proposalID), proposer,
+		[]common.Address{common.HexToAddress("0x1234")}, []*big.Int{big.NewInt(0)}, []string{""}, [][]byte{{0x01, 0x02}},
+		big.NewInt(voteStart), big.NewInt(voteEnd), description)
+}
+
+func voteCastCall(t *testing.T, voter common.Address, proposalID int64, support uint8, weight int64, reason string) []byte {
+	return emitterCall(t, "VoteCast", []common.Hash{common.BytesToHash(voter.Bytes())},
+		big.NewInt(proposalID), support, big.NewInt(weight), reason)
+}
+
+func voteCastWithParamsCall(t *testing.T, voter common.Address, proposalID int64, support uint8, weight int64, reason string, params []byte) []byte {
+	return emitterCall(t, "VoteCastWithParams", []common.Hash{common.BytesToHash(voter.Bytes())},
+		big.NewInt(proposalID), support, big.NewInt(weight), reason, params)
+}
+
+// sendCalls sends the calls to the contract in one block and returns their receipts
+func sendCalls(t *testing.T, backend *simulated.Backend, key *ecdsa.PrivateKey, to common.Address, calls ...[]byte) []*types.Receipt {
+	t.Helper()
+	ctx := context.Background()
+	client := backend.Client()
+
+	nonce, err := client.PendingNonceAt(ctx, crypto.PubkeyToAddress(key.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashes := make([]common.Hash, 0, len(calls))
+	for i, callData := range calls {
+		tx, err := types.SignTx(types.NewTransaction(nonce+uint64(i), to, big.NewInt(0), 200_000, gasPrice, callData),
+			types.LatestSignerForChainID(chainID), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := client.SendTransaction(ctx, tx); err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, tx.Hash())
+	}
+	backend.Commit()
+
+	receipts := make([]*types.Receipt, 0, len(hashes))
+	for _, hash := range hashes {
+		receipt, err := client.TransactionReceipt(ctx, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if receipt.Status != types.ReceiptStatusSuccessful || len(receipt.Logs) != 1 {
+			t.Fatalf("expected the call to emit one log, got status %d and %d logs", receipt.Status, len(receipt.Logs))
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts
+}
+
+// limitedBackend rejects eth_getLogs over more than maxRange blocks like rpc providers do, and records the ranges queried
+type limitedBackend struct {
+	ChainLogBackend
+	maxRange uint64
+
+	mu     sync.Mutex
+	ranges [][2]uint64
+}
+
+func (b *limitedBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	from, to := query.FromBlock.Uint64(), query.ToBlock.Uint64()
+	b.mu.Lock()
+	b.ranges = append(b.ranges, [2]uint64{from, to})
+	b.mu.Unlock()
+	if to-from+1 > b.maxRange {
+		return nil, fmt.Errorf("block range is too wide, at most %d blocks", b.maxRange)
+	}
+	return b.ChainLogBackend.FilterLogs(ctx, query)
+}
+
+func newTestLogEmitter(t *testing.T) (*simulated.Backend, *ecdsa.PrivateKey, common.Address) {
+	t.Helper()
+	backend, key := newTestChain(t, nil)
+	return backend, key, deployContract(t, backend, key, testLogEmitterCode())
+}
+
+func commitBlocks(backend *simulated.Backend, count int) {
+	for range count {
+		backend.Commit()
+	}
+}
+
+func TestChainLogSourceMatchesIndexer(t *testing.T) {
+	backend, key, governor := newTestLogEmitter(t)
+	proposer := common.HexToAddress("0x00000000000000000000000000000000000000Aa")
+	voter := common.HexToAddress("0x00000000000000000000000000000000000000Bb")
+	paramsVoter := common.HexToAddress("0x00000000000000000000000000000000000000Cc")
+
+	created := sendCalls(t, backend, key, governor, proposalCreatedCall(t, 0xabc, proposer, 10, 20, "# Upgrade the treasury\n\nDetails"))[0]
+	votes := sendCalls(t, backend, key, governor,
+		voteCastCall(t, voter, 0xabc, 1, 1000, "looks good"),
+		voteCastWithParamsCall(t, paramsVoter, 0xabc, 2, 5, "", []byte{0xff}),
+	)
+
+	client := backend.Client()
+	blockTime := func(number *big.Int) uint64 {
+		header, err := client.HeaderByNumber(context.Background(), number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return header.Time
+	}
+	createdTime, votesTime := blockTime(created.BlockNumber), blockTime(votes[0].BlockNumber)
+
+	// the indexer reports the proposal like this, the vote timestamps of a block clock are estimated from
+	// the block of the proposal and the block interval, which is 0 on a chain this short
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Query string `json:"query"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		if strings.Contains(request.Query, "voteCasts") {
+			fmt.Fprintf(w, `{"data":{"voteCasts":[
+				{"id":"%s-%d","proposalId":"0xabc","reason":"looks good","support":1,"voter":"%s","weight":"1000","transactionHash":"%s","blockNumber":"%d","blockTimestamp":"%d"},
+				{"id":"%s-%d","proposalId":"0xabc","reason":"","support":2,"voter":"%s","weight":"5","transactionHash":"%s","blockNumber":"%d","blockTimestamp":"%d"}
+			]}}`,
+				votes[0].TxHash.Hex(), votes[0].Logs[0].Index, strings.ToLower(voter.Hex()), votes[0].TxHash.Hex(), votes[0].BlockNumber, votesTime*1000,
+				votes[1].TxHash.Hex(), votes[1].Logs[0].Index, strings.ToLower(paramsVoter.Hex()), votes[1].TxHash.Hex(), votes[1].BlockNumber, votesTime*1000)
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"proposals":[
+			{"id":"%s-%d","proposalId":"0xabc","title":"Upgrade the treasury","voteStartTimestamp":"%d","voteEndTimestamp":"%d","voteStart":"10","voteEnd":"20","clockMode":"blocknumber","proposer":"%s","blockNumber":"%d","blockTimestamp":"%d","transactionHash":"%s","description":"# Upgrade the treasury\n\nDetails"}
+		]}}`,
+			created.TxHash.Hex(), created.Logs[0].Index, createdTime*1000, createdTime*1000, strings.ToLower(proposer.Hex()), created.BlockNumber, createdTime*1000, created.TxHash.Hex())
+	}))
+	defer indexer.Close()
+
+	ctx := context.Background()
+	chain := NewChainLogSource(client, ChainLogSourceConfig{Governor: governor})
+	chainProposals, err := chain.QueryProposalsOffset(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexerProposals, err := NewDegovIndexer(indexer.URL).QueryProposalsOffset(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(chainProposals, indexerProposals) {
+		t.Fatalf("chain proposals differ from the indexer:\n%+v\n%+v", chainProposals, indexerProposals)
+	}
+
+	// proposal ids are matched in their canonical form
+	chainVotes, err := chain.QueryVotesOffset(ctx, 0, "0x0ABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexerVotes, err := NewDegovIndexer(indexer.URL).QueryVotesOffset(ctx, 0, "0xabc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(chainVotes, indexerVotes) {
+		t.Fatalf("chain votes differ from the indexer:\n%+v\n%+v", chainVotes, indexerVotes)
+	}
+}
+
+func TestChainLogSourceOrderAndPaging(t *testing.T) {
+	backend, key, governor := newTestLogEmitter(t)
+	proposer := common.HexToAddress("0xaa")
+	voter := common.HexToAddress("0xbb")
+
+	// proposals over several blocks with several per block, the votes of proposal 1 span two pages
+	var expectedIDs []string
+	next := int64(1)
+	for _, perBlock := range []int{1, 20, 3, 11} {
+		calls := make([][]byte, 0, perBlock)
+		for range perBlock {
+			calls = append(calls, proposalCreatedCall(t, next, proposer, 1, 2, fmt.Sprintf("Proposal %d", next)))
+			expectedIDs = append(expectedIDs, fmt.Sprintf("0x%x", next))
+			next++
+		}
+		sendCalls(t, backend, key, governor, calls...)
+	}
+	var expectedWeights []string
+	for block := range 2 {
+		calls := make([][]byte, 0, 16)
+		for i := range 16 {
+			weight := int64(block*16 + i + 1)
+			if i%2 == 0 {
+				calls = append(calls, voteCastCall(t, voter, 1, 1, weight, ""))
+			} else {
+				calls = append(calls, voteCastWithParamsCall(t, voter, 1, 1, weight, "", nil))
+			}
+			expectedWeights = append(expectedWeights, fmt.Sprint(weight))
+		}
+		sendCalls(t, backend, key, governor, calls...)
+	}
+
+	ctx := context.Background()
+	source := NewChainLogSource(backend.Client(), ChainLogSourceConfig{Governor: governor})
+
+	var proposalIDs []string
+	for offset := 0; ; {
+		page, err := source.QueryProposalsOffset(ctx, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		if len(page) > chainLogPageSize {
+			t.Fatalf("got a page of %d proposals", len(page))
+		}
+		for _, proposal := range page {
+			proposalIDs = append(proposalIDs, proposal.ProposalID)
+		}
+		offset += len(page)
+	}
+	if !reflect.DeepEqual(proposalIDs, expectedIDs) {
+		t.Fatalf("expected the proposals in log order %v, got %v", expectedIDs, proposalIDs)
+	}
+
+	var weights []string
+	for offset := 0; ; {
+		page, err := source.QueryVotesOffset(ctx, offset, "0x01")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, vote := range page {
+			weights = append(weights, vote.Weight)
+		}
+		offset += len(page)
+	}
+	if !reflect.DeepEqual(weights, expectedWeights) {
+		t.Fatalf("expected the votes in log order %v, got %v", expectedWeights, weights)
+	}
+	if votes, err := source.QueryVotesOffset(ctx, 0, "0x02"); err != nil || len(votes) != 0 {
+		t.Fatalf("expected no votes for a proposal without votes, got %d (%v)", len(votes), err)
+	}
+}
+
+func TestChainLogSourceShrinksBlockRange(t *testing.T) {
+	backend, key, governor := newTestLogEmitter(t)
+	proposer := common.HexToAddress("0xaa")
+	var expectedIDs []string
+	for i := int64(1); i <= 5; i++ {
+		sendCalls(t, backend, key, governor, proposalCreatedCall(t, i, proposer, 1, 2, ""))
+		expectedIDs = append(expectedIDs, fmt.Sprintf("0x%x", i))
+		commitBlocks(backend, 20)
+	}
+
+	limited := &limitedBackend{ChainLogBackend: backend.Client(), maxRange: 12}
+	source := NewChainLogSource(limited, ChainLogSourceConfig{Governor: governor, BlockRange: 64})
+	if err := source.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !source.Complete() {
+		t.Fatal("expected the sync to reach the head with a smaller block range")
+	}
+
+	var proposalIDs []string
+	for _, proposal := range source.Proposals() {
+		proposalIDs = append(proposalIDs, proposal.ProposalID)
+	}
+	if !reflect.DeepEqual(proposalIDs, expectedIDs) {
+		t.Fatalf("expected proposals %v, got %v", expectedIDs, proposalIDs)
+	}
+
+	// 64, 32 and 16 are rejected, the scan continues with ranges of the minimum of 10 blocks without gaps
+	widths := make([]uint64, 0, len(limited.ranges))
+	nextBlock := uint64(0)
+	for _, queried := range limited.ranges {
+		widths = append(widths, queried[1]-queried[0]+1)
+		if queried[1]-queried[0]+1 > limited.maxRange {
+			continue
+		}
+		if queried[0] != nextBlock {
+			t.Fatalf("expected the scan to continue at block %d, got range %v", nextBlock, queried)
+		}
+		nextBlock = queried[1] + 1
+	}
+	if !reflect.DeepEqual(widths[:4], []uint64{64, 32, 16, chainLogMinBlockRange}) {
+		t.Fatalf("expected the block range to halve until accepted, got %v", widths)
+	}
+	if source.blockRange != chainLogMinBlockRange {
+		t.Fatalf("expected the smaller block range to be kept, got %d", source.blockRange)
+	}
+
+	// a node rejecting even the smallest range fails the sync
+	limited = &limitedBackend{ChainLogBackend: backend.Client(), maxRange: chainLogMinBlockRange - 1}
+	if err := NewChainLogSource(limited, ChainLogSourceConfig{Governor: governor}).Sync(context.Background()); err == nil {
+		t.Fatal("expected the sync to fail when the smallest range is rejected")
+	}
+}
+
+func TestChainLogSourceConfirmations(t *testing.T) {
+	backend, key, governor := newTestLogEmitter(t)
+	commitBlocks(backend, 5)
+	sendCalls(t, backend, key, governor, proposalCreatedCall(t, 1, common.HexToAddress("0xaa"), 1, 2, ""))
+
+	ctx := context.Background()
+	source := NewChainLogSource(backend.Client(), ChainLogSourceConfig{Governor: governor, Confirmations: 3})
+	if err := source.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(source.Proposals()) != 0 {
+		t.Fatal("expected the proposal of an unconfirmed block to be left out")
+	}
+	scanned, head := source.Progress()
+	if head-scanned != 3 {
+		t.Fatalf("expected the scan to stay 3 blocks behind the head, scanned %d of %d", scanned, head)
+	}
+
+	commitBlocks(backend, 3)
+	if err := source.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(source.Proposals()) != 1 {
+		t.Fatal("expected the proposal once its block is confirmed")
+	}
+}
+
+func TestChainLogSourceBehindIsAnError(t *testing.T) {
+	backend, key, governor := newTestLogEmitter(t)
+	sendCalls(t, backend, key, governor, proposalCreatedCall(t, 1, common.HexToAddress("0xaa"), 1, 2, ""))
+	commitBlocks(backend, 40)
+	sendCalls(t, backend, key, governor, proposalCreatedCall(t, 2, common.HexToAddress("0xaa"), 1, 2, ""))
+
+	// one range of 10 blocks per sync
+	ctx := context.Background()
+	source := NewChainLogSource(backend.Client(), ChainLogSourceConfig{Governor: governor, BlockRange: 10, MaxRanges: 1})
+	page, err := source.QueryProposalsOffset(ctx, 0)
+	if err != nil || len(page) != 1 {
+		t.Fatalf("expected the proposal of the scanned range, got %d (%v)", len(page), err)
+	}
+	if _, err := source.QueryProposalsOffset(ctx, 1); !errors.Is(err, ErrChainLogBehind) {
+		t.Fatalf("expected the end of the scanned logs to be reported as behind, got %v", err)
+	}
+	if _, err := source.QueryVotesOffset(ctx, 0, "0x1"); !errors.Is(err, ErrChainLogBehind) {
+		t.Fatalf("expected the votes to be reported as behind, got %v", err)
+	}
+
+	for !source.Complete() {
+		if err := source.Sync(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if page, err := source.QueryProposalsOffset(ctx, 1); err != nil || len(page) != 1 {
+		t.Fatalf("expected the second proposal once caught up, got %d (%v)", len(page), err)
+	}
+	if page, err := source.QueryProposalsOffset(ctx, 2); err != nil || len(page) != 0 {
+		t.Fatalf("expected the end of the list once caught up, got %d (%v)", len(page), err)
+	}
+}
@@ -102,6 +102,17 @@ func setDefaults(v *viper.Viper) {
 	// Environment defaults
 	v.SetDefault("APP_ENV", "production")
 
+	// Auth defaults
+	v.SetDefault("AUTH_ACCESS_TOKEN_TTL", "15m")
+	v.SetDefault("AUTH_REFRESH_TOKEN_TTL", "720h")
+	v.SetDefault("KV_STORE", "postgres")
+	v.SetDefault("SIWE_ALLOWED_DOMAINS", "degov.ai,*.degov.ai")
+	v.SetDefault("UNSUBSCRIBE_TOKEN_TTL", "2160h")
+	v.SetDefault("CORS_ALLOWED_ORIGINS", "*")
+
+	// public url of this api, used in links of notifications
+	v.SetDefault("DEGOV_API_URL", "https://api.degov.ai")
+
 	// Task defaults
 	v.SetDefault("TASK_DAO_SYNC_ENABLED", true)
 	v.SetDefault("TASK_DAO_SYNC_INTERVAL", "5m")
@@ -111,14 +122,31 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("TASK_VOTE_END_TRACKING_INTERVAL", "4m")
 	v.SetDefault("TASK_PROPOSAL_TRACKING_ENABLED", true)
 	v.SetDefault("TASK_PROPOSAL_TRACKING_INTERVAL", "3m")
+	v.SetDefault("TASK_TIMELOCK_TRACKING_ENABLED", true)
+	v.SetDefault("TASK_TIMELOCK_TRACKING_INTERVAL", "5m")
+	v.SetDefault("PROPOSAL_EXPIRING_LEAD_TIME", "48h")
 	v.SetDefault("TASK_NOTIFICATION_EVENT_ENABLED", true)
 	v.SetDefault("TASK_NOTIFICATION_EVENT_INTERVAL", "10s")
 	v.SetDefault("TASK_NOTIFICATION_DISPATCHER_ENABLED", true)
 	v.SetDefault("TASK_NOTIFICATION_DISPATCHER_INTERVAL", "5s")
+	v.SetDefault("TASK_TELEGRAM_UPDATES_ENABLED", true)
+	v.SetDefault("TASK_TELEGRAM_UPDATES_INTERVAL", "5s")
 
 	// sendgrid
 	v.SetDefault("SENDGRID_FROM_USER", "DeGov Notifications")
 	v.SetDefault("SENDGRID_FROM_EMAIL", "notifications@degov.ai")
+
+	// webhooks are only delivered to public addresses
+	v.SetDefault("WEBHOOK_ALLOW_PRIVATE_ADDRESSES", false)
+
+	// the chain log source stays behind the chain head by this many blocks, CHAIN_LOG_CONFIRMATIONS_<chainId> overrides it
+	v.SetDefault("CHAIN_LOG_CONFIRMATIONS", 12)
+
+	// telegram
+	v.SetDefault("TELEGRAM_BOT_API_URL", "https://api.telegram.org")
+
+	// function signature database, decodes proposal actions of targets without verified abi
+	v.SetDefault("FOUR_BYTE_API_URL", "https://www.4byte.directory")
 }
 
 // Server configuration methods
@@ -230,6 +258,14 @@ func (c *Config) GetTaskProposalTrackingInterval() time.Duration {
 	return c.viper.GetDuration("TASK_PROPOSAL_TRACKING_INTERVAL")
 }
 
+func (c *Config) GetTaskTimelockTrackingEnabled() bool {
+	return c.viper.GetBool("TASK_TIMELOCK_TRACKING_ENABLED")
+}
+
+func (c *Config) GetTaskTimelockTrackingInterval() time.Duration {
+	return c.viper.GetDuration("TASK_TIMELOCK_TRACKING_INTERVAL")
+}
+
 func (c *Config) GetTaskNotificationEventEnabled() bool {
 	return c.viper.GetBool("TASK_NOTIFICATION_EVENT_ENABLED")
 }
@@ -246,6 +282,14 @@ func (c *Config) GetTaskNotificationDispatcherInterval() time.Duration {
 	return c.viper.GetDuration("TASK_NOTIFICATION_DISPATCHER_INTERVAL")
 }
 
+func (c *Config) GetTaskTelegramUpdatesEnabled() bool {
+	return c.viper.GetBool("TASK_TELEGRAM_UPDATES_ENABLED") && c.viper.GetString("TELEGRAM_BOT_TOKEN") != ""
+}
+
+func (c *Config) GetTaskTelegramUpdatesInterval() time.Duration {
+	return c.viper.GetDuration("TASK_TELEGRAM_UPDATES_INTERVAL")
+}
+
 // Generic configuration methods
 func (c *Config) GetString(key string) string {
 	return c.viper.GetString(key)
@@ -334,6 +378,17 @@ func GetDuration(key string) time.Duration {
 	return GetConfig().GetDuration(key)
 }
 
+// GetCorsAllowedOrigins returns the origins of CORS_ALLOWED_ORIGINS, they also restrict the websocket subscriptions
+func GetCorsAllowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(GetString("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
 func GetEmailStyle() types.EmailStyle {
 	return types.EmailStyle{
 		ContainerMaxWidth: "600px",
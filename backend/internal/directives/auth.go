@@ -3,10 +3,14 @@ package directives
 import (
 	"context"
 	"fmt"
+	"log/slog"
 
 	"github.com/99designs/gqlgen/graphql"
 	gqlmodels "github.com/ringecosystem/degov-apps/graph/models"
 	"github.com/ringecosystem/degov-apps/internal/middleware"
+	"github.com/ringecosystem/degov-apps/services"
+	"github.com/ringecosystem/degov-apps/types"
+	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
 // AuthDirective handles @auth directive
@@ -21,7 +25,7 @@ func AuthDirective(ctx context.Context, obj interface{}, next graphql.Resolver,
 		// Authentication is required
 		_, err := middleware.RequireAuth(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("authentication required: %v", err)
+			return nil, unauthenticated(ctx, fmt.Sprintf("authentication required: %v", err))
 		}
 	}
 	// else {
@@ -38,28 +42,48 @@ func AuthDirective(ctx context.Context, obj interface{}, next graphql.Resolver,
 	return next(ctx)
 }
 
+// unauthenticated builds the error of a field which requires authentication, the UNAUTHENTICATED code tells
+// clients to refresh their token or to log in
+func unauthenticated(ctx context.Context, message string) error {
+	return &gqlerror.Error{
+		Path:    graphql.GetPath(ctx),
+		Message: message,
+		Extensions: map[string]interface{}{
+			"code": "UNAUTHENTICATED",
+		},
+	}
+}
+
 // AuthorizeDirective handles @authorize directive
 func AuthorizeDirective(ctx context.Context, obj interface{}, next graphql.Resolver, rule gqlmodels.AuthRule) (interface{}, error) {
 	// Get authenticated user
 	claims, err := middleware.RequireAuth(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("authentication required for authorization: %v", err)
+		return nil, unauthenticated(ctx, fmt.Sprintf("authentication required for authorization: %v", err))
 	}
 
 	user := claims.User
 	switch rule {
 	case gqlmodels.AuthRuleOwnerOnly:
-		// For OWNER_ONLY, we need to extract the resource owner from the arguments
-		// This is a simplified implementation - in practice, you might need more sophisticated logic
-		// return authorizeOwnerOnly(ctx, claims, obj, next)
-		return next(ctx)
+		if err := authorizeOwnerOnly(ctx, user); err != nil {
+			return nil, err
+		}
 
 	case gqlmodels.AuthRuleAdminOnly:
-		// Check if user is admin (you'll need to implement this based on your user model)
-		if !isAdmin(user.Address) {
+		if !user.IsAdmin() {
 			return nil, fmt.Errorf("admin privileges required")
 		}
 
+	case gqlmodels.AuthRuleDaoAdmin:
+		// admins can operate every dao, dao admins only the dao given by the daoCode argument
+		if user.IsAdmin() {
+			break
+		}
+		daoCode := daoCodeArgument(ctx)
+		if daoCode == "" || !user.IsDaoAdmin(daoCode) {
+			return nil, fmt.Errorf("dao admin privileges required")
+		}
+
 	case gqlmodels.AuthRulePublic:
 		// Public access - no additional authorization needed
 		break
@@ -71,65 +95,64 @@ func AuthorizeDirective(ctx context.Context, obj interface{}, next graphql.Resol
 	return next(ctx)
 }
 
-// // authorizeOwnerOnly checks if the authenticated user owns the resource
-// func authorizeOwnerOnly(ctx context.Context, claims *middleware.AuthClaims, obj interface{}, next graphql.Resolver) (interface{}, error) {
-// 	// Extract field arguments to find the resource owner
-// 	fieldCtx := graphql.GetFieldContext(ctx)
-// 	if fieldCtx == nil {
-// 		return nil, fmt.Errorf("failed to get field context")
-// 	}
-
-// 	// Check different argument patterns to find the user/owner ID
-// 	args := fieldCtx.Args
-
-// 	// Pattern 1: Direct userId argument
-// 	if userID, ok := args["userId"].(string); ok {
-// 		if claims.Address != userID {
-// 			return nil, fmt.Errorf("permission denied: can only access your own resources")
-// 		}
-// 		return next(ctx)
-// 	}
-
-// 	// Pattern 2: Input object with userId field
-// 	if input, ok := args["input"]; ok {
-// 		if inputMap, ok := input.(map[string]interface{}); ok {
-// 			if userID, ok := inputMap["userId"].(string); ok {
-// 				if claims.Address != userID {
-// 					return nil, fmt.Errorf("permission denied: can only access your own resources")
-// 				}
-// 				return next(ctx)
-// 			}
-
-// 			// Pattern 3: Input object with address field (for createUser)
-// 			if address, ok := inputMap["address"].(string); ok {
-// 				if claims.Address != address {
-// 					return nil, fmt.Errorf("permission denied: can only create user for your own address")
-// 				}
-// 				return next(ctx)
-// 			}
-// 		}
-// 	}
-
-// 	// Pattern 4: For "me" query - always allow since user is accessing their own data
-// 	if fieldCtx.Field.Name == "me" {
-// 		return next(ctx)
-// 	}
-
-// 	// Pattern 5: Check if this is a user update operation without explicit user ID
-// 	// In this case, we assume the user is updating their own profile
-// 	if fieldCtx.Field.Name == "updateUser" {
-// 		return next(ctx)
-// 	}
-
-// 	// If we can't determine ownership, deny access
-// 	return nil, fmt.Errorf("permission denied: unable to verify resource ownership")
-// }
-
-// isAdmin checks if the user has admin privileges
-// You should implement this based on your user model and admin logic
-func isAdmin(address string) bool {
-	// TODO: Implement admin check logic
-	// This could check a database, smart contract, or configuration
-	// For now, return false (no admins)
-	return false
+// authorizeOwnerOnly checks that every resource named by the field arguments (see services.OwnedResourceKinds)
+// belongs to the authenticated user. Fields without such arguments operate on the data of the user itself
+func authorizeOwnerOnly(ctx context.Context, user *types.UserSessInfo) error {
+	if user == nil || user.Id == "" {
+		return fmt.Errorf("authentication required for authorization")
+	}
+
+	args := fieldArguments(ctx)
+	input, _ := args["input"].(map[string]interface{})
+
+	ownershipService := services.NewOwnershipService()
+	for _, kind := range services.OwnedResourceKinds {
+		for _, source := range []map[string]interface{}{args, input} {
+			value, ok := source[string(kind)]
+			if !ok || value == nil {
+				continue
+			}
+			id, ok := value.(string)
+			if !ok || id == "" {
+				return fmt.Errorf("permission denied: invalid %s", kind)
+			}
+			owner, err := ownershipService.OwnerOf(kind, id)
+			if err != nil {
+				slog.Error("Failed to resolve resource owner", "kind", kind, "id", id, "error", err)
+				return fmt.Errorf("permission denied: unable to verify resource ownership")
+			}
+			// a missing resource is reported the same way as a foreign one, so ids of other users can not be probed
+			if owner != user.Id {
+				return fmt.Errorf("permission denied: can only access your own resources")
+			}
+		}
+	}
+	return nil
+}
+
+// fieldArguments returns the raw arguments of the field, input objects are map[string]interface{}
+func fieldArguments(ctx context.Context) map[string]interface{} {
+	fieldCtx := graphql.GetFieldContext(ctx)
+	if fieldCtx == nil || fieldCtx.Field.Field == nil {
+		return map[string]interface{}{}
+	}
+	var variables map[string]interface{}
+	if graphql.HasOperationContext(ctx) {
+		variables = graphql.GetOperationContext(ctx).Variables
+	}
+	return fieldCtx.Field.ArgumentMap(variables)
+}
+
+// daoCodeArgument reads the daoCode argument of the field, either top level or inside the input object
+func daoCodeArgument(ctx context.Context) string {
+	args := fieldArguments(ctx)
+	if daoCode, ok := args["daoCode"].(string); ok {
+		return daoCode
+	}
+	if input, ok := args["input"].(map[string]interface{}); ok {
+		if daoCode, ok := input["daoCode"].(string); ok {
+			return daoCode
+		}
+	}
+	return ""
 }
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// EIP1271MagicValue is returned by isValidSignature when the signature is valid
+// https://eips.ethereum.org/EIPS/eip-1271
+var EIP1271MagicValue = [4]byte{0x16, 0x26, 0xba, 0x7e}
+
+const eip1271ABI = `[{
+	"inputs": [
+		{"internalType": "bytes32", "name": "hash", "type": "bytes32"},
+		{"internalType": "bytes", "name": "signature", "type": "bytes"}
+	],
+	"name": "isValidSignature",
+	"outputs": [{"internalType": "bytes4", "name": "magicValue", "type": "bytes4"}],
+	"stateMutability": "view",
+	"type": "function"
+}]`
+
+// ContractBackend is the part of ethclient.Client used to verify contract signatures,
+// a simulated backend satisfies it as well
+type ContractBackend interface {
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+}
+
+// HasCode reports whether a contract is deployed at the address
+func HasCode(ctx context.Context, backend ContractBackend, address common.Address) (bool, error) {
+	code, err := backend.CodeAt(ctx, address, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to get code of %s: %w", address.Hex(), err)
+	}
+	return len(code) > 0, nil
+}
+
+// VerifyEIP1271Signature asks the contract wallet whether the signature of the hash is valid.
+// A reverted call is reported as an invalid signature, not as an error
+func VerifyEIP1271Signature(ctx context.Context, backend ContractBackend, wallet common.Address, hash common.Hash, signature []byte) (bool, error) {
+	contractABI, err := abi.JSON(strings.NewReader(eip1271ABI))
+	if err != nil {
+		return false, fmt.Errorf("failed to parse eip1271 ABI: %w", err)
+	}
+
+	callData, err := contractABI.Pack("isValidSignature", hash, signature)
+	if err != nil {
+		return false, fmt.Errorf("failed to pack isValidSignature call: %w", err)
+	}
+
+	result, err := backend.CallContract(ctx, ethereum.CallMsg{
+		To:   &wallet,
+		Data: callData,
+	}, nil)
+	if err != nil {
+		if strings.Contains(err.Error(), "revert") {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to call isValidSignature: %w", err)
+	}
+
+	// some wallets return the magic value without abi padding, only the first 4 bytes matter
+	if len(result) < 4 {
+		return false, nil
+	}
+	return bytes.Equal(result[:4], EIP1271MagicValue[:]), nil
+}
@@ -3,96 +3,248 @@ package internal
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"math/big"
-	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
-	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
 
 	dbmodels "github.com/ringecosystem/degov-apps/database/models"
 )
 
+// governorStateBatchSize is the number of state calls aggregated into one multicall
+const governorStateBatchSize = 100
+
 // GovernorContract handles governor contract interactions
 type GovernorContract struct {
-	client *ethclient.Client
+	backend ContractBackend
+	// hasMulticall caches whether Multicall3 is deployed on the chain, nil until checked
+	hasMulticall *bool
 }
 
-// NewGovernorContract creates a new Governor contract client
-func NewGovernorContract(rpcURL string) (*GovernorContract, error) {
-	client, err := ethclient.Dial(rpcURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Ethereum client: %w", err)
-	}
-
-	return &GovernorContract{
-		client: client,
-	}, nil
+// ProposalStateResult is the state of one proposal read by GetProposalStates
+type ProposalStateResult struct {
+	State dbmodels.ProposalState
+	Err   error
 }
 
-// Close closes the client connection
-func (g *GovernorContract) Close() {
-	g.client.Close()
+// NewGovernorContract creates a Governor contract client, the backend is usually the RPCPool of the chain
+// and may be a simulated one
+func NewGovernorContract(backend ContractBackend) *GovernorContract {
+	return &GovernorContract{
+		backend: backend,
+	}
 }
 
-// Governor contract ABI for the state function
+// Governor contract ABI for the state and proposalEta functions
 const governorStateABI = `[{
 	"inputs": [{"internalType": "uint256", "name": "proposalId", "type": "uint256"}],
 	"name": "state",
 	"outputs": [{"internalType": "enum IGovernor.ProposalState", "name": "", "type": "uint8"}],
 	"stateMutability": "view",
 	"type": "function"
+}, {
+	"inputs": [{"internalType": "uint256", "name": "proposalId", "type": "uint256"}],
+	"name": "proposalEta",
+	"outputs": [{"internalType": "uint256", "name": "", "type": "uint256"}],
+	"stateMutability": "view",
+	"type": "function"
 }]`
 
+// Compound style timelock ABI for the GRACE_PERIOD constant, TimelockController has no grace period
+const timelockGracePeriodABI = `[{
+	"inputs": [],
+	"name": "GRACE_PERIOD",
+	"outputs": [{"internalType": "uint256", "name": "", "type": "uint256"}],
+	"stateMutability": "view",
+	"type": "function"
+}]`
+
+var (
+	governorABI = mustParseABI(governorStateABI)
+	timelockABI = mustParseABI(timelockGracePeriodABI)
+)
+
 // GetProposalState queries the governor contract for proposal state
 func (g *GovernorContract) GetProposalState(ctx context.Context, contractAddress, proposalID string) (dbmodels.ProposalState, error) {
-	// Parse the ABI
-	contractABI, err := abi.JSON(strings.NewReader(governorStateABI))
+	callData, err := packProposalStateCall(proposalID)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse governor contract ABI: %w", err)
+		return "", err
 	}
 
-	// Convert proposal ID to big.Int (hex format only)
-	// Remove 0x or 0X prefix if present
-	cleanProposalID := proposalID
-	if len(proposalID) >= 2 && (proposalID[:2] == "0x" || proposalID[:2] == "0X") {
-		cleanProposalID = proposalID[2:]
+	// Create call message
+	contractAddr := common.HexToAddress(contractAddress)
+	callMsg := ethereum.CallMsg{
+		To:   &contractAddr,
+		Data: callData,
 	}
 
-	// Parse as hex
-	proposalBigInt, ok := new(big.Int).SetString(cleanProposalID, 16)
-	if !ok {
-		return "", fmt.Errorf("invalid hex proposal ID: %s", proposalID)
+	// Call the contract
+	result, err := g.backend.CallContract(ctx, callMsg, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to call contract: %w", err)
+	}
+	return unpackProposalState(result)
+}
+
+// GetProposalStates reads the states of the proposals, the results are in the order of the ids.
+// The calls are aggregated through Multicall3 where a failing call only fails its own result,
+// chains without Multicall3 and batches whose multicall fails fall back to one call per proposal
+func (g *GovernorContract) GetProposalStates(ctx context.Context, contractAddress string, proposalIDs []string) ([]ProposalStateResult, error) {
+	results := make([]ProposalStateResult, len(proposalIDs))
+	if len(proposalIDs) == 0 {
+		return results, nil
+	}
+
+	hasMulticall, err := g.multicallAvailable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !hasMulticall {
+		for i, proposalID := range proposalIDs {
+			results[i].State, results[i].Err = g.GetProposalState(ctx, contractAddress, proposalID)
+		}
+		return results, nil
 	}
 
-	// Pack the function call data using ABI
-	callData, err := contractABI.Pack("state", proposalBigInt)
+	governor := common.HexToAddress(contractAddress)
+	for start := 0; start < len(proposalIDs); start += governorStateBatchSize {
+		end := min(start+governorStateBatchSize, len(proposalIDs))
+
+		// invalid ids are reported in their result and left out of the batch
+		calls := make([]Multicall3Call, 0, end-start)
+		indexes := make([]int, 0, end-start)
+		for i := start; i < end; i++ {
+			callData, err := packProposalStateCall(proposalIDs[i])
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			calls = append(calls, Multicall3Call{Target: governor, AllowFailure: true, CallData: callData})
+			indexes = append(indexes, i)
+		}
+		if len(calls) == 0 {
+			continue
+		}
+
+		callResults, err := Aggregate3(ctx, g.backend, Multicall3Address, calls)
+		if err != nil {
+			// a failing multicall must not stall the tracking of the dao, the batch is read call by call
+			slog.Warn("Multicall of proposal states failed, reading them one by one", "governor", contractAddress, "proposals", len(calls), "error", err)
+			for _, i := range indexes {
+				results[i].State, results[i].Err = g.GetProposalState(ctx, contractAddress, proposalIDs[i])
+			}
+			continue
+		}
+		for j, callResult := range callResults {
+			i := indexes[j]
+			if !callResult.Success {
+				results[i].Err = fmt.Errorf("state call of proposal %s reverted", proposalIDs[i])
+				continue
+			}
+			results[i].State, results[i].Err = unpackProposalState(callResult.ReturnData)
+		}
+	}
+	return results, nil
+}
+
+// GetProposalEta reads the time the queued proposal becomes executable, the zero time is returned
+// for proposals which are not queued
+func (g *GovernorContract) GetProposalEta(ctx context.Context, contractAddress, proposalID string) (time.Time, error) {
+	proposalBigInt, err := parseProposalID(proposalID)
 	if err != nil {
-		return "", fmt.Errorf("failed to pack function call data: %w", err)
+		return time.Time{}, err
+	}
+	callData, err := governorABI.Pack("proposalEta", proposalBigInt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to pack function call data: %w", err)
 	}
 
-	// Create call message
 	contractAddr := common.HexToAddress(contractAddress)
-	callMsg := ethereum.CallMsg{
-		To:   &contractAddr,
-		Data: callData,
+	result, err := g.backend.CallContract(ctx, ethereum.CallMsg{To: &contractAddr, Data: callData}, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to call contract: %w", err)
+	}
+	var eta *big.Int
+	if err := governorABI.UnpackIntoInterface(&eta, "proposalEta", result); err != nil {
+		return time.Time{}, fmt.Errorf("failed to unpack contract result: %w", err)
+	}
+	if eta.Sign() == 0 || !eta.IsInt64() {
+		return time.Time{}, nil
 	}
+	return time.Unix(eta.Int64(), 0), nil
+}
 
-	// Call the contract
-	result, err := g.client.CallContract(ctx, callMsg, nil)
+// GetTimelockGracePeriod reads the grace period of a Compound style timelock, after eta + grace period a queued
+// proposal expires. ok is false for timelocks without grace period, such as TimelockController
+func (g *GovernorContract) GetTimelockGracePeriod(ctx context.Context, timelockAddress string) (gracePeriod time.Duration, ok bool, err error) {
+	callData, err := timelockABI.Pack("GRACE_PERIOD")
 	if err != nil {
-		return "", fmt.Errorf("failed to call contract: %w", err)
+		return 0, false, fmt.Errorf("failed to pack function call data: %w", err)
 	}
 
-	// Unpack the result using ABI
-	var stateResult uint8
-	err = contractABI.UnpackIntoInterface(&stateResult, "state", result)
+	contractAddr := common.HexToAddress(timelockAddress)
+	result, err := g.backend.CallContract(ctx, ethereum.CallMsg{To: &contractAddr, Data: callData}, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to unpack contract result: %w", err)
+		if isExecutionReverted(err) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to call contract: %w", err)
+	}
+	var seconds *big.Int
+	if err := timelockABI.UnpackIntoInterface(&seconds, "GRACE_PERIOD", result); err != nil || !seconds.IsInt64() || seconds.Sign() == 0 {
+		// no such function, e.g. the fallback of a TimelockController returned nothing
+		return 0, false, nil
+	}
+	return time.Duration(seconds.Int64()) * time.Second, true, nil
+}
+
+func (g *GovernorContract) multicallAvailable(ctx context.Context) (bool, error) {
+	if g.hasMulticall == nil {
+		hasCode, err := HasCode(ctx, g.backend, Multicall3Address)
+		if err != nil {
+			return false, err
+		}
+		g.hasMulticall = &hasCode
+	}
+	return *g.hasMulticall, nil
+}
+
+// packProposalStateCall packs the state(proposalId) call, the proposal id is hex encoded
+func packProposalStateCall(proposalID string) ([]byte, error) {
+	proposalBigInt, err := parseProposalID(proposalID)
+	if err != nil {
+		return nil, err
+	}
+
+	callData, err := governorABI.Pack("state", proposalBigInt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack function call data: %w", err)
 	}
+	return callData, nil
+}
+
+// parseProposalID parses a hex encoded proposal id
+func parseProposalID(proposalID string) (*big.Int, error) {
+	// Remove 0x or 0X prefix if present
+	cleanProposalID := proposalID
+	if len(proposalID) >= 2 && (proposalID[:2] == "0x" || proposalID[:2] == "0X") {
+		cleanProposalID = proposalID[2:]
+	}
+
+	proposalBigInt, ok := new(big.Int).SetString(cleanProposalID, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid hex proposal ID: %s", proposalID)
+	}
+	return proposalBigInt, nil
+}
 
-	// Convert to ProposalState
+func unpackProposalState(result []byte) (dbmodels.ProposalState, error) {
+	var stateResult uint8
+	if err := governorABI.UnpackIntoInterface(&stateResult, "state", result); err != nil {
+		return "", fmt.Errorf("failed to unpack contract result: %w", err)
+	}
 	return convertToProposalState(uint64(stateResult)), nil
 }
 
@@ -119,31 +271,3 @@ func convertToProposalState(state uint64) dbmodels.ProposalState {
 		return dbmodels.ProposalStateUnknown // Default fallback
 	}
 }
-
-// GetRPCURL tries to get RPC URL from config or fallback to default
-func GetRPCURL(chainRPCs []string, chainID int) string {
-	// Use the first RPC from config if available
-	if len(chainRPCs) > 0 && strings.TrimSpace(chainRPCs[0]) != "" {
-		return chainRPCs[0]
-	}
-
-	// Fallback to common public RPCs based on chain ID
-	switch chainID {
-	case 1:
-		return "https://eth.llamarpc.com"
-	case 46:
-		return "https://rpc.darwinia.network"
-	case 56:
-		return "https://bsc-dataseed.binance.org"
-	case 137:
-		return "https://polygon-rpc.com"
-	case 42161:
-		return "https://arb1.arbitrum.io/rpc"
-	case 10:
-		return "https://mainnet.optimism.io"
-	case 43114:
-		return "https://api.avax.network/ext/bc/C/rpc"
-	default:
-		return "" // No fallback available
-	}
-}
@@ -0,0 +1,300 @@
+package internal
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/program"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/params"
+
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+)
+
+// revertingProposalID makes the test governor revert its state call
+const revertingProposalID = 0xdead
+
+// assemble builds bytecode with forward jumps in two passes, jump targets are pushed with a fixed
+// width PUSH2 so the label positions of the first pass hold in the second
+func assemble(build func(p *program.Program, labels map[string]uint64)) []byte {
+	labels := map[string]uint64{}
+	build(program.New(), labels)
+	p := program.New()
+	build(p, labels)
+	return p.Bytes()
+}
+
+func push2(p *program.Program, value uint64) *program.Program {
+	return p.Op(vm.PUSH2).Append([]byte{byte(value >> 8), byte(value)})
+}
+
+func label(p *program.Program, labels map[string]uint64, name string) {
+	_, labels[name] = p.Jumpdest()
+}
+
+// testGovernorCode answers state(id) with id % 8 and reverts for revertingProposalID
+func testGovernorCode() []byte {
+	return assemble(func(p *program.Program, labels map[string]uint64) {
+		p.Push(4).Op(vm.CALLDATALOAD)
+		p.Op(vm.DUP1).Push(revertingProposalID).Op(vm.EQ)
+		push2(p, labels["revert"]).Op(vm.JUMPI)
+		p.Push(8).Op(vm.SWAP1, vm.MOD)
+		p.Push(0).Op(vm.MSTORE)
+		p.Return(0, 32)
+		label(p, labels, "revert")
+		p.Push(0).Push(0).Op(vm.REVERT)
+	})
+}
+
+// testMulticall3Code implements aggregate3((address,bool,bytes)[]) like Multicall3, any selector is treated as
+// aggregate3. The loop variables live in memory, the result is encoded from memory offset 0x100
+func testMulticall3Code() []byte {
+	const (
+		vN     = 0x00 // number of calls
+		vI     = 0x20 // index of the current call
+		vTail  = 0x40 // where the next Result is written
+		vArr   = 0x60 // calldata offset of the Call3 array length
+		vT     = 0x80 // calldata offset of the current Call3
+		vL     = 0xa0 // calldata length of the current call
+		vAllow = 0xc0 // allowFailure of the current call
+		out    = 0x100
+		head   = out + 0x40 // offsets of the Results
+	)
+	return assemble(func(p *program.Program, labels map[string]uint64) {
+		p.Push(4).Op(vm.CALLDATALOAD).Push(4).Op(vm.ADD)
+		p.Op(vm.DUP1).Push(vArr).Op(vm.MSTORE)
+		p.Op(vm.CALLDATALOAD)
+		p.Op(vm.DUP1).Push(vN).Op(vm.MSTORE)
+		p.Op(vm.DUP1).Push(out + 0x20).Op(vm.MSTORE)
+		p.Push(0x20).Push(out).Op(vm.MSTORE)
+		p.Push(5).Op(vm.SHL).Push(head).Op(vm.ADD).Push(vTail).Op(vm.MSTORE)
+
+		label(p, labels, "loop")
+		p.Push(vN).Op(vm.MLOAD).Push(vI).Op(vm.MLOAD, vm.LT, vm.ISZERO)
+		push2(p, labels["end"]).Op(vm.JUMPI)
+
+		// the Call3 tuple and its calldata
+		p.Push(vArr).Op(vm.MLOAD).Push(0x20).Op(vm.ADD)
+		p.Op(vm.DUP1).Push(vI).Op(vm.MLOAD).Push(5).Op(vm.SHL, vm.ADD, vm.CALLDATALOAD, vm.ADD)
+		p.Op(vm.DUP1).Push(vT).Op(vm.MSTORE)
+		p.Op(vm.DUP1).Push(0x20).Op(vm.ADD, vm.CALLDATALOAD).Push(vAllow).Op(vm.MSTORE)
+		p.Op(vm.DUP1).Push(0x40).Op(vm.ADD, vm.CALLDATALOAD, vm.ADD)
+		p.Op(vm.DUP1, vm.CALLDATALOAD).Push(vL).Op(vm.MSTORE)
+		p.Push(vL).Op(vm.MLOAD, vm.SWAP1).Push(0x20).Op(vm.ADD)
+		p.Push(vTail).Op(vm.MLOAD).Push(0x60).Op(vm.ADD, vm.CALLDATACOPY)
+
+		// call the target with the calldata copied to where its return data goes
+		p.Push(0).Push(0).Push(vL).Op(vm.MLOAD).Push(vTail).Op(vm.MLOAD).Push(0x60).Op(vm.ADD)
+		p.Push(0).Push(vT).Op(vm.MLOAD, vm.CALLDATALOAD, vm.GAS, vm.CALL)
+		p.Op(vm.DUP1).Push(vAllow).Op(vm.MLOAD, vm.OR)
+		push2(p, labels["store"]).Op(vm.JUMPI)
+		p.Push(0).Push(0).Op(vm.REVERT)
+
+		// Result(success, returnData)
+		label(p, labels, "store")
+		p.Push(vTail).Op(vm.MLOAD, vm.MSTORE)
+		p.Push(0x40).Push(vTail).Op(vm.MLOAD).Push(0x20).Op(vm.ADD, vm.MSTORE)
+		p.Op(vm.RETURNDATASIZE).Push(vTail).Op(vm.MLOAD).Push(0x40).Op(vm.ADD, vm.MSTORE)
+		p.Op(vm.RETURNDATASIZE).Push(0).Push(vTail).Op(vm.MLOAD).Push(0x60).Op(vm.ADD, vm.RETURNDATACOPY)
+		p.Push(head).Push(vTail).Op(vm.MLOAD, vm.SUB)
+		p.Push(vI).Op(vm.MLOAD).Push(5).Op(vm.SHL).Push(head).Op(vm.ADD, vm.MSTORE)
+		p.Push(0x1f).Op(vm.RETURNDATASIZE, vm.ADD).Push(5).Op(vm.SHR).Push(5).Op(vm.SHL)
+		p.Push(0x60).Op(vm.ADD).Push(vTail).Op(vm.MLOAD, vm.ADD).Push(vTail).Op(vm.MSTORE)
+		p.Push(1).Push(vI).Op(vm.MLOAD, vm.ADD).Push(vI).Op(vm.MSTORE)
+		push2(p, labels["loop"]).Op(vm.JUMP)
+
+		label(p, labels, "end")
+		p.Push(out).Push(vTail).Op(vm.MLOAD, vm.SUB).Push(out).Op(vm.RETURN)
+	})
+}
+
+// newTestChain starts a simulated chain with a funded deployer and the extra genesis accounts
+func newTestChain(t *testing.T, alloc types.GenesisAlloc) (*simulated.Backend, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alloc == nil {
+		alloc = types.GenesisAlloc{}
+	}
+	alloc[crypto.PubkeyToAddress(key.PublicKey)] = types.Account{Balance: big.NewInt(params.Ether)}
+	backend := simulated.NewBackend(alloc)
+	t.Cleanup(func() { backend.Close() })
+	return backend, key
+}
+
+// deployContract deploys the runtime code with a constructor returning it
+func deployContract(t *testing.T, backend *simulated.Backend, key *ecdsa.PrivateKey, runtime []byte) common.Address {
+	t.Helper()
+	ctx := context.Background()
+	client := backend.Client()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	nonce, err := client.PendingNonceAt(ctx, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := types.SignTx(types.NewContractCreation(nonce, big.NewInt(0), 1_000_000, gasPrice, program.New().ReturnViaCodeCopy(runtime).Bytes()),
+		types.LatestSignerForChainID(chainID), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SendTransaction(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+
+	receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatal("contract deployment failed")
+	}
+	return receipt.ContractAddress
+}
+
+func proposalIDs(ids ...uint64) []string {
+	proposalIDs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		proposalIDs = append(proposalIDs, fmt.Sprintf("0x%x", id))
+	}
+	return proposalIDs
+}
+
+// checkProposalStates expects the state id % 8 for every id and an error for revertingProposalID
+func checkProposalStates(t *testing.T, ids []uint64, results []ProposalStateResult) {
+	t.Helper()
+	if len(results) != len(ids) {
+		t.Fatalf("got %d results for %d ids", len(results), len(ids))
+	}
+	for i, id := range ids {
+		if id == revertingProposalID {
+			if results[i].Err == nil {
+				t.Errorf("result %d: expected the reverted state call to fail", i)
+			}
+			continue
+		}
+		if results[i].Err != nil {
+			t.Errorf("result %d: unexpected error: %v", i, results[i].Err)
+			continue
+		}
+		if expected := convertToProposalState(id % 8); results[i].State != expected {
+			t.Errorf("result %d of proposal %d: got state %s, expected %s", i, id, results[i].State, expected)
+		}
+	}
+}
+
+func TestGetProposalStatesMulticallRevertOnlyFailsItsResult(t *testing.T) {
+	backend, key := newTestChain(t, types.GenesisAlloc{Multicall3Address: {Code: testMulticall3Code()}})
+	governor := deployContract(t, backend, key, testGovernorCode())
+
+	contract := NewGovernorContract(backend.Client())
+	ids := []uint64{1, revertingProposalID, 4, 7}
+	results, err := contract.GetProposalStates(context.Background(), governor.Hex(), proposalIDs(ids...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contract.hasMulticall == nil || !*contract.hasMulticall {
+		t.Fatal("expected the multicall path on a chain with Multicall3")
+	}
+	checkProposalStates(t, ids, results)
+}
+
+func TestAggregate3RevertedCallOnlyFailsItsResult(t *testing.T) {
+	backend, key := newTestChain(t, types.GenesisAlloc{Multicall3Address: {Code: testMulticall3Code()}})
+	governor := deployContract(t, backend, key, testGovernorCode())
+
+	var calls []Multicall3Call
+	for _, proposalID := range proposalIDs(5, revertingProposalID, 2) {
+		callData, err := packProposalStateCall(proposalID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls = append(calls, Multicall3Call{Target: governor, AllowFailure: true, CallData: callData})
+	}
+	results, err := Aggregate3(context.Background(), backend.Client(), Multicall3Address, calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Success || results[1].Success || !results[2].Success {
+		t.Fatalf("unexpected success flags: %v %v %v", results[0].Success, results[1].Success, results[2].Success)
+	}
+	for i, expected := range []dbmodels.ProposalState{dbmodels.ProposalStateQueued, "", dbmodels.ProposalStateCanceled} {
+		if expected == "" {
+			continue
+		}
+		if state, err := unpackProposalState(results[i].ReturnData); err != nil || state != expected {
+			t.Errorf("result %d: got state %s (%v), expected %s", i, state, err, expected)
+		}
+	}
+
+	// without allowFailure the reverted call reverts the whole batch
+	calls[1].AllowFailure = false
+	if _, err := Aggregate3(context.Background(), backend.Client(), Multicall3Address, calls); err == nil {
+		t.Fatal("expected aggregate3 to fail when a call without allowFailure reverts")
+	}
+}
+
+func TestGetProposalStatesWithoutMulticall(t *testing.T) {
+	backend, key := newTestChain(t, nil)
+	governor := deployContract(t, backend, key, testGovernorCode())
+
+	contract := NewGovernorContract(backend.Client())
+	ids := []uint64{3, revertingProposalID, 5}
+	results, err := contract.GetProposalStates(context.Background(), governor.Hex(), proposalIDs(ids...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contract.hasMulticall == nil || *contract.hasMulticall {
+		t.Fatal("expected the per-call path on a chain without Multicall3")
+	}
+	checkProposalStates(t, ids, results)
+}
+
+func TestGetProposalStatesFailingMulticallFallsBack(t *testing.T) {
+	// a Multicall3 address whose code always reverts
+	revert := program.New().Push(0).Push(0).Op(vm.REVERT).Bytes()
+	backend, key := newTestChain(t, types.GenesisAlloc{Multicall3Address: {Code: revert}})
+	governor := deployContract(t, backend, key, testGovernorCode())
+
+	ids := []uint64{2, revertingProposalID, 6}
+	results, err := NewGovernorContract(backend.Client()).GetProposalStates(context.Background(), governor.Hex(), proposalIDs(ids...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkProposalStates(t, ids, results)
+}
+
+func TestGetProposalStatesKeepsOrderAcrossBatches(t *testing.T) {
+	backend, key := newTestChain(t, types.GenesisAlloc{Multicall3Address: {Code: testMulticall3Code()}})
+	governor := deployContract(t, backend, key, testGovernorCode())
+
+	// more than two batches, in an order which is not sorted
+	ids := make([]uint64, 0, 2*governorStateBatchSize+50)
+	for i := 2*governorStateBatchSize + 50; i > 0; i-- {
+		ids = append(ids, uint64(i*3))
+	}
+	ids[governorStateBatchSize+10] = revertingProposalID
+	results, err := NewGovernorContract(backend.Client()).GetProposalStates(context.Background(), governor.Hex(), proposalIDs(ids...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkProposalStates(t, ids, results)
+}
@@ -3,6 +3,7 @@ package internal
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/machinebox/graphql"
@@ -179,7 +180,7 @@ func (d *DegovIndexer) InspectProposal(proposalId string) (*Proposal, error) {
 }
 
 // QueryProposalsOffset executes the QueryProposalsOffset GraphQL query and returns proposals list
-func (d *DegovIndexer) QueryProposalsOffset(offset int) ([]Proposal, error) {
+func (d *DegovIndexer) QueryProposalsOffset(ctx context.Context, offset int) ([]Proposal, error) {
 	query := `
 		query QueryProposalsOffset($limit: Int!, $offset: Int!) {
 			proposals(orderBy: blockNumber_ASC_NULLS_FIRST, limit: $limit, offset: $offset) {
@@ -214,7 +215,7 @@ func (d *DegovIndexer) QueryProposalsOffset(offset int) ([]Proposal, error) {
 	req.Var("limit", 30)
 	req.Var("offset", offset)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	var response ProposalsResponse
@@ -290,9 +291,14 @@ func (d *DegovIndexer) QueryVote(id string) (*VoteCast, error) {
 }
 
 func (d *DegovIndexer) QueryVoteByVoter(proposalId string, voter string) (*VoteCast, error) {
+	return d.QueryVoteByVoters(proposalId, []string{voter})
+}
+
+// QueryVoteByVoters returns the earliest vote cast on the proposal by any of the voters, e.g. the linked addresses of a user
+func (d *DegovIndexer) QueryVoteByVoters(proposalId string, voters []string) (*VoteCast, error) {
 	query := `
-		query QueryVoteByVoter($proposalId: String!, $voter: String!) {
-			voteCasts(where: {proposalId_eq: $proposalId, voter_eq: $voter}) {
+		query QueryVoteByVoters($proposalId: String!, $voters: [String!]!) {
+			voteCasts(where: {proposalId_eq: $proposalId, voter_in: $voters}, orderBy: blockNumber_ASC_NULLS_FIRST, limit: 1) {
 				proposalId
 				reason
 				support
@@ -308,23 +314,58 @@ func (d *DegovIndexer) QueryVoteByVoter(proposalId string, voter string) (*VoteC
 
 	req := graphql.NewRequest(query)
 	req.Var("proposalId", proposalId)
-	req.Var("voter", voter)
+	req.Var("voters", voters)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	var response VoteCastsResponse
 	if err := d.client.Run(ctx, req, &response); err != nil {
-		return nil, fmt.Errorf("failed to execute QueryVoteByVoter: %w", err)
+		return nil, fmt.Errorf("failed to execute QueryVoteByVoters: %w", err)
 	}
 	if len(response.VoteCasts) > 0 {
 		return &response.VoteCasts[0], nil
 	}
 
-	return nil, fmt.Errorf("no vote found for proposalId %s and voter %s", proposalId, voter)
+	return nil, fmt.Errorf("no vote found for proposalId %s and voters %s", proposalId, strings.Join(voters, ","))
+}
+
+// QueryVotedVoters returns the voters among the given ones which have voted on the proposal
+func (d *DegovIndexer) QueryVotedVoters(proposalId string, voters []string) ([]string, error) {
+	query := `
+		query QueryVotedVoters($proposalId: String!, $voters: [String!]!, $limit: Int!) {
+			voteCasts(where: {proposalId_eq: $proposalId, voter_in: $voters}, limit: $limit) {
+				voter
+			}
+		}
+	`
+	if len(voters) == 0 {
+		return nil, nil
+	}
+
+	req := graphql.NewRequest(query)
+	req.Var("proposalId", proposalId)
+	req.Var("voters", voters)
+	// a voter may cast more than one vote on some governors
+	req.Var("limit", len(voters)*2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var response VoteCastsResponse
+	if err := d.client.Run(ctx, req, &response); err != nil {
+		return nil, fmt.Errorf("failed to execute QueryVotedVoters: %w", err)
+	}
+
+	votedVoters := make([]string, 0, len(response.VoteCasts))
+	for _, vote := range response.VoteCasts {
+		votedVoters = append(votedVoters, vote.Voter)
+	}
+	return votedVoters, nil
 }
 
-func (d *DegovIndexer) QueryExpiringProposals() ([]Proposal, error) {
+// QueryExpiringProposals returns the proposals whose voting ends within the window
+func (d *DegovIndexer) QueryExpiringProposals(window time.Duration) ([]Proposal, error) {
 	query := `
 	query QueryExpiringProposals($limit: Int!, $offset: Int!, $start: BigInt!, $end: BigInt!) {
 	  proposals(
@@ -366,7 +407,7 @@ func (d *DegovIndexer) QueryExpiringProposals() ([]Proposal, error) {
 
 	now := time.Now()
 	startTimestamp := now.UnixMilli()
-	endTimestamp := now.Add(2 * 24 * 60 * time.Minute).UnixMilli()
+	endTimestamp := now.Add(window).UnixMilli()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
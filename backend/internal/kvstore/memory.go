@@ -0,0 +1,55 @@
+package kvstore
+
+import (
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// MemoryStore keeps the values in process, it is only suitable for a single replica
+type MemoryStore struct {
+	mu    sync.Mutex
+	cache *cache.Cache
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		cache: cache.New(5*time.Minute, 10*time.Minute),
+	}
+}
+
+func (s *MemoryStore) Set(key string, value string, ttl time.Duration) error {
+	s.cache.Set(key, value, ttl)
+	return nil
+}
+
+func (s *MemoryStore) SetNX(key string, value string, ttl time.Duration) (bool, error) {
+	// Add fails when the key exists and is not expired
+	return s.cache.Add(key, value, ttl) == nil, nil
+}
+
+func (s *MemoryStore) Get(key string) (string, error) {
+	value, found := s.cache.Get(key)
+	if !found {
+		return "", ErrNotFound
+	}
+	return value.(string), nil
+}
+
+func (s *MemoryStore) Take(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, err := s.Get(key)
+	if err != nil {
+		return "", err
+	}
+	s.cache.Delete(key)
+	return value, nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.cache.Delete(key)
+	return nil
+}
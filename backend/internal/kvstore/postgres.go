@@ -0,0 +1,103 @@
+package kvstore
+
+import (
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/ringecosystem/degov-apps/database"
+	"gorm.io/gorm"
+)
+
+// postgresCleanupInterval is how often expired rows are removed
+const postgresCleanupInterval = 10 * time.Minute
+
+// PostgresStore keeps the values in dgv_kv_store, so that no extra infrastructure is required to run multiple replicas
+type PostgresStore struct {
+	db          *gorm.DB
+	lastCleanup atomic.Int64
+}
+
+func NewPostgresStore() *PostgresStore {
+	return &PostgresStore{
+		db: database.GetDB(),
+	}
+}
+
+func (s *PostgresStore) Set(key string, value string, ttl time.Duration) error {
+	s.cleanupExpired()
+	now := time.Now()
+	err := s.db.Exec(`
+		insert into dgv_kv_store (key, value, time_expire, ctime)
+		values (?, ?, ?, ?)
+		on conflict (key) do update set value = excluded.value, time_expire = excluded.time_expire, ctime = excluded.ctime`,
+		key, value, now.Add(ttl), now,
+	).Error
+	if err != nil {
+		return fmt.Errorf("kvstore: failed to set %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) SetNX(key string, value string, ttl time.Duration) (bool, error) {
+	s.cleanupExpired()
+	now := time.Now()
+	// an expired row is the same as a missing one, it is replaced
+	result := s.db.Exec(`
+		insert into dgv_kv_store (key, value, time_expire, ctime)
+		values (?, ?, ?, ?)
+		on conflict (key) do update set value = excluded.value, time_expire = excluded.time_expire, ctime = excluded.ctime
+		where dgv_kv_store.time_expire <= ?`,
+		key, value, now.Add(ttl), now, now,
+	)
+	if result.Error != nil {
+		return false, fmt.Errorf("kvstore: failed to set %s: %w", key, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (s *PostgresStore) Get(key string) (string, error) {
+	var values []string
+	err := s.db.Raw(`select value from dgv_kv_store where key = ? and time_expire > ?`, key, time.Now()).
+		Scan(&values).Error
+	if err != nil {
+		return "", fmt.Errorf("kvstore: failed to get %s: %w", key, err)
+	}
+	if len(values) == 0 {
+		return "", ErrNotFound
+	}
+	return values[0], nil
+}
+
+func (s *PostgresStore) Take(key string) (string, error) {
+	var values []string
+	err := s.db.Raw(`delete from dgv_kv_store where key = ? and time_expire > ? returning value`, key, time.Now()).
+		Scan(&values).Error
+	if err != nil {
+		return "", fmt.Errorf("kvstore: failed to take %s: %w", key, err)
+	}
+	if len(values) == 0 {
+		return "", ErrNotFound
+	}
+	return values[0], nil
+}
+
+func (s *PostgresStore) Delete(key string) error {
+	if err := s.db.Exec(`delete from dgv_kv_store where key = ?`, key).Error; err != nil {
+		return fmt.Errorf("kvstore: failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// cleanupExpired removes the expired rows at most once per postgresCleanupInterval
+func (s *PostgresStore) cleanupExpired() {
+	now := time.Now()
+	last := s.lastCleanup.Load()
+	if now.Sub(time.Unix(0, last)) < postgresCleanupInterval || !s.lastCleanup.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	if err := s.db.Exec(`delete from dgv_kv_store where time_expire <= ?`, now).Error; err != nil {
+		slog.Warn("Failed to cleanup expired kv store entries", "error", err)
+	}
+}
@@ -0,0 +1,52 @@
+package kvstore
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ringecosystem/degov-apps/internal/config"
+)
+
+// ErrNotFound is returned by Get and Take when the key does not exist or has expired
+var ErrNotFound = errors.New("kvstore: key not found")
+
+// Store keeps short lived values such as login nonces, otp codes and rate limit markers.
+// Every value expires after its ttl. The store must be shared by all replicas of the api
+// for nonces and otp codes to be verified on a replica other than the one which issued them
+type Store interface {
+	// Set stores the value, replacing any existing one
+	Set(key string, value string, ttl time.Duration) error
+	// SetNX stores the value only if the key does not exist, it reports whether the value was stored
+	SetNX(key string, value string, ttl time.Duration) (bool, error)
+	// Get returns the value, or ErrNotFound
+	Get(key string) (string, error)
+	// Take returns and deletes the value atomically, so that one-time values can be consumed only once
+	Take(key string) (string, error)
+	Delete(key string) error
+}
+
+var (
+	globalStore Store
+	storeOnce   sync.Once
+)
+
+// GetStore returns the store selected by KV_STORE (postgres, memory)
+func GetStore() Store {
+	storeOnce.Do(func() {
+		kind := strings.ToLower(config.GetString("KV_STORE"))
+		switch kind {
+		case "memory":
+			slog.Warn("Using the in-memory kv store, nonces and otp codes are not shared between replicas")
+			globalStore = NewMemoryStore()
+		case "postgres", "":
+			globalStore = NewPostgresStore()
+		default:
+			slog.Warn("Unknown kv store, fallback to postgres", "kv_store", kind)
+			globalStore = NewPostgresStore()
+		}
+	})
+	return globalStore
+}
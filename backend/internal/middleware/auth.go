@@ -3,17 +3,20 @@ package middleware
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/ringecosystem/degov-apps/internal/config"
+	"github.com/ringecosystem/degov-apps/services"
 	"github.com/ringecosystem/degov-apps/types"
 )
 
 // AuthClaims represents the JWT claims structure
 type AuthClaims struct {
-	User *types.UserSessInfo `json:"user"`
+	User      *types.UserSessInfo `json:"user"`
+	SessionID string              `json:"sid"`
 	jwt.RegisteredClaims
 }
 
@@ -23,18 +26,22 @@ type ContextKey string
 const (
 	// UserClaimsKey is the context key for user claims
 	UserClaimsKey ContextKey = "user_claims"
+	// TokenErrorKey is the context key for the reason a presented token was not accepted
+	TokenErrorKey ContextKey = "token_error"
 )
 
 // AuthMiddleware provides JWT authentication middleware
 type AuthMiddleware struct {
-	jwtSecret []byte
+	jwtSecret      []byte
+	sessionService *services.UserSessionService
 }
 
 // NewAuthMiddleware creates a new authentication middleware
 func NewAuthMiddleware() *AuthMiddleware {
 	secretKey := config.GetStringRequired("JWT_SECRET")
 	return &AuthMiddleware{
-		jwtSecret: []byte(secretKey),
+		jwtSecret:      []byte(secretKey),
+		sessionService: services.NewUserSessionService(),
 	}
 }
 
@@ -65,12 +72,13 @@ func (m *AuthMiddleware) HTTPMiddleware(next http.Handler) http.Handler {
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
-		// Parse and validate token
+		// Parse and validate token, a token which is present but invalid (expired, revoked) is treated as anonymous
+		// so that refreshToken and public fields keep working. Fields which require authentication report
+		// UNAUTHENTICATED with the reason, so clients know they have to refresh the token
 		claims, err := m.validateToken(tokenString)
 		if err != nil {
-			// Invalid token, continue without authentication
-			// You might want to return an error here depending on your requirements
-			next.ServeHTTP(w, r)
+			slog.Debug("Invalid access token, continuing without authentication", "error", err)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), TokenErrorKey, err)))
 			return
 		}
 
@@ -105,6 +113,18 @@ func (m *AuthMiddleware) validateToken(tokenString string) (*AuthClaims, error)
 		return nil, fmt.Errorf("failed to parse claims")
 	}
 
+	// tokens are bound to a session, which is revoked by logout
+	if claims.SessionID == "" {
+		return nil, fmt.Errorf("token has no session")
+	}
+	active, err := m.sessionService.IsActive(claims.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check session: %v", err)
+	}
+	if !active {
+		return nil, fmt.Errorf("session is revoked or expired")
+	}
+
 	return claims, nil
 }
 
@@ -118,6 +138,9 @@ func GetUserFromContext(ctx context.Context) (*AuthClaims, bool) {
 func RequireAuth(ctx context.Context) (*AuthClaims, error) {
 	claims, ok := GetUserFromContext(ctx)
 	if !ok || claims == nil {
+		if tokenErr, ok := ctx.Value(TokenErrorKey).(error); ok {
+			return nil, fmt.Errorf("invalid token: %v", tokenErr)
+		}
 		return nil, fmt.Errorf("authentication required")
 	}
 	return claims, nil
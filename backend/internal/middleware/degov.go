@@ -3,12 +3,12 @@ package middleware
 import (
 	"context"
 	"net/http"
-	"net/url"
 	"strings"
 	"time"
 
 	"github.com/patrickmn/go-cache"
 	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	"github.com/ringecosystem/degov-apps/internal/utils"
 	"github.com/ringecosystem/degov-apps/services"
 	"github.com/ringecosystem/degov-apps/types"
 )
@@ -47,27 +47,32 @@ func (m *DegovMiddleware) HTTPMiddleware(next http.Handler) http.Handler {
 		siteHeader := r.Header.Get("x-degov-site")
 		daocodeHeader := r.Header.Get("x-degov-daocode")
 
-		var daoCode string
-		var originHost string
+		daoCode, originHost := m.findDaoCodeByURL(siteHeader, originHeader, refererHeader)
 
 		// if daocodeHeader no empty, use it
 		if daocodeHeader != "" {
 			daoCode = daocodeHeader
-		} else {
-			extractedDaoCode, extractedOriginHost := m.findDaoCodeByURL(siteHeader, originHeader, refererHeader)
-			daoCode = extractedDaoCode
-			originHost = extractedOriginHost
 		}
 
+		ctx := r.Context()
 		if daoCode != "" {
-			ctx := context.WithValue(r.Context(), DegovDaocodeKey, daoCode)
+			ctx = context.WithValue(ctx, DegovDaocodeKey, daoCode)
+		}
+		// the site is kept even when it is not a known dao, login validates it against the signed message
+		if originHost != "" {
 			ctx = context.WithValue(ctx, DegovDaositeKey, originHost)
-			r = r.WithContext(ctx)
 		}
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// GetDegovOrigin returns the dao code and the site host of the request, both may be empty
+func GetDegovOrigin(ctx context.Context) (string, string) {
+	daoCode, _ := ctx.Value(DegovDaocodeKey).(string)
+	site, _ := ctx.Value(DegovDaositeKey).(string)
+	return daoCode, site
+}
+
 // try to find the DAO code based on the origin or referer headers
 func (m *DegovMiddleware) findDaoCodeByURL(customSite, origin, referer string) (string, string) {
 	// use origin if available, otherwise use referer
@@ -83,7 +88,7 @@ func (m *DegovMiddleware) findDaoCodeByURL(customSite, origin, referer string) (
 		return "", ""
 	}
 
-	targetHost := m.extractHost(targetURL)
+	targetHost := utils.ExtractHost(targetURL)
 	if targetHost == "" {
 		return "", ""
 	}
@@ -106,25 +111,6 @@ func (m *DegovMiddleware) findDaoCodeByURL(customSite, origin, referer string) (
 	return "", targetHost
 }
 
-// extractHost extracts the host from a given URL string
-func (m *DegovMiddleware) extractHost(rawURL string) string {
-	if rawURL == "" {
-		return ""
-	}
-
-	// if url does not contain a protocol, add default https
-	if !strings.Contains(rawURL, "://") {
-		rawURL = "https://" + rawURL
-	}
-
-	parsedURL, err := url.Parse(rawURL)
-	if err != nil {
-		return ""
-	}
-
-	return parsedURL.Host
-}
-
 // from cache, if not found, fetch from database and cache
 func (m *DegovMiddleware) getDaosFromCache() []DaoEndpoint {
 	// try to get from cache
@@ -149,7 +135,7 @@ func (m *DegovMiddleware) getDaosFromCache() []DaoEndpoint {
 	for _, gqlDao := range gqlDaos {
 		dao := DaoEndpoint{
 			Code:     gqlDao.Code,
-			Endpoint: m.extractHost(gqlDao.Endpoint),
+			Endpoint: utils.ExtractHost(gqlDao.Endpoint),
 		}
 		daos = append(daos, dao)
 	}
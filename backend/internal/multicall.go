@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Multicall3Address is the address Multicall3 is deployed at on most chains.
+// https://github.com/mds1/multicall3
+var Multicall3Address = common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11")
+
+const multicall3ABIJSON = `[{
+	"inputs": [{
+		"components": [
+			{"internalType": "address", "name": "target", "type": "address"},
+			{"internalType": "bool", "name": "allowFailure", "type": "bool"},
+			{"internalType": "bytes", "name": "callData", "type": "bytes"}
+		],
+		"internalType": "struct Multicall3.Call3[]",
+		"name": "calls",
+		"type": "tuple[]"
+	}],
+	"name": "aggregate3",
+	"outputs": [{
+		"components": [
+			{"internalType": "bool", "name": "success", "type": "bool"},
+			{"internalType": "bytes", "name": "returnData", "type": "bytes"}
+		],
+		"internalType": "struct Multicall3.Result[]",
+		"name": "returnData",
+		"type": "tuple[]"
+	}],
+	"stateMutability": "payable",
+	"type": "function"
+}]`
+
+var multicall3ABI = mustParseABI(multicall3ABIJSON)
+
+// Multicall3Call is the Call3 struct of aggregate3, a failing call reverts the whole batch unless AllowFailure is set
+type Multicall3Call struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+// Multicall3Result is the Result struct of aggregate3
+type Multicall3Result struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// Aggregate3 executes the calls in a single eth_call through the Multicall3 contract at the address
+func Aggregate3(ctx context.Context, backend ContractBackend, multicall common.Address, calls []Multicall3Call) ([]Multicall3Result, error) {
+	callData, err := multicall3ABI.Pack("aggregate3", calls)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack aggregate3 call: %w", err)
+	}
+
+	output, err := backend.CallContract(ctx, ethereum.CallMsg{
+		To:   &multicall,
+		Data: callData,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call aggregate3: %w", err)
+	}
+
+	var results []Multicall3Result
+	if err := multicall3ABI.UnpackIntoInterface(&results, "aggregate3", output); err != nil {
+		return nil, fmt.Errorf("failed to unpack aggregate3 result: %w", err)
+	}
+	if len(results) != len(calls) {
+		return nil, fmt.Errorf("aggregate3 returned %d results for %d calls", len(results), len(calls))
+	}
+	return results, nil
+}
+
+// mustParseABI parses an abi known at compile time, it panics on invalid json
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(fmt.Sprintf("invalid abi: %v", err))
+	}
+	return parsed
+}
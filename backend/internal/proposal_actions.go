@@ -0,0 +1,237 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ProposalAction is one call of a proposal as emitted by ProposalCreated
+type ProposalAction struct {
+	Target common.Address
+	Value  *big.Int
+	// Signature is only set by governors which emit the function signature apart from the calldata
+	Signature string
+	// Calldata always starts with the function selector, it is prepended when the governor emits a signature
+	Calldata []byte
+}
+
+// QueryProposalActions reads the actions of the proposal from its ProposalCreated log, the block number is the
+// block the proposal was created in
+func QueryProposalActions(ctx context.Context, backend ChainLogBackend, governor common.Address, proposalID string, blockNumber uint64) ([]ProposalAction, error) {
+	id, err := parseProposalID(proposalID)
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := backend.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(blockNumber),
+		ToBlock:   new(big.Int).SetUint64(blockNumber),
+		Addresses: []common.Address{governor},
+		Topics:    [][]common.Hash{{governorEventsABI.Events["ProposalCreated"].ID}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ProposalCreated logs of block %d: %w", blockNumber, err)
+	}
+
+	for _, log := range logs {
+		values := map[string]interface{}{}
+		if err := governorEventsABI.UnpackIntoMap(values, "ProposalCreated", log.Data); err != nil {
+			return nil, fmt.Errorf("failed to decode ProposalCreated log: %w", err)
+		}
+		if eventID, _ := values["proposalId"].(*big.Int); eventID == nil || eventID.Cmp(id) != 0 {
+			continue
+		}
+		targets, _ := values["targets"].([]common.Address)
+		amounts, _ := values["values"].([]*big.Int)
+		signatures, _ := values["signatures"].([]string)
+		calldatas, _ := values["calldatas"].([][]byte)
+		if len(amounts) != len(targets) || len(calldatas) != len(targets) {
+			return nil, fmt.Errorf("ProposalCreated of proposal %s has mismatching action arrays", proposalID)
+		}
+
+		actions := make([]ProposalAction, 0, len(targets))
+		for i, target := range targets {
+			action := ProposalAction{
+				Target:   target,
+				Value:    amounts[i],
+				Calldata: calldatas[i],
+			}
+			if i < len(signatures) && signatures[i] != "" {
+				action.Signature = signatures[i]
+				action.Calldata = append(crypto.Keccak256([]byte(action.Signature))[:4], calldatas[i]...)
+			}
+			actions = append(actions, action)
+		}
+		return actions, nil
+	}
+	return nil, fmt.Errorf("no ProposalCreated log of proposal %s in block %d", proposalID, blockNumber)
+}
+
+const erc20MetadataABIJSON = `[
+	{"inputs":[],"name":"symbol","outputs":[{"name":"","type":"string"}],"stateMutability":"view","type":"function"},
+	{"inputs":[],"name":"decimals","outputs":[{"name":"","type":"uint8"}],"stateMutability":"view","type":"function"}
+]`
+
+var erc20MetadataABI = mustParseABI(erc20MetadataABIJSON)
+
+// TokenMetadata is the symbol and decimals of an ERC20 token
+type TokenMetadata struct {
+	Symbol   string
+	Decimals int
+}
+
+// QueryTokenMetadata reads the symbol and decimals of the token, tokens which return a bytes32 symbol are not supported
+func QueryTokenMetadata(ctx context.Context, backend ContractBackend, token common.Address) (*TokenMetadata, error) {
+	var metadata TokenMetadata
+	for _, method := range []string{"symbol", "decimals"} {
+		callData, err := erc20MetadataABI.Pack(method)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack function call data: %w", err)
+		}
+		result, err := backend.CallContract(ctx, ethereum.CallMsg{To: &token, Data: callData}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to call %s of %s: %w", method, token.Hex(), err)
+		}
+		values, err := erc20MetadataABI.Unpack(method, result)
+		if err != nil || len(values) != 1 {
+			return nil, fmt.Errorf("failed to unpack %s of %s: %v", method, token.Hex(), err)
+		}
+		switch value := values[0].(type) {
+		case string:
+			metadata.Symbol = value
+		case uint8:
+			metadata.Decimals = int(value)
+		}
+	}
+	return &metadata, nil
+}
+
+// knownFunctionSignatures are common governance calls, decoded without asking the signature database
+var knownFunctionSignatures = []string{
+	"transfer(address,uint256)",
+	"transferFrom(address,address,uint256)",
+	"approve(address,uint256)",
+	"mint(address,uint256)",
+	"burn(uint256)",
+	"grantRole(bytes32,address)",
+	"revokeRole(bytes32,address)",
+	"transferOwnership(address)",
+	"upgradeTo(address)",
+	"upgradeToAndCall(address,bytes)",
+	"upgrade(address,address)",
+	"changeAdmin(address)",
+	"updateDelay(uint256)",
+	"setVotingDelay(uint256)",
+	"setVotingPeriod(uint256)",
+	"setProposalThreshold(uint256)",
+	"updateQuorumNumerator(uint256)",
+	"updateTimelock(address)",
+	"safeTransferFrom(address,address,uint256)",
+}
+
+var knownFunctionsBySelector = func() map[string][]string {
+	bySelector := make(map[string][]string, len(knownFunctionSignatures))
+	for _, signature := range knownFunctionSignatures {
+		selector := common.Bytes2Hex(crypto.Keccak256([]byte(signature))[:4])
+		bySelector[selector] = append(bySelector[selector], signature)
+	}
+	return bySelector
+}()
+
+// SignatureDatabase looks up the text signatures of a 4-byte function selector, such as 4byte.directory
+type SignatureDatabase struct {
+	endpoint string
+	client   *http.Client
+}
+
+func NewSignatureDatabase(endpoint string) *SignatureDatabase {
+	return &SignatureDatabase{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// LookupFunction returns the candidate signatures of the selector, the known governance calls first,
+// then the signatures of the database from the oldest, which are the least likely to be collisions
+func (d *SignatureDatabase) LookupFunction(ctx context.Context, selector []byte) ([]string, error) {
+	if len(selector) < 4 {
+		return nil, fmt.Errorf("selector must be 4 bytes")
+	}
+	hexSelector := common.Bytes2Hex(selector[:4])
+	signatures := append([]string(nil), knownFunctionsBySelector[hexSelector]...)
+	if d.endpoint == "" {
+		return signatures, nil
+	}
+
+	query := url.Values{}
+	query.Set("hex_signature", "0x"+hexSelector)
+	query.Set("ordering", "created_at")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.endpoint+"/api/v1/signatures/?"+query.Encode(), nil)
+	if err != nil {
+		return signatures, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return signatures, fmt.Errorf("failed to query signature database: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return signatures, fmt.Errorf("signature database returned status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Results []struct {
+			TextSignature string `json:"text_signature"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return signatures, fmt.Errorf("failed to decode signature database response: %w", err)
+	}
+	for _, result := range response.Results {
+		known := false
+		for _, signature := range signatures {
+			known = known || signature == result.TextSignature
+		}
+		if !known {
+			signatures = append(signatures, result.TextSignature)
+		}
+	}
+	return signatures, nil
+}
+
+// MethodFromSignature builds the abi method of a text signature such as "transfer(address,uint256)".
+// Parameter names are unknown, tuple parameters are not supported
+func MethodFromSignature(signature string) (abi.Method, error) {
+	open := strings.Index(signature, "(")
+	if open <= 0 || !strings.HasSuffix(signature, ")") {
+		return abi.Method{}, fmt.Errorf("invalid function signature %q", signature)
+	}
+	name := signature[:open]
+	params := signature[open+1 : len(signature)-1]
+	if strings.Contains(params, "(") {
+		return abi.Method{}, fmt.Errorf("tuple parameters are not supported: %q", signature)
+	}
+
+	var inputs abi.Arguments
+	if params != "" {
+		for _, param := range strings.Split(params, ",") {
+			paramType, err := abi.NewType(strings.TrimSpace(param), "", nil)
+			if err != nil {
+				return abi.Method{}, fmt.Errorf("invalid parameter type %q: %w", param, err)
+			}
+			inputs = append(inputs, abi.Argument{Type: paramType})
+		}
+	}
+	return abi.NewMethod(name, name, abi.Function, "nonpayable", false, false, inputs, nil), nil
+}
@@ -0,0 +1,418 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/ringecosystem/degov-apps/internal/config"
+)
+
+const (
+	// rpcPoolMaxAttempts is the number of endpoints tried by one call before giving up
+	rpcPoolMaxAttempts = 3
+	// rpcPoolAttemptTimeout bounds a single attempt so that a hanging node does not use up the whole call
+	rpcPoolAttemptTimeout = 10 * time.Second
+	// rpcPoolEWMAAlpha is the weight of the latest sample in the latency and error rate averages
+	rpcPoolEWMAAlpha = 0.3
+	// rpcPoolErrorPenalty is the latency an error rate of 1 is worth when ranking endpoints
+	rpcPoolErrorPenalty = 10 * time.Second
+	rpcPoolMinBackoff   = 5 * time.Second
+	rpcPoolMaxBackoff   = 5 * time.Minute
+)
+
+var (
+	rpcPools   = make(map[int]*RPCPool)
+	rpcPoolsMu sync.Mutex
+)
+
+// RPCPool spreads the calls of one chain over all of its rpc endpoints. Endpoints are ranked by latency and
+// error rate, a failing endpoint is put in exponential backoff and the call is retried on the next one.
+// It implements ContractBackend, so contract helpers fail over transparently
+type RPCPool struct {
+	chainID   int
+	mu        sync.Mutex
+	endpoints []*rpcEndpoint
+}
+
+type rpcEndpoint struct {
+	url                 string
+	client              *ethclient.Client
+	latency             time.Duration
+	errorRate           float64
+	requests            uint64
+	failures            uint64
+	consecutiveFailures int
+	backoffUntil        time.Time
+	lastError           string
+	lastUsed            time.Time
+}
+
+// GetRPCPool returns the pool of the chain. The endpoints are the operator override list RPC_URL_<chainId>
+// (comma separated) first, then the given rpc urls, e.g. of the dao config, then the public fallbacks of the chain.
+// urls not known yet are added to an existing pool
+func GetRPCPool(chainID int, rpcURLs []string) *RPCPool {
+	rpcPoolsMu.Lock()
+	defer rpcPoolsMu.Unlock()
+
+	pool, ok := rpcPools[chainID]
+	if !ok {
+		pool = &RPCPool{chainID: chainID}
+		pool.add(strings.Split(config.GetString(fmt.Sprintf("RPC_URL_%d", chainID)), ","))
+		pool.add(rpcURLs)
+		pool.add(defaultRPCURLs(chainID))
+		rpcPools[chainID] = pool
+		return pool
+	}
+	pool.add(rpcURLs)
+	return pool
+}
+
+func (p *RPCPool) add(rpcURLs []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, rpcURL := range rpcURLs {
+		rpcURL = strings.TrimSpace(rpcURL)
+		if rpcURL == "" {
+			continue
+		}
+		known := false
+		for _, endpoint := range p.endpoints {
+			if endpoint.url == rpcURL {
+				known = true
+				break
+			}
+		}
+		if !known {
+			p.endpoints = append(p.endpoints, &rpcEndpoint{url: rpcURL})
+		}
+	}
+}
+
+// Size returns the number of endpoints of the pool
+func (p *RPCPool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.endpoints)
+}
+
+// Call runs fn on the best endpoint, rotating to the next one when the endpoint fails.
+// Errors which are not caused by the endpoint, such as a reverted call, are returned at once
+func (p *RPCPool) Call(ctx context.Context, fn func(ctx context.Context, client *ethclient.Client) error) error {
+	candidates := p.candidates()
+	if len(candidates) == 0 {
+		return fmt.Errorf("no rpc endpoint available for chain %d", p.chainID)
+	}
+
+	var lastErr error
+	for attempt, endpoint := range candidates {
+		if attempt >= rpcPoolMaxAttempts {
+			break
+		}
+		client, err := p.client(ctx, endpoint)
+		if err == nil {
+			attemptCtx, cancel := context.WithTimeout(ctx, rpcPoolAttemptTimeout)
+			start := time.Now()
+			err = fn(attemptCtx, client)
+			cancel()
+			if err == nil || !isRPCEndpointFailure(ctx, err) {
+				p.recordSuccess(endpoint, time.Since(start))
+				return err
+			}
+		}
+		lastErr = err
+		p.recordFailure(endpoint, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return fmt.Errorf("rpc call on chain %d failed: %s", p.chainID, redactRPCError(lastErr))
+}
+
+func (p *RPCPool) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	var result []byte
+	err := p.Call(ctx, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		result, err = client.CallContract(ctx, msg, blockNumber)
+		return err
+	})
+	return result, err
+}
+
+func (p *RPCPool) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	var result []byte
+	err := p.Call(ctx, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		result, err = client.CodeAt(ctx, account, blockNumber)
+		return err
+	})
+	return result, err
+}
+
+func (p *RPCPool) BlockNumber(ctx context.Context) (uint64, error) {
+	var result uint64
+	err := p.Call(ctx, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		result, err = client.BlockNumber(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (p *RPCPool) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	var result *types.Header
+	err := p.Call(ctx, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		result, err = client.HeaderByNumber(ctx, number)
+		return err
+	})
+	return result, err
+}
+
+func (p *RPCPool) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	var result []types.Log
+	err := p.Call(ctx, func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		result, err = client.FilterLogs(ctx, query)
+		return err
+	})
+	return result, err
+}
+
+// candidates orders the endpoints to try: the ones not in backoff by score, then the ones whose backoff ends first
+func (p *RPCPool) candidates() []*rpcEndpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var available, backedOff []*rpcEndpoint
+	for _, endpoint := range p.endpoints {
+		if endpoint.backoffUntil.After(now) {
+			backedOff = append(backedOff, endpoint)
+		} else {
+			available = append(available, endpoint)
+		}
+	}
+	sort.SliceStable(available, func(i, j int) bool {
+		return available[i].score() < available[j].score()
+	})
+	sort.SliceStable(backedOff, func(i, j int) bool {
+		return backedOff[i].backoffUntil.Before(backedOff[j].backoffUntil)
+	})
+	return append(available, backedOff...)
+}
+
+func (p *RPCPool) client(ctx context.Context, endpoint *rpcEndpoint) (*ethclient.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if endpoint.client == nil {
+		client, err := ethclient.DialContext(ctx, endpoint.url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+		endpoint.client = client
+	}
+	return endpoint.client, nil
+}
+
+func (p *RPCPool) recordSuccess(endpoint *rpcEndpoint, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	endpoint.requests++
+	endpoint.lastUsed = time.Now()
+	if endpoint.latency == 0 {
+		endpoint.latency = latency
+	} else {
+		endpoint.latency = time.Duration(rpcPoolEWMAAlpha*float64(latency) + (1-rpcPoolEWMAAlpha)*float64(endpoint.latency))
+	}
+	endpoint.errorRate *= 1 - rpcPoolEWMAAlpha
+	endpoint.consecutiveFailures = 0
+	endpoint.backoffUntil = time.Time{}
+}
+
+func (p *RPCPool) recordFailure(endpoint *rpcEndpoint, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	endpoint.requests++
+	endpoint.failures++
+	endpoint.lastUsed = time.Now()
+	endpoint.errorRate = rpcPoolEWMAAlpha + (1-rpcPoolEWMAAlpha)*endpoint.errorRate
+	endpoint.consecutiveFailures++
+	backoff := rpcPoolMinBackoff << min(endpoint.consecutiveFailures-1, 16)
+	endpoint.backoffUntil = time.Now().Add(min(backoff, rpcPoolMaxBackoff))
+	endpoint.lastError = redactRPCError(err)
+}
+
+func (e *rpcEndpoint) score() time.Duration {
+	return e.latency + time.Duration(e.errorRate*float64(rpcPoolErrorPenalty))
+}
+
+// RPCEndpointHealth is the health of one endpoint, the url is reduced to its host since it may contain an api key
+type RPCEndpointHealth struct {
+	URL                 string
+	Healthy             bool
+	LatencyMs           int64
+	ErrorRate           float64
+	Requests            uint64
+	Failures            uint64
+	ConsecutiveFailures int
+	BackoffUntil        *time.Time
+	LastError           *string
+	LastUsed            *time.Time
+}
+
+type RPCPoolHealth struct {
+	ChainID   int
+	Healthy   bool
+	Endpoints []RPCEndpointHealth
+}
+
+// Health reports the endpoints of the pool, the pool is healthy while any endpoint is not in backoff
+func (p *RPCPool) Health() RPCPoolHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	health := RPCPoolHealth{ChainID: p.chainID}
+	for _, endpoint := range p.endpoints {
+		item := RPCEndpointHealth{
+			URL:                 redactRPCURL(endpoint.url),
+			Healthy:             !endpoint.backoffUntil.After(now),
+			LatencyMs:           endpoint.latency.Milliseconds(),
+			ErrorRate:           endpoint.errorRate,
+			Requests:            endpoint.requests,
+			Failures:            endpoint.failures,
+			ConsecutiveFailures: endpoint.consecutiveFailures,
+		}
+		if !item.Healthy {
+			backoffUntil := endpoint.backoffUntil
+			item.BackoffUntil = &backoffUntil
+		}
+		if endpoint.lastError != "" {
+			lastError := endpoint.lastError
+			item.LastError = &lastError
+		}
+		if !endpoint.lastUsed.IsZero() {
+			lastUsed := endpoint.lastUsed
+			item.LastUsed = &lastUsed
+		}
+		health.Healthy = health.Healthy || item.Healthy
+		health.Endpoints = append(health.Endpoints, item)
+	}
+	return health
+}
+
+// RPCPoolsHealth reports every pool created so far, ordered by chain id
+func RPCPoolsHealth() []RPCPoolHealth {
+	rpcPoolsMu.Lock()
+	pools := make([]*RPCPool, 0, len(rpcPools))
+	for _, pool := range rpcPools {
+		pools = append(pools, pool)
+	}
+	rpcPoolsMu.Unlock()
+
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i].chainID < pools[j].chainID
+	})
+	health := make([]RPCPoolHealth, 0, len(pools))
+	for _, pool := range pools {
+		health = append(health, pool.Health())
+	}
+	return health
+}
+
+// isRPCEndpointFailure tells whether the error is caused by the endpoint, and trying another endpoint may help
+func isRPCEndpointFailure(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		// the caller gave up, it says nothing about the endpoint
+		return false
+	}
+	if isLogRangeError(err) {
+		// the node limits eth_getLogs, the caller has to ask for a smaller range
+		return false
+	}
+	return !isExecutionReverted(err)
+}
+
+// isExecutionReverted reports whether the call was reverted by the contract
+func isExecutionReverted(err error) bool {
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == 3 {
+		return true
+	}
+	return strings.Contains(err.Error(), "execution reverted")
+}
+
+// isLogRangeError reports whether the node rejected an eth_getLogs because of its block range or result size,
+// the wording differs between node implementations and providers
+func isLogRangeError(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, pattern := range []string{"block range", "range too large", "range is too large", "too many results", "more than", "response size", "query timeout exceeded"} {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactRPCURL keeps only the scheme and host of the url, api keys are often part of the path or query
+func redactRPCURL(rpcURL string) string {
+	parsed, err := url.Parse(rpcURL)
+	if err != nil || parsed.Host == "" {
+		return "invalid url"
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+// redactRPCError removes the endpoint urls from the error, the http client includes them in its errors
+func redactRPCError(err error) string {
+	if err == nil {
+		return ""
+	}
+	message := err.Error()
+	for _, field := range strings.Fields(message) {
+		trimmed := strings.Trim(field, `"':,`)
+		if strings.Contains(trimmed, "://") {
+			message = strings.ReplaceAll(message, trimmed, redactRPCURL(trimmed))
+		}
+	}
+	return message
+}
+
+// defaultRPCURLs are the public rpcs used when no rpc of the chain is configured
+func defaultRPCURLs(chainID int) []string {
+	switch chainID {
+	case 1:
+		return []string{"https://ethereum-rpc.publicnode.com", "https://eth.llamarpc.com"}
+	case 46:
+		return []string{"https://rpc.darwinia.network"}
+	case 56:
+		return []string{"https://bsc-dataseed.binance.org"}
+	case 137:
+		return []string{"https://polygon-rpc.com"}
+	case 42161:
+		return []string{"https://arb1.arbitrum.io/rpc"}
+	case 10:
+		return []string{"https://mainnet.optimism.io"}
+	case 43114:
+		return []string{"https://api.avax.network/ext/bc/C/rpc"}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// TelegramMessageMaxLength is the max length of a message accepted by the bot api
+const TelegramMessageMaxLength = 4096
+
+type TelegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *TelegramMessage `json:"message,omitempty"`
+}
+
+type TelegramMessage struct {
+	MessageID int64         `json:"message_id"`
+	From      *TelegramUser `json:"from,omitempty"`
+	Chat      TelegramChat  `json:"chat"`
+	Text      string        `json:"text"`
+}
+
+type TelegramUser struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+type TelegramChat struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type"`
+	Username string `json:"username"`
+}
+
+type telegramResponse struct {
+	Ok          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+}
+
+type TelegramBot struct {
+	BaseURL    string
+	Token      string
+	httpClient *http.Client
+}
+
+// NewTelegramBot creates a bot api client, the baseURL is configurable so that a local fake server can be used
+func NewTelegramBot(baseURL, token string) *TelegramBot {
+	return &TelegramBot{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   token,
+		httpClient: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+	}
+}
+
+// SendMessage sends a html formatted message to the chat and returns the sent message
+func (bot *TelegramBot) SendMessage(chatID string, html string) (*TelegramMessage, error) {
+	var message TelegramMessage
+	if err := bot.call("sendMessage", map[string]interface{}{
+		"chat_id":                  chatID,
+		"text":                     html,
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	}, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// GetUpdates fetches the pending updates of the bot, updates before offset are confirmed and will not be returned again
+func (bot *TelegramBot) GetUpdates(offset int64) ([]TelegramUpdate, error) {
+	var updates []TelegramUpdate
+	if err := bot.call("getUpdates", map[string]interface{}{
+		"offset":          offset,
+		"timeout":         0,
+		"allowed_updates": []string{"message"},
+	}, &updates); err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+func (bot *TelegramBot) call(method string, params map[string]interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("[telegram] failed to marshal %s params: %w", method, err)
+	}
+
+	url := fmt.Sprintf("%s/bot%s/%s", bot.BaseURL, bot.Token, method)
+	resp, err := bot.httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// the url contains the bot token, do not leak it into logs
+		return fmt.Errorf("[telegram] failed to call %s", method)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("[telegram] failed to read %s response: %w", method, err)
+	}
+
+	var telegramResp telegramResponse
+	if err := json.Unmarshal(respBody, &telegramResp); err != nil {
+		return fmt.Errorf("[telegram] failed to unmarshal %s response, status code: %d", method, resp.StatusCode)
+	}
+	if !telegramResp.Ok {
+		return fmt.Errorf("[telegram] %s returned an error: %d %s", method, telegramResp.ErrorCode, telegramResp.Description)
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(telegramResp.Result, result); err != nil {
+			return fmt.Errorf("[telegram] failed to unmarshal %s result: %w", method, err)
+		}
+	}
+	return nil
+}
+
+var (
+	telegramMdHeadingRegex = regexp.MustCompile(`(?m)^#{1,6}[ \t]+(.+?)[ \t]*$`)
+	telegramMdRuleRegex    = regexp.MustCompile(`(?m)^[ \t]*-{3,}[ \t]*$`)
+	telegramMdBulletRegex  = regexp.MustCompile(`(?m)^([ \t]*)[-*][ \t]+`)
+	telegramMdQuoteRegex   = regexp.MustCompile(`(?m)^&gt;[ \t]?`)
+	telegramMdLinkRegex    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	telegramMdBoldRegex    = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	telegramBlankLineRegex = regexp.MustCompile(`\n{3,}`)
+)
+
+// TelegramHTMLFromMarkdown converts the markdown produced by the .md templates into the html subset supported by telegram.
+// https://core.telegram.org/bots/api#html-style
+func TelegramHTMLFromMarkdown(md string) string {
+	text := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(md)
+
+	text = telegramMdHeadingRegex.ReplaceAllString(text, "<b>$1</b>")
+	text = telegramMdRuleRegex.ReplaceAllString(text, "")
+	text = telegramMdBulletRegex.ReplaceAllString(text, "$1• ")
+	text = telegramMdQuoteRegex.ReplaceAllString(text, "┃ ")
+	text = telegramMdLinkRegex.ReplaceAllStringFunc(text, func(link string) string {
+		parts := telegramMdLinkRegex.FindStringSubmatch(link)
+		href := strings.ReplaceAll(parts[2], `"`, "&quot;")
+		return fmt.Sprintf(`<a href="%s">%s</a>`, href, parts[1])
+	})
+	text = telegramMdBoldRegex.ReplaceAllString(text, "<b>$1</b>")
+	// headings in templates are often bold already, e.g. ### **Title**
+	text = strings.ReplaceAll(text, "<b><b>", "<b>")
+	text = strings.ReplaceAll(text, "</b></b>", "</b>")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	text = strings.Join(lines, "\n")
+	text = telegramBlankLineRegex.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// TruncateTelegramMessage cuts the message to the bot api limit, the cut happens on a line boundary
+// so that html tags are not broken
+func TruncateTelegramMessage(html string) string {
+	if utf8.RuneCountInString(html) <= TelegramMessageMaxLength {
+		return html
+	}
+	runes := []rune(html)
+	cut := string(runes[:TelegramMessageMaxLength-4])
+	if idx := strings.LastIndex(cut, "\n"); idx > 0 {
+		cut = cut[:idx]
+	}
+	return cut + "\n..."
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log/slog"
 	"math/big"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -228,6 +229,53 @@ func FormatBigIntWithDecimals(amountStr *string, decimals int) (string, error) {
 	return formattedStr, nil
 }
 
+// FormatTokenAmount formats a token amount in its smallest unit with thousands separators and at most
+// 4 fraction digits, e.g. 50000000000000000000000 with 18 decimals is "50,000"
+func FormatTokenAmount(amount *big.Int, decimals int) string {
+	if amount == nil {
+		return "0"
+	}
+	const maxFractionDigits = 4
+
+	sign := ""
+	value := new(big.Int).Set(amount)
+	if value.Sign() < 0 {
+		sign = "-"
+		value.Neg(value)
+	}
+
+	integer := value
+	fraction := ""
+	if decimals > 0 {
+		divisor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+		remainder := new(big.Int)
+		integer, remainder = new(big.Int).QuoRem(value, divisor, remainder)
+		fraction = fmt.Sprintf("%0*s", decimals, remainder.String())
+		if len(fraction) > maxFractionDigits {
+			fraction = fraction[:maxFractionDigits]
+		}
+		fraction = strings.TrimRight(fraction, "0")
+	}
+
+	digits := integer.String()
+	var grouped strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+
+	if fraction == "" {
+		if integer.Sign() == 0 && value.Sign() > 0 {
+			// too small to show with the fraction digits
+			return sign + "<0." + strings.Repeat("0", maxFractionDigits-1) + "1"
+		}
+		return sign + grouped.String()
+	}
+	return sign + grouped.String() + "." + fraction
+}
+
 // FormatAsQuote takes a multi-line string and prefixes each line with "> ".
 func FormatAsMdQuote(text string) string {
 	if text == "" {
@@ -244,3 +292,22 @@ func FormatAsMdQuote(text string) string {
 
 	return strings.Join(quotedLines, "\n")
 }
+
+// ExtractHost extracts the host (with port if any) from a url, https is assumed when the url has no scheme
+func ExtractHost(rawURL string) string {
+	if rawURL == "" {
+		return ""
+	}
+
+	// if url does not contain a protocol, add default https
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return parsedURL.Host
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"math/big"
 	"sync"
@@ -56,3 +57,12 @@ func NextOTPCode() (string, error) {
 
 	return otpCode, nil
 }
+
+// NextSecret generates a random hex encoded secret with the given bytes length
+func NextSecret(length int) (string, error) {
+	bytes := make([]byte, length)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("error generating secret: %w", err)
+	}
+	return hex.EncodeToString(bytes), nil
+}
@@ -0,0 +1 @@
+drop table if exists dgv_notification_delivery;
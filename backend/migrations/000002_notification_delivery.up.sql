@@ -0,0 +1,29 @@
+
+-- Notification delivery table, one row per (record, channel)
+create table
+  if not exists dgv_notification_delivery (
+    id varchar(50) not null,
+    record_id varchar(50) not null,
+    channel_id varchar(50) not null,
+    channel_type varchar(50) not null, -- { EMAIL, WEBHOOK, TELEGRAM, DISCORD, SLACK }
+    channel_value varchar(500) not null,
+    user_id varchar(50) not null,
+    state varchar(50) not null, -- { PENDING, SENT_OK, SENT_FAIL }
+    times_attempt int not null default 0,
+    last_error text,
+    provider_message_id varchar(255),
+    time_last_attempt timestamp,
+    ctime timestamp default now (),
+    utime timestamp,
+    primary key (id)
+  );
+
+create unique index uq_notification_delivery_record_channel on dgv_notification_delivery (record_id, channel_id);
+
+comment on table dgv_notification_delivery is 'Delivery of a notification record to one channel';
+comment on column dgv_notification_delivery.record_id is 'notification record id';
+comment on column dgv_notification_delivery.channel_id is 'notification channel id';
+comment on column dgv_notification_delivery.channel_value is 'channel value at the time of delivery';
+comment on column dgv_notification_delivery.times_attempt is 'number of delivery attempts';
+comment on column dgv_notification_delivery.last_error is 'error of the last failed attempt';
+comment on column dgv_notification_delivery.provider_message_id is 'message id returned by the provider';
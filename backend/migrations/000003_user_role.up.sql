@@ -0,0 +1,20 @@
+-- User roles, ADMIN is global, DAO_ADMIN is scoped to one dao
+create table
+  if not exists dgv_user_role (
+    id varchar(50) not null,
+    address varchar(255) not null,
+    role varchar(50) not null, -- { ADMIN, DAO_ADMIN }
+    dao_code varchar(255),
+    granted_by varchar(255),
+    ctime timestamp default now (),
+    utime timestamp,
+    primary key (id)
+  );
+
+create unique index uq_user_role_address_role_dao on dgv_user_role (address, role, coalesce(dao_code, ''));
+
+comment on table dgv_user_role is 'Roles granted to user addresses';
+comment on column dgv_user_role.address is 'user address, lowercase';
+comment on column dgv_user_role.role is 'role, ADMIN or DAO_ADMIN';
+comment on column dgv_user_role.dao_code is 'dao code of a DAO_ADMIN role';
+comment on column dgv_user_role.granted_by is 'address which granted the role';
@@ -0,0 +1,21 @@
+-- Login sessions, each session holds the hash of its current refresh token
+create table
+  if not exists dgv_user_session (
+    id varchar(50) not null,
+    user_id varchar(50) not null,
+    user_address varchar(255) not null,
+    refresh_token_hash varchar(64) not null,
+    state varchar(50) not null, -- { ACTIVE, REVOKED }
+    time_expire timestamp not null,
+    time_revoked timestamp,
+    ctime timestamp default now (),
+    utime timestamp,
+    primary key (id)
+  );
+
+create index idx_user_session_user_id on dgv_user_session (user_id);
+
+comment on table dgv_user_session is 'Login sessions';
+comment on column dgv_user_session.refresh_token_hash is 'sha256 of the current refresh token, rotated on every refresh';
+comment on column dgv_user_session.time_expire is 'expiration of the current refresh token';
+comment on column dgv_user_session.time_revoked is 'time the session was logged out or revoked';
@@ -0,0 +1,14 @@
+-- Short lived values shared by all replicas: login nonces, otp codes and rate limit markers
+create table
+  if not exists dgv_kv_store (
+    key varchar(255) not null,
+    value text not null,
+    time_expire timestamp not null,
+    ctime timestamp default now (),
+    primary key (key)
+  );
+
+create index idx_kv_store_time_expire on dgv_kv_store (time_expire);
+
+comment on table dgv_kv_store is 'Expiring key value store';
+comment on column dgv_kv_store.time_expire is 'the value is ignored after this time and removed periodically';
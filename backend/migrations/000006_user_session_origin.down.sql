@@ -0,0 +1,2 @@
+alter table dgv_user_session drop column if exists site;
+alter table dgv_user_session drop column if exists dao_code;
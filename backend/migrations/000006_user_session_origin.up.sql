@@ -0,0 +1,6 @@
+-- The dao site a session was started from
+alter table dgv_user_session add column if not exists dao_code varchar(255);
+alter table dgv_user_session add column if not exists site varchar(255);
+
+comment on column dgv_user_session.dao_code is 'code of the dao whose site the user signed in on';
+comment on column dgv_user_session.site is 'domain of the signed siwe message';
@@ -0,0 +1 @@
+drop table if exists dgv_user_address;
@@ -0,0 +1,28 @@
+-- Wallet addresses of a user, dgv_user.address is the primary one
+create table
+  if not exists dgv_user_address (
+    id varchar(50) not null,
+    user_id varchar(50) not null,
+    address varchar(255) not null,
+    ctime timestamp default now (),
+    primary key (id)
+  );
+
+create unique index uq_user_address_address on dgv_user_address (address);
+
+create index idx_user_address_user_id on dgv_user_address (user_id);
+
+comment on table dgv_user_address is 'Wallet addresses linked to a user';
+comment on column dgv_user_address.address is 'address, lowercase, an address belongs to one user only';
+
+-- every existing user owns its primary address
+insert into
+  dgv_user_address (id, user_id, address, ctime)
+select
+  id,
+  id,
+  address,
+  ctime
+from
+  dgv_user
+on conflict do nothing;
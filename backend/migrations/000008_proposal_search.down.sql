@@ -0,0 +1,7 @@
+drop index if exists idx_proposal_tracking_dao_proposal;
+
+drop index if exists idx_proposal_tracking_dao_state;
+
+drop index if exists idx_proposal_tracking_created;
+
+drop index if exists idx_proposal_tracking_title_trgm;
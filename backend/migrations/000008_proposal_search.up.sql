@@ -0,0 +1,10 @@
+-- Indexes for the proposals query: trigram title search and keyset pagination by creation time
+create extension if not exists pg_trgm;
+
+create index if not exists idx_proposal_tracking_title_trgm on dgv_proposal_tracking using gin (title gin_trgm_ops);
+
+create index if not exists idx_proposal_tracking_created on dgv_proposal_tracking ((coalesce(proposal_created_at, ctime)) desc, id desc);
+
+create index if not exists idx_proposal_tracking_dao_state on dgv_proposal_tracking (dao_code, state);
+
+create index if not exists idx_proposal_tracking_dao_proposal on dgv_proposal_tracking (dao_code, proposal_id);
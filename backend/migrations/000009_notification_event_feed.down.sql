@@ -0,0 +1 @@
+drop index if exists idx_notification_event_feed;
@@ -0,0 +1,2 @@
+-- Index for the personal feed, which pages through the events of a set of daos by creation time
+create index if not exists idx_notification_event_feed on dgv_notification_event (dao_code, ctime desc, id desc);
@@ -0,0 +1,7 @@
+drop index if exists idx_notification_record_unread;
+
+drop index if exists idx_notification_record_inbox;
+
+alter table dgv_notification_record drop column if exists time_read;
+alter table dgv_notification_record drop column if exists inbox_body;
+alter table dgv_notification_record drop column if exists inbox_title;
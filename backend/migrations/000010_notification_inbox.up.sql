@@ -0,0 +1,15 @@
+-- In-app inbox, the notification records are rendered into the inbox by the INBOX pseudo channel
+alter table dgv_notification_record add column if not exists inbox_title varchar(255);
+alter table dgv_notification_record add column if not exists inbox_body text;
+alter table dgv_notification_record add column if not exists time_read timestamp;
+
+create index if not exists idx_notification_record_inbox on dgv_notification_record (user_id, ctime desc, id desc);
+
+create index if not exists idx_notification_record_unread on dgv_notification_record (user_id)
+where
+  time_read is null
+  and inbox_title is not null;
+
+comment on column dgv_notification_record.inbox_title is 'title rendered for the in-app inbox';
+comment on column dgv_notification_record.inbox_body is 'markdown body rendered for the in-app inbox';
+comment on column dgv_notification_record.time_read is 'time the user read the notification in the inbox';
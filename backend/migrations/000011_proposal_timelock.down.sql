@@ -0,0 +1,4 @@
+drop index if exists idx_proposal_tracking_queued;
+
+alter table dgv_proposal_tracking drop column if exists expires_at;
+alter table dgv_proposal_tracking drop column if exists eta;
@@ -0,0 +1,11 @@
+-- Timelock of queued proposals, read from the governor when the proposal is queued
+alter table dgv_proposal_tracking add column if not exists eta timestamp;
+alter table dgv_proposal_tracking add column if not exists expires_at timestamp;
+
+create index if not exists idx_proposal_tracking_queued on dgv_proposal_tracking (dao_code)
+where
+  state = 'QUEUED'
+  and eta is not null;
+
+comment on column dgv_proposal_tracking.eta is 'time the queued proposal becomes executable';
+comment on column dgv_proposal_tracking.expires_at is 'time the queued proposal expires, eta + grace period of the timelock, null for timelocks without grace period';
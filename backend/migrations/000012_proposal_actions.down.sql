@@ -0,0 +1 @@
+alter table dgv_proposal_tracking drop column if exists actions;
@@ -0,0 +1,4 @@
+-- Decoded actions of the proposal, stored when the proposal is first tracked so lists of proposals return them without decoding
+alter table dgv_proposal_tracking add column if not exists actions text;
+
+comment on column dgv_proposal_tracking.actions is 'decoded actions of the proposal as json, null until they are decoded';
@@ -0,0 +1,104 @@
+package routes
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"github.com/ringecosystem/degov-apps/services"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="UTF-8" />
+    <meta name="viewport" content="width=device-width, initial-scale=1.0" />
+    <title>DeGov.AI - Unsubscribe</title>
+  </head>
+  <body style="font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; max-width: 480px; margin: 80px auto; padding: 0 20px; text-align: center;">
+    <h2>{{.Heading}}</h2>
+    <p>{{.Message}}</p>
+    {{- if .Token}}
+    <form method="POST">
+      <input type="hidden" name="token" value="{{.Token}}" />
+      <button type="submit" style="padding: 10px 24px; font-size: 16px; cursor: pointer;">Unsubscribe</button>
+    </form>
+    {{- end}}
+    <p><a href="{{.PreferenceURL}}">Manage your subscribe preferences</a></p>
+  </body>
+</html>
+`))
+
+type unsubscribePageData struct {
+	Heading       string
+	Message       string
+	Token         string
+	PreferenceURL string
+}
+
+type UnsubscribeRoute struct {
+	unsubscribeService *services.UnsubscribeService
+	preferenceURL      string
+}
+
+func NewUnsubscribeRoute(appsURL string) *UnsubscribeRoute {
+	return &UnsubscribeRoute{
+		unsubscribeService: services.NewUnsubscribeService(),
+		preferenceURL:      appsURL + "/subscribe/preference",
+	}
+}
+
+// Handler handles the /unsubscribe endpoint, no login is required since the token is signed.
+// GET only shows a confirmation page so that link scanners of mail clients do not unsubscribe,
+// POST applies the token, it is also the RFC 8058 one-click request sent by mail clients
+func (u *UnsubscribeRoute) Handler(w http.ResponseWriter, r *http.Request) {
+	token := r.FormValue("token")
+
+	switch r.Method {
+	case http.MethodGet:
+		claims, err := u.unsubscribeService.Verify(token)
+		if err != nil {
+			u.render(w, http.StatusBadRequest, "Invalid link", "This unsubscribe link is invalid or has expired.", "")
+			return
+		}
+		u.render(w, http.StatusOK, "Unsubscribe", "Do you want to unsubscribe from "+unsubscribeScopeText(claims)+"?", token)
+	case http.MethodPost:
+		claims, err := u.unsubscribeService.Unsubscribe(token)
+		if err != nil {
+			slog.Warn("Failed to unsubscribe", "error", err)
+			u.render(w, http.StatusBadRequest, "Unsubscribe failed", "This unsubscribe link is invalid or has expired.", "")
+			return
+		}
+		slog.Info("User unsubscribed", "user_id", claims.UserID, "scope", claims.Scope, "dao_code", claims.DaoCode, "proposal_id", claims.ProposalID, "feature", claims.Feature)
+		u.render(w, http.StatusOK, "Unsubscribed", "You have been unsubscribed from "+unsubscribeScopeText(claims)+".", "")
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (u *UnsubscribeRoute) render(w http.ResponseWriter, status int, heading, message, token string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := unsubscribePage.Execute(w, unsubscribePageData{
+		Heading:       heading,
+		Message:       message,
+		Token:         token,
+		PreferenceURL: u.preferenceURL,
+	}); err != nil {
+		slog.Warn("Failed to render unsubscribe page", "error", err)
+	}
+}
+
+func unsubscribeScopeText(claims *types.UnsubscribeClaims) string {
+	switch claims.Scope {
+	case types.UnsubscribeScopeDao:
+		return "all notifications of " + claims.DaoCode
+	case types.UnsubscribeScopeProposal:
+		return "the notifications of this proposal"
+	case types.UnsubscribeScopeFeature:
+		return "the " + string(claims.Feature) + " notifications of " + claims.DaoCode
+	default:
+		return "all DeGov emails"
+	}
+}
@@ -1,37 +1,56 @@
 package services
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/patrickmn/go-cache"
 	"github.com/ringecosystem/degov-apps/database"
 	dbmodels "github.com/ringecosystem/degov-apps/database/models"
 	gqlmodels "github.com/ringecosystem/degov-apps/graph/models"
+	"github.com/ringecosystem/degov-apps/internal"
 	"github.com/ringecosystem/degov-apps/internal/config"
+	"github.com/ringecosystem/degov-apps/internal/kvstore"
+	"github.com/ringecosystem/degov-apps/internal/utils"
 	"github.com/ringecosystem/degov-apps/types"
 	"github.com/spruceid/siwe-go"
 	"gorm.io/gorm"
 )
 
 type AuthService struct {
-	db          *gorm.DB
-	nonceCache  *cache.Cache
-	userService *UserService
+	db               *gorm.DB
+	store            kvstore.Store
+	userService      *UserService
+	userRoleService  *UserRoleService
+	sessionService   *UserSessionService
+	daoConfigService *DaoConfigService
+	// contractBackend returns the backend contract wallet signatures are verified with, nil when the chain has no rpc
+	contractBackend func(chainID int, daoCode *string) internal.ContractBackend
 }
 
 func NewAuthService() *AuthService {
-	c := cache.New(3*time.Minute, 5*time.Minute)
-
-	return &AuthService{
-		db:          database.GetDB(),
-		nonceCache:  c,
-		userService: NewUserService(),
+	s := &AuthService{
+		db:               database.GetDB(),
+		store:            kvstore.GetStore(),
+		userService:      NewUserService(),
+		userRoleService:  NewUserRoleService(),
+		sessionService:   NewUserSessionService(),
+		daoConfigService: NewDaoConfigService(),
 	}
+	s.contractBackend = s.rpcBackendForChain
+	return s
 }
 
 func (s *AuthService) Nonce(input gqlmodels.GetNonceInput) (string, error) {
@@ -49,52 +68,122 @@ func (s *AuthService) Nonce(input gqlmodels.GetNonceInput) (string, error) {
 
 	nonce := hex.EncodeToString(bytes)
 
-	// put nonce in cache, expires in 3 minutes
-	s.nonceCache.Set(nonce, true, 3*time.Minute)
+	// put nonce in store, expires in 3 minutes
+	if err := s.store.Set(nonceKey(nonce), "1", 3*time.Minute); err != nil {
+		return "", err
+	}
 	return nonce, nil
 }
 
-func (s *AuthService) Login(input gqlmodels.LoginInput) (gqlmodels.LoginOutput, error) {
-	message, err := siwe.ParseMessage(input.Message)
+func nonceKey(nonce string) string {
+	return "nonce:" + nonce
+}
+
+// Login verifies the siwe message and starts a session, origin is the dao site the request came from
+func (s *AuthService) Login(input gqlmodels.LoginInput, origin types.LoginOrigin) (gqlmodels.LoginOutput, error) {
+	message, origin, err := s.verifySiweMessage(input.Message, input.Signature, origin)
 	if err != nil {
-		err = fmt.Errorf("parse message err: %v", err)
 		return gqlmodels.LoginOutput{}, err
 	}
-	verify, err := message.ValidNow()
+
+	user, err := s.userService.Modify(dbmodels.User{
+		Address: message.GetAddress().Hex(),
+	})
 	if err != nil {
-		err = fmt.Errorf("message valid failed: %v", err)
+		err = fmt.Errorf("modify user failed: %v", err)
 		return gqlmodels.LoginOutput{}, err
 	}
 
-	if !verify {
-		err = fmt.Errorf("verify message fail")
+	session, refreshToken, err := s.sessionService.Create(user, origin)
+	if err != nil {
+		err = fmt.Errorf("create session failed: %v", err)
 		return gqlmodels.LoginOutput{}, err
 	}
 
-	//# must open
-	nonce := message.GetNonce()
-	slog.Debug("login nonce", "nonce", nonce)
-	enableCheckNonce := true
-	cfg := config.GetConfig()
-	if cfg.GetAppEnv().IsDevelopment() {
-		enableCheckNonce = cfg.GetStringWithDefault("UNSAFE_ENABLE_VERIFY_NONCE_ON_LOGIN", "true") == "true"
+	return s.issueTokens(user, session, refreshToken)
+}
+
+// LinkAddress links the address which signed the siwe message to the current user
+func (s *AuthService) LinkAddress(baseInput types.BasicInput[gqlmodels.LinkAddressInput], origin types.LoginOrigin) ([]*gqlmodels.LinkedAddress, error) {
+	message, _, err := s.verifySiweMessage(baseInput.Input.Message, baseInput.Input.Signature, origin)
+	if err != nil {
+		return nil, err
 	}
-	if enableCheckNonce {
-		// check nonce in cache
-		_, found := s.nonceCache.Get(nonce)
-		if !found {
-			err = fmt.Errorf("invalid or expired nonce")
-			return gqlmodels.LoginOutput{}, err
-		}
-		// After nonce verification, delete it from cache (one-time use)
-		s.nonceCache.Delete(nonce)
+	if err := s.userService.LinkAddress(baseInput.User.Id, message.GetAddress().Hex()); err != nil {
+		return nil, err
+	}
+	return s.LinkedAddresses(types.BasicInput[*string]{User: baseInput.User})
+}
+
+func (s *AuthService) UnlinkAddress(baseInput types.BasicInput[gqlmodels.UnlinkAddressInput]) ([]*gqlmodels.LinkedAddress, error) {
+	user, err := s.userService.Inspect(baseInput.User.Id)
+	if err != nil {
+		return nil, err
 	}
+	if err := s.userService.UnlinkAddress(user, baseInput.Input.Address); err != nil {
+		return nil, err
+	}
+	return s.LinkedAddresses(types.BasicInput[*string]{User: baseInput.User})
+}
 
-	user, err := s.userService.Modify(dbmodels.User{
-		Address: message.GetAddress().Hex(),
-	})
+func (s *AuthService) LinkedAddresses(baseInput types.BasicInput[*string]) ([]*gqlmodels.LinkedAddress, error) {
+	addresses, err := s.userService.ListAddresses(baseInput.User.Id)
 	if err != nil {
-		err = fmt.Errorf("modify user failed: %v", err)
+		return nil, err
+	}
+	result := make([]*gqlmodels.LinkedAddress, 0, len(addresses))
+	for _, address := range addresses {
+		result = append(result, &gqlmodels.LinkedAddress{
+			Address: address.Address,
+			Primary: strings.EqualFold(address.Address, baseInput.User.Address),
+			Ctime:   address.CTime,
+		})
+	}
+	return result, nil
+}
+
+// RefreshToken rotates the refresh token and issues a new access token, roles are reloaded on every refresh
+func (s *AuthService) RefreshToken(input gqlmodels.RefreshTokenInput) (gqlmodels.LoginOutput, error) {
+	session, refreshToken, err := s.sessionService.Rotate(input.RefreshToken)
+	if err != nil {
+		return gqlmodels.LoginOutput{}, err
+	}
+
+	var user dbmodels.User
+	if err := s.db.Where("id = ?", session.UserID).First(&user).Error; err != nil {
+		err = fmt.Errorf("query user failed: %v", err)
+		return gqlmodels.LoginOutput{}, err
+	}
+	return s.issueTokens(&user, session, refreshToken)
+}
+
+// Logout revokes the session of the current access token
+func (s *AuthService) Logout(baseInput types.BasicInput[string]) (bool, error) {
+	return s.sessionService.Revoke(baseInput.Input, baseInput.User.Id)
+}
+
+// LogoutAllSessions revokes every session of the user, on every device
+func (s *AuthService) LogoutAllSessions(baseInput types.BasicInput[*string]) (bool, error) {
+	count, err := s.sessionService.RevokeAll(baseInput.User.Id)
+	if err != nil {
+		return false, err
+	}
+	slog.Info("Logged out all sessions", "user_id", baseInput.User.Id, "count", count)
+	return true, nil
+}
+
+func accessTokenTTL() time.Duration {
+	ttl := config.GetDuration("AUTH_ACCESS_TOKEN_TTL")
+	if ttl <= 0 {
+		return 15 * time.Minute
+	}
+	return ttl
+}
+
+func (s *AuthService) issueTokens(user *dbmodels.User, session *dbmodels.UserSession, refreshToken string) (gqlmodels.LoginOutput, error) {
+	roles, err := s.userRoleService.RoleClaims(user.Address)
+	if err != nil {
+		err = fmt.Errorf("query user roles failed: %v", err)
 		return gqlmodels.LoginOutput{}, err
 	}
 
@@ -104,12 +193,15 @@ func (s *AuthService) Login(input gqlmodels.LoginInput) (gqlmodels.LoginOutput,
 		Email:   user.Email,
 		CTime:   user.CTime,
 		UTime:   user.UTime,
+		Roles:   roles,
 	}
 
-	// Create JWT token with claims
+	// Create JWT token with claims, sid binds the token to the session so that it can be revoked
+	ttl := accessTokenTTL()
 	claims := jwt.MapClaims{
 		"user": useSessInfo,
-		"exp":  time.Now().Add(24 * time.Hour).Unix(),
+		"sid":  session.ID,
+		"exp":  time.Now().Add(ttl).Unix(),
 		"iat":  time.Now().Unix(),
 	}
 
@@ -124,6 +216,247 @@ func (s *AuthService) Login(input gqlmodels.LoginInput) (gqlmodels.LoginOutput,
 	}
 
 	return gqlmodels.LoginOutput{
-		Token: tokenString,
+		Token:        tokenString,
+		RefreshToken: refreshToken,
+		Expiration:   int32(ttl.Seconds()),
 	}, nil
 }
+
+// verifySiweMessage verifies the time constraints, origin, signature and nonce of a siwe message
+func (s *AuthService) verifySiweMessage(rawMessage string, signature string, origin types.LoginOrigin) (*siwe.Message, types.LoginOrigin, error) {
+	message, err := siwe.ParseMessage(rawMessage)
+	if err != nil {
+		err = fmt.Errorf("parse message err: %v", err)
+		return nil, origin, err
+	}
+	verify, err := message.ValidNow()
+	if err != nil {
+		err = fmt.Errorf("message valid failed: %v", err)
+		return nil, origin, err
+	}
+
+	if !verify {
+		err = fmt.Errorf("verify message fail")
+		return nil, origin, err
+	}
+
+	// the message must be signed for one of our sites, otherwise a phishing site could replay it
+	origin, err = s.validateSiweOrigin(message, origin)
+	if err != nil {
+		return nil, origin, err
+	}
+
+	if err := s.verifySignature(message, signature, origin); err != nil {
+		return nil, origin, err
+	}
+
+	//# must open
+	nonce := message.GetNonce()
+	slog.Debug("login nonce", "nonce", nonce)
+	enableCheckNonce := true
+	cfg := config.GetConfig()
+	if cfg.GetAppEnv().IsDevelopment() {
+		enableCheckNonce = cfg.GetStringWithDefault("UNSAFE_ENABLE_VERIFY_NONCE_ON_LOGIN", "true") == "true"
+	}
+	if enableCheckNonce {
+		// check and consume the nonce at once (one-time use)
+		if _, err := s.store.Take(nonceKey(nonce)); err != nil {
+			if !errors.Is(err, kvstore.ErrNotFound) {
+				slog.Error("Failed to take login nonce", "error", err)
+			}
+			err = fmt.Errorf("invalid or expired nonce")
+			return nil, origin, err
+		}
+	}
+
+	return message, origin, nil
+}
+
+// siweAllowList is the set of domains and chains a siwe message can be signed for
+type siweAllowList struct {
+	// domains maps an allowed host to the code of its dao, empty for the configured domains
+	domains map[string]string
+	// wildcards are configured suffixes such as .degov.ai
+	wildcards []string
+	chainIDs  map[int]bool
+}
+
+// loadSiweAllowList builds the allow list from SIWE_ALLOWED_DOMAINS, SIWE_ALLOWED_CHAIN_IDS and the dao endpoints
+func (s *AuthService) loadSiweAllowList() (*siweAllowList, error) {
+	allowList := &siweAllowList{
+		domains:  make(map[string]string),
+		chainIDs: make(map[int]bool),
+	}
+
+	for _, domain := range strings.Split(config.GetString("SIWE_ALLOWED_DOMAINS"), ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		switch {
+		case domain == "":
+		case strings.HasPrefix(domain, "*."):
+			allowList.wildcards = append(allowList.wildcards, domain[1:])
+		default:
+			allowList.domains[domain] = ""
+		}
+	}
+	if config.GetAppEnv().IsDevelopment() {
+		allowList.domains["localhost"] = ""
+		allowList.domains["127.0.0.1"] = ""
+	}
+	for _, chainID := range strings.Split(config.GetString("SIWE_ALLOWED_CHAIN_IDS"), ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(chainID)); err == nil {
+			allowList.chainIDs[id] = true
+		}
+	}
+
+	var daos []dbmodels.Dao
+	err := s.db.Select("code", "endpoint", "chain_id").
+		Where("state IN ?", []dbmodels.DaoState{dbmodels.DaoStateActive, dbmodels.DaoStateDraft}).
+		Find(&daos).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daos: %w", err)
+	}
+	for _, dao := range daos {
+		allowList.chainIDs[dao.ChainID] = true
+		if host := strings.ToLower(utils.ExtractHost(dao.Endpoint)); host != "" {
+			allowList.domains[host] = dao.Code
+		}
+	}
+	return allowList, nil
+}
+
+// match reports whether the domain is allowed and returns the code of its dao if it is a dao site
+func (a *siweAllowList) match(domain string) (string, bool) {
+	hostname := domain
+	if host, _, err := net.SplitHostPort(domain); err == nil {
+		hostname = host
+	}
+	for _, candidate := range []string{domain, hostname} {
+		if daoCode, ok := a.domains[candidate]; ok {
+			return daoCode, true
+		}
+	}
+	for _, wildcard := range a.wildcards {
+		if strings.HasSuffix(hostname, wildcard) {
+			return "", true
+		}
+	}
+	return "", false
+}
+
+// validateSiweOrigin checks the domain, uri and chain id of the message, and that the message was signed
+// on the site the request came from. The origin is returned with the dao and site resolved from the message
+func (s *AuthService) validateSiweOrigin(message *siwe.Message, origin types.LoginOrigin) (types.LoginOrigin, error) {
+	allowList, err := s.loadSiweAllowList()
+	if err != nil {
+		return origin, err
+	}
+
+	chainID := message.GetChainID()
+	if !allowList.chainIDs[chainID] {
+		return origin, fmt.Errorf("chain id %d is not supported", chainID)
+	}
+
+	domain := strings.ToLower(message.GetDomain())
+	domainDaoCode, ok := allowList.match(domain)
+	if !ok {
+		return origin, fmt.Errorf("domain %s is not allowed to sign in", domain)
+	}
+
+	uri := message.GetURI()
+	if uriHost := strings.ToLower(uri.Host); uriHost != domain {
+		return origin, fmt.Errorf("uri %s does not match domain %s", uri.String(), domain)
+	}
+
+	if origin.Site != nil && *origin.Site != "" && !strings.EqualFold(*origin.Site, domain) {
+		return origin, fmt.Errorf("domain %s does not match the requesting site %s", domain, *origin.Site)
+	}
+	if domainDaoCode != "" {
+		if origin.DaoCode != nil && *origin.DaoCode != "" && *origin.DaoCode != domainDaoCode {
+			return origin, fmt.Errorf("domain %s belongs to dao %s, not %s", domain, domainDaoCode, *origin.DaoCode)
+		}
+		origin.DaoCode = &domainDaoCode
+	}
+	origin.Site = &domain
+	return origin, nil
+}
+
+// verifySignature verifies the signature with ECDSA recovery, when it fails and the signer address
+// is a contract (e.g. a Safe multisig) the wallet is asked through EIP-1271 isValidSignature
+func (s *AuthService) verifySignature(message *siwe.Message, signature string, origin types.LoginOrigin) error {
+	sigBytes, err := hexutil.Decode(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+
+	// VerifyEIP191 only handles 65 bytes signatures, contract wallets may use any length
+	var ecdsaErr error
+	if len(sigBytes) == crypto.SignatureLength {
+		if _, ecdsaErr = message.VerifyEIP191(signature); ecdsaErr == nil {
+			return nil
+		}
+	} else {
+		ecdsaErr = fmt.Errorf("signature length %d is not an ECDSA signature", len(sigBytes))
+	}
+
+	chainID := message.GetChainID()
+	backend := s.contractBackend(chainID, origin.DaoCode)
+	if backend == nil {
+		return fmt.Errorf("invalid signature: %v", ecdsaErr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	address := message.GetAddress()
+	isContract, err := internal.HasCode(ctx, backend, address)
+	if err != nil {
+		slog.Error("Failed to check signer code", "address", address.Hex(), "chain_id", chainID, "error", err)
+		return fmt.Errorf("invalid signature: %v", ecdsaErr)
+	}
+	if !isContract {
+		return fmt.Errorf("invalid signature: %v", ecdsaErr)
+	}
+
+	valid, err := internal.VerifyEIP1271Signature(ctx, backend, address, common.BytesToHash(accounts.TextHash([]byte(message.String()))), sigBytes)
+	if err != nil {
+		return fmt.Errorf("failed to verify contract wallet signature: %v", err)
+	}
+	if !valid {
+		return fmt.Errorf("invalid signature: rejected by contract wallet %s", address.Hex())
+	}
+	slog.Info("Contract wallet signature verified", "address", address.Hex(), "chain_id", chainID)
+	return nil
+}
+
+// rpcBackendForChain returns the rpc pool of the chain, nil when no rpc is known for the chain
+func (s *AuthService) rpcBackendForChain(chainID int, daoCode *string) internal.ContractBackend {
+	rpcPool := s.rpcPoolForChain(chainID, daoCode)
+	if rpcPool.Size() == 0 {
+		return nil
+	}
+	return rpcPool
+}
+
+// rpcPoolForChain returns the rpc pool of the chain with the rpcs of the dao configs of the chain,
+// the dao the login came from goes first
+func (s *AuthService) rpcPoolForChain(chainID int, daoCode *string) *internal.RPCPool {
+	var daoCodes []string
+	if daoCode != nil && *daoCode != "" {
+		daoCodes = append(daoCodes, *daoCode)
+	}
+	var chainDaoCodes []string
+	if err := s.db.Model(&dbmodels.Dao{}).Where("chain_id = ?", chainID).Order("seq asc").Pluck("code", &chainDaoCodes).Error; err != nil {
+		slog.Warn("Failed to query daos of chain", "chain_id", chainID, "error", err)
+	}
+	daoCodes = append(daoCodes, chainDaoCodes...)
+
+	var rpcURLs []string
+	for _, code := range daoCodes {
+		daoConfig, err := s.daoConfigService.StandardConfig(code)
+		if err != nil || daoConfig.Chain.ID != chainID {
+			continue
+		}
+		rpcURLs = append(rpcURLs, daoConfig.Chain.RPCs...)
+	}
+	return internal.GetRPCPool(chainID, rpcURLs)
+}
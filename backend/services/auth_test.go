@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/program"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ringecosystem/degov-apps/internal"
+	dtypes "github.com/ringecosystem/degov-apps/types"
+	"github.com/spruceid/siwe-go"
+)
+
+// walletCode answers isValidSignature with the value, whatever the hash and signature are
+func walletCode(value [4]byte) []byte {
+	word := make([]byte, 32)
+	copy(word, value[:])
+	return program.New().Push(word).Push(0).Op(vm.MSTORE).Return(0, 32).Bytes()
+}
+
+// revertingWalletCode reverts every call
+func revertingWalletCode() []byte {
+	return program.New().Push(0).Push(0).Op(vm.REVERT).Bytes()
+}
+
+type signatureTestChain struct {
+	backend *simulated.Backend
+	key     *ecdsa.PrivateKey
+}
+
+func newSignatureTestChain(t *testing.T) *signatureTestChain {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		crypto.PubkeyToAddress(key.PublicKey): {Balance: big.NewInt(params.Ether)},
+	})
+	t.Cleanup(func() { backend.Close() })
+	return &signatureTestChain{backend: backend, key: key}
+}
+
+// deploy deploys the runtime code with a constructor returning it
+func (c *signatureTestChain) deploy(t *testing.T, runtime []byte) common.Address {
+	t.Helper()
+	ctx := context.Background()
+	client := c.backend.Client()
+
+	nonce, err := client.PendingNonceAt(ctx, crypto.PubkeyToAddress(c.key.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := types.SignTx(types.NewContractCreation(nonce, big.NewInt(0), 1_000_000, gasPrice, program.New().ReturnViaCodeCopy(runtime).Bytes()),
+		types.LatestSignerForChainID(chainID), c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SendTransaction(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	c.backend.Commit()
+
+	receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatal("contract deployment failed")
+	}
+	return receipt.ContractAddress
+}
+
+func (c *signatureTestChain) authService() *AuthService {
+	return &AuthService{
+		contractBackend: func(chainID int, daoCode *string) internal.ContractBackend {
+			return c.backend.Client()
+		},
+	}
+}
+
+func siweTestMessage(t *testing.T, address common.Address) *siwe.Message {
+	t.Helper()
+	message, err := siwe.InitMessage("degov.ai", address.Hex(), "https://degov.ai", "nonce12345678", map[string]interface{}{
+		"chainId": 1337,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+// signMessage signs the siwe message with EIP-191 like a wallet, with a recovery id of 27 or 28
+func signMessage(t *testing.T, key *ecdsa.PrivateKey, message *siwe.Message) string {
+	t.Helper()
+	signature, err := crypto.Sign(accounts.TextHash([]byte(message.String())), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	return hexutil.Encode(signature)
+}
+
+func TestVerifySignatureEOA(t *testing.T) {
+	chain := newSignatureTestChain(t)
+	service := chain.authService()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := siweTestMessage(t, crypto.PubkeyToAddress(key.PublicKey))
+	if err := service.verifySignature(message, signMessage(t, key, message), dtypes.LoginOrigin{}); err != nil {
+		t.Fatalf("expected the ECDSA signature to be valid: %v", err)
+	}
+
+	// signed by another key, the address has no code to ask
+	otherKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.verifySignature(message, signMessage(t, otherKey, message), dtypes.LoginOrigin{}); err == nil {
+		t.Fatal("expected the signature of another key to be rejected")
+	}
+}
+
+func TestVerifySignatureContractWalletMagicValue(t *testing.T) {
+	chain := newSignatureTestChain(t)
+	service := chain.authService()
+	wallet := chain.deploy(t, walletCode(internal.EIP1271MagicValue))
+	message := siweTestMessage(t, wallet)
+
+	// a multisig signature is not 65 bytes, an ECDSA sized one fails the recovery first
+	otherKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, signature := range []string{"0x1234567890abcdef", signMessage(t, otherKey, message)} {
+		if err := service.verifySignature(message, signature, dtypes.LoginOrigin{}); err != nil {
+			t.Fatalf("expected the contract wallet to accept signature %s: %v", signature, err)
+		}
+	}
+}
+
+func TestVerifySignatureContractWalletRejects(t *testing.T) {
+	chain := newSignatureTestChain(t)
+	service := chain.authService()
+
+	wallets := map[string]common.Address{
+		"wrong value": chain.deploy(t, walletCode([4]byte{0xff, 0xff, 0xff, 0xff})),
+		"revert":      chain.deploy(t, revertingWalletCode()),
+	}
+	for name, wallet := range wallets {
+		message := siweTestMessage(t, wallet)
+		if err := service.verifySignature(message, "0x1234567890abcdef", dtypes.LoginOrigin{}); err == nil {
+			t.Errorf("%s: expected the contract wallet signature to be rejected", name)
+		}
+	}
+}
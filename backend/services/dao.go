@@ -476,14 +476,7 @@ func (s *UserLikedDaoService) LikedDaos(baseInput types.BasicInput[*string]) ([]
 		return []*gqlmodels.Dao{}, nil
 	}
 
-	// First get liked dao codes by joining with dgv_dao to ensure only ACTIVE DAOs
-	var daoCodes []string
-	err := s.db.Table("dgv_user_liked_dao").
-		Select("dgv_user_liked_dao.dao_code").
-		Joins("INNER JOIN dgv_dao ON dgv_user_liked_dao.dao_code = dgv_dao.code").
-		Where("dgv_user_liked_dao.user_id = ? AND dgv_dao.state = ?", baseInput.User.Id, "ACTIVE").
-		Pluck("dao_code", &daoCodes).Error
-
+	daoCodes, err := s.LikedDaoCodes(baseInput.User.Id)
 	if err != nil {
 		return nil, err
 	}
@@ -502,6 +495,20 @@ func (s *UserLikedDaoService) LikedDaos(baseInput types.BasicInput[*string]) ([]
 	})
 }
 
+// LikedDaoCodes returns the codes of the ACTIVE daos liked by the user
+func (s *UserLikedDaoService) LikedDaoCodes(userID string) ([]string, error) {
+	var daoCodes []string
+	err := s.db.Table("dgv_user_liked_dao").
+		Select("dgv_user_liked_dao.dao_code").
+		Joins("INNER JOIN dgv_dao ON dgv_user_liked_dao.dao_code = dgv_dao.code").
+		Where("dgv_user_liked_dao.user_id = ? AND dgv_dao.state = ?", userID, "ACTIVE").
+		Pluck("dao_code", &daoCodes).Error
+	if err != nil {
+		return nil, err
+	}
+	return daoCodes, nil
+}
+
 type DaoChipService struct {
 	db *gorm.DB
 }
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB opens a sqlite database with the tables of the models. Queries using postgres only
+// syntax can not run on it, the tests cover the services built on plain queries
+func newTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the models default their times to now(), which sqlite only takes as CURRENT_TIMESTAMP
+	if err := db.Callback().Raw().Before("gorm:raw").Register("test:default_now", func(tx *gorm.DB) {
+		sql := strings.ReplaceAll(tx.Statement.SQL.String(), "DEFAULT now()", "DEFAULT CURRENT_TIMESTAMP")
+		tx.Statement.SQL.Reset()
+		tx.Statement.SQL.WriteString(sql)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/ringecosystem/degov-apps/database"
 	dbmodels "github.com/ringecosystem/degov-apps/database/models"
 	gqlmodels "github.com/ringecosystem/degov-apps/graph/models"
+	"github.com/ringecosystem/degov-apps/internal"
 	"github.com/ringecosystem/degov-apps/internal/config"
 	"github.com/ringecosystem/degov-apps/internal/utils"
 	"gorm.io/gorm"
@@ -305,3 +306,32 @@ func (s *EvmChainService) saveAbiToDB(contract *dbmodels.ContractsAbi) error {
 	contract.UTime = &now
 	return s.db.Create(contract).Error
 }
+
+// RPCHealth reports the rpc pools of the chains used so far
+func (s *EvmChainService) RPCHealth() []*gqlmodels.RPCPoolHealth {
+	pools := internal.RPCPoolsHealth()
+	outputs := make([]*gqlmodels.RPCPoolHealth, 0, len(pools))
+	for _, pool := range pools {
+		output := &gqlmodels.RPCPoolHealth{
+			ChainID:   int32(pool.ChainID),
+			Healthy:   pool.Healthy,
+			Endpoints: make([]*gqlmodels.RPCEndpointHealth, 0, len(pool.Endpoints)),
+		}
+		for _, endpoint := range pool.Endpoints {
+			output.Endpoints = append(output.Endpoints, &gqlmodels.RPCEndpointHealth{
+				URL:                 endpoint.URL,
+				Healthy:             endpoint.Healthy,
+				LatencyMs:           int32(endpoint.LatencyMs),
+				ErrorRate:           endpoint.ErrorRate,
+				Requests:            int32(endpoint.Requests),
+				Failures:            int32(endpoint.Failures),
+				ConsecutiveFailures: int32(endpoint.ConsecutiveFailures),
+				BackoffUntil:        endpoint.BackoffUntil,
+				LastError:           endpoint.LastError,
+				LastUsed:            endpoint.LastUsed,
+			})
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs
+}
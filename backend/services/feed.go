@@ -0,0 +1,186 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"gorm.io/gorm"
+
+	"github.com/ringecosystem/degov-apps/database"
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	gqlmodels "github.com/ringecosystem/degov-apps/graph/models"
+	"github.com/ringecosystem/degov-apps/internal/utils"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+// feedEventTypes are the notification events shown in the feed, votes are too noisy for it
+var feedEventTypes = []dbmodels.SubscribeFeatureName{
+	dbmodels.SubscribeFeatureProposalNew,
+	dbmodels.SubscribeFeatureProposalStateChanged,
+	dbmodels.SubscribeFeatureVoteEnd,
+	dbmodels.SubscribeFeatureProposalExecutable,
+	dbmodels.SubscribeFeatureProposalExpiring,
+}
+
+// FeedService builds the personal activity feed from the notification events of the liked and subscribed daos
+type FeedService struct {
+	db                    *gorm.DB
+	userLikedService      *UserLikedDaoService
+	userSubscribedService *UserSubscribedDaoService
+	proposalService       *ProposalService
+}
+
+func NewFeedService() *FeedService {
+	return &FeedService{
+		db:                    database.GetDB(),
+		userLikedService:      NewUserLikedDaoService(),
+		userSubscribedService: NewUserSubscribedDaoService(),
+		proposalService:       NewProposalService(),
+	}
+}
+
+func (s *FeedService) MyFeed(baseInput types.BasicInput[types.ListFeedInput]) (*gqlmodels.FeedConnection, error) {
+	if baseInput.User == nil {
+		return nil, fmt.Errorf("not logged in")
+	}
+	input := baseInput.Input
+
+	first, err := connectionPageSize(input.First)
+	if err != nil {
+		return nil, err
+	}
+
+	daoCodes, err := s.feedDaoCodes(baseInput.User.Id)
+	if err != nil {
+		return nil, err
+	}
+	connection := &gqlmodels.FeedConnection{
+		Edges:    []*gqlmodels.FeedEdge{},
+		PageInfo: &gqlmodels.PageInfo{},
+	}
+	if len(daoCodes) == 0 {
+		return connection, nil
+	}
+
+	defaultLeadTime := FormatLeadTime(VoteEndDefaultLeadTime)
+	query := s.db.Table("dgv_notification_event AS e").
+		Select("e.*").
+		Joins("INNER JOIN dgv_proposal_tracking AS p ON p.dao_code = e.dao_code AND p.proposal_id = e.proposal_id").
+		Where("e.dao_code IN ? AND e.type IN ?", daoCodes, feedEventTypes).
+		// a proposal has one vote end event per lead time, only the default one is shown. The lead time is read
+		// from the json payload like VoteEndEventLeadTime does, a payload without it is the default one
+		Where("CASE WHEN e.type = ? THEN coalesce(nullif(nullif(e.payload, '')::jsonb ->> 'lead_time', ''), ?) = ? ELSE true END",
+			dbmodels.SubscribeFeatureVoteEnd, defaultLeadTime, defaultLeadTime)
+	if input.After != nil && *input.After != "" {
+		cursorTime, cursorID, err := utils.DecodeCursor(*input.After)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("(e.ctime, e.id) < (?, ?)", cursorTime, cursorID)
+	}
+
+	// one more row tells whether there is a next page
+	var events []*dbmodels.NotificationEvent
+	err = query.
+		Order("e.ctime desc, e.id desc").
+		Limit(first + 1).
+		Find(&events).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list feed events: %w", err)
+	}
+	connection.PageInfo.HasNextPage = len(events) > first
+	if len(events) > first {
+		events = events[:first]
+	}
+	if len(events) == 0 {
+		return connection, nil
+	}
+
+	proposals, err := s.feedProposals(events)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		proposal, ok := proposals[event.DaoCode+"/"+event.ProposalID]
+		if !ok {
+			continue
+		}
+		connection.Edges = append(connection.Edges, &gqlmodels.FeedEdge{
+			Cursor: utils.EncodeCursor(event.CTime, event.ID),
+			Node:   s.convertFeedItem(event, proposal),
+		})
+	}
+	if len(connection.Edges) > 0 {
+		endCursor := connection.Edges[len(connection.Edges)-1].Cursor
+		connection.PageInfo.EndCursor = &endCursor
+	}
+	return connection, nil
+}
+
+// feedDaoCodes returns the liked and subscribed dao codes without duplicates
+func (s *FeedService) feedDaoCodes(userID string) ([]string, error) {
+	likedCodes, err := s.userLikedService.LikedDaoCodes(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list liked daos: %w", err)
+	}
+	subscribedCodes, err := s.userSubscribedService.SubscribedDaoCodes(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscribed daos: %w", err)
+	}
+
+	seen := make(map[string]bool, len(likedCodes)+len(subscribedCodes))
+	daoCodes := make([]string, 0, len(likedCodes)+len(subscribedCodes))
+	for _, code := range append(likedCodes, subscribedCodes...) {
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		daoCodes = append(daoCodes, code)
+	}
+	return daoCodes, nil
+}
+
+// feedProposals loads the proposals of the events in one query, keyed by dao_code/proposal_id
+func (s *FeedService) feedProposals(events []*dbmodels.NotificationEvent) (map[string]*dbmodels.ProposalTracking, error) {
+	keys := make([][]interface{}, 0, len(events))
+	for _, event := range events {
+		keys = append(keys, []interface{}{event.DaoCode, event.ProposalID})
+	}
+
+	var proposals []*dbmodels.ProposalTracking
+	if err := s.db.Where("(dao_code, proposal_id) IN ?", keys).Find(&proposals).Error; err != nil {
+		return nil, fmt.Errorf("failed to load feed proposals: %w", err)
+	}
+	result := make(map[string]*dbmodels.ProposalTracking, len(proposals))
+	for _, proposal := range proposals {
+		result[proposal.DaoCode+"/"+proposal.ProposalID] = proposal
+	}
+	return result, nil
+}
+
+func (s *FeedService) convertFeedItem(event *dbmodels.NotificationEvent, proposal *dbmodels.ProposalTracking) *gqlmodels.FeedItem {
+	item := &gqlmodels.FeedItem{
+		ID:        event.ID,
+		Type:      gqlmodels.FeatureName(event.Type),
+		DaoCode:   event.DaoCode,
+		ChainID:   int32(event.ChainID),
+		Proposal:  s.proposalService.ConvertToGqlProposal(proposal),
+		TimeEvent: event.TimeEvent,
+		Ctime:     event.CTime,
+	}
+	if event.Type == dbmodels.SubscribeFeatureProposalStateChanged && event.Payload != nil {
+		var payload types.ProposalStateChangedPayload
+		if err := json.Unmarshal([]byte(*event.Payload), &payload); err != nil {
+			slog.Warn("Failed to parse state changed payload", "event_id", event.ID, "error", err)
+			return item
+		}
+		newState := gqlmodels.ProposalState(payload.NewState)
+		item.NewState = &newState
+		if payload.OldState != nil {
+			oldState := gqlmodels.ProposalState(*payload.OldState)
+			item.OldState = &oldState
+		}
+	}
+	return item
+}
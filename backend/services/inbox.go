@@ -0,0 +1,148 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/ringecosystem/degov-apps/database"
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	gqlmodels "github.com/ringecosystem/degov-apps/graph/models"
+	"github.com/ringecosystem/degov-apps/internal/utils"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+// InboxService serves the notification records rendered by the INBOX pseudo channel
+type InboxService struct {
+	db *gorm.DB
+}
+
+func NewInboxService() *InboxService {
+	return &InboxService{
+		db: database.GetDB(),
+	}
+}
+
+func (s *InboxService) ListNotifications(baseInput types.BasicInput[types.ListInboxInput]) (*gqlmodels.InboxNotificationConnection, error) {
+	if baseInput.User == nil {
+		return nil, fmt.Errorf("not logged in")
+	}
+	input := baseInput.Input
+
+	first, err := connectionPageSize(input.First)
+	if err != nil {
+		return nil, err
+	}
+
+	unreadCount, err := s.UnreadCount(baseInput.User.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.inboxQuery(baseInput.User.Id)
+	if input.UnreadOnly {
+		query = query.Where("time_read IS NULL")
+	}
+	if input.After != nil && *input.After != "" {
+		cursorTime, cursorID, err := utils.DecodeCursor(*input.After)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("(ctime, id) < (?, ?)", cursorTime, cursorID)
+	}
+
+	// one more row tells whether there is a next page
+	var records []*dbmodels.NotificationRecord
+	err = query.
+		Order("ctime desc, id desc").
+		Limit(first + 1).
+		Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inbox notifications: %w", err)
+	}
+
+	connection := &gqlmodels.InboxNotificationConnection{
+		Edges:       make([]*gqlmodels.InboxNotificationEdge, 0, len(records)),
+		PageInfo:    &gqlmodels.PageInfo{HasNextPage: len(records) > first},
+		UnreadCount: int32(unreadCount),
+	}
+	if len(records) > first {
+		records = records[:first]
+	}
+	for _, record := range records {
+		connection.Edges = append(connection.Edges, &gqlmodels.InboxNotificationEdge{
+			Cursor: utils.EncodeCursor(record.CTime, record.ID),
+			Node:   convertInboxNotification(record),
+		})
+	}
+	if len(connection.Edges) > 0 {
+		endCursor := connection.Edges[len(connection.Edges)-1].Cursor
+		connection.PageInfo.EndCursor = &endCursor
+	}
+	return connection, nil
+}
+
+func (s *InboxService) UnreadCount(userID string) (int64, error) {
+	var count int64
+	if err := s.inboxQuery(userID).Where("time_read IS NULL").Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count unread notifications: %w", err)
+	}
+	return count, nil
+}
+
+// MarkRead marks the given notifications, or all of them, of the user as read
+func (s *InboxService) MarkRead(baseInput types.BasicInput[gqlmodels.MarkNotificationsReadInput]) (*gqlmodels.MarkNotificationsReadOutput, error) {
+	if baseInput.User == nil {
+		return nil, fmt.Errorf("not logged in")
+	}
+	input := baseInput.Input
+	all := input.All != nil && *input.All
+	if !all && len(input.Ids) == 0 {
+		return nil, fmt.Errorf("either ids or all is required")
+	}
+
+	query := s.inboxQuery(baseInput.User.Id).Where("time_read IS NULL")
+	if !all {
+		query = query.Where("id IN ?", input.Ids)
+	}
+	result := query.Update("time_read", time.Now())
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to mark notifications read: %w", result.Error)
+	}
+
+	unreadCount, err := s.UnreadCount(baseInput.User.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &gqlmodels.MarkNotificationsReadOutput{
+		Marked:      int32(result.RowsAffected),
+		UnreadCount: int32(unreadCount),
+	}, nil
+}
+
+// inboxQuery selects the records of the user which were rendered into the inbox
+func (s *InboxService) inboxQuery(userID string) *gorm.DB {
+	return s.db.Model(&dbmodels.NotificationRecord{}).
+		Where("user_id = ? AND inbox_title IS NOT NULL", userID)
+}
+
+func convertInboxNotification(record *dbmodels.NotificationRecord) *gqlmodels.InboxNotification {
+	notification := &gqlmodels.InboxNotification{
+		ID:         record.ID,
+		Type:       gqlmodels.FeatureName(record.Type),
+		DaoCode:    record.DaoCode,
+		ChainID:    int32(record.ChainID),
+		ProposalID: record.ProposalID,
+		Read:       record.TimeRead != nil,
+		TimeRead:   record.TimeRead,
+		Ctime:      record.CTime,
+	}
+	if record.InboxTitle != nil {
+		notification.Title = *record.InboxTitle
+	}
+	if record.InboxBody != nil {
+		notification.Body = *record.InboxBody
+	}
+	return notification
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jinzhu/copier"
+	"github.com/ringecosystem/degov-apps/database"
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	gqlmodels "github.com/ringecosystem/degov-apps/graph/models"
+	"github.com/ringecosystem/degov-apps/internal/utils"
+	"github.com/ringecosystem/degov-apps/types"
+	"gorm.io/gorm"
+)
+
+const (
+	// liveEventBufferSize is the number of events buffered per subscriber, events are dropped for subscribers which can not keep up
+	liveEventBufferSize = 32
+	// liveEventChannel is the postgres notification channel the replicas share the events on
+	liveEventChannel = "degov_live_events"
+	// liveEventMaxPayload is the largest notification payload postgres accepts
+	liveEventMaxPayload = 7999
+	// liveEventListenRetry is how long the listener waits before reconnecting
+	liveEventListenRetry = 5 * time.Second
+)
+
+var (
+	globalLiveEvent *LiveEventService
+	liveEventOnce   sync.Once
+)
+
+// LiveEventService fans out proposal and vote events to the graphql subscriptions. The tracking tasks publish
+// the events on one replica, they are delivered to its subscribers and sent to the other replicas with
+// postgres NOTIFY, which the replicas serving subscriptions receive with Listen
+type LiveEventService struct {
+	db *gorm.DB
+	// source identifies this replica, its own notifications are not delivered twice
+	source string
+
+	mu          sync.RWMutex
+	nextID      uint64
+	subscribers map[uint64]*liveEventSubscriber
+}
+
+// liveEventNotification is the payload of the postgres notification of an event
+type liveEventNotification struct {
+	Source string          `json:"source"`
+	Event  types.LiveEvent `json:"event"`
+}
+
+type liveEventSubscriber struct {
+	filter types.LiveEventFilter
+	events chan types.LiveEvent
+}
+
+func NewLiveEventService() *LiveEventService {
+	liveEventOnce.Do(func() {
+		source, err := utils.NextSecret(16)
+		if err != nil {
+			source = utils.NextIDString()
+		}
+		globalLiveEvent = &LiveEventService{
+			db:          database.GetDB(),
+			source:      source,
+			subscribers: make(map[uint64]*liveEventSubscriber),
+		}
+	})
+	return globalLiveEvent
+}
+
+// Publish delivers the event to the matching subscribers of this replica and notifies the other replicas
+func (s *LiveEventService) Publish(event types.LiveEvent) {
+	s.deliver(event)
+	s.notify(event)
+}
+
+// notify sends the event to the other replicas, events too large for a notification only reach this replica
+func (s *LiveEventService) notify(event types.LiveEvent) {
+	if s.db == nil {
+		return
+	}
+	payload, err := s.notificationPayload(event)
+	if err != nil {
+		slog.Warn("Failed to encode live event", "type", event.Type, "error", err)
+		return
+	}
+	if len(payload) > liveEventMaxPayload {
+		slog.Warn("Live event is too large to notify the other replicas", "type", event.Type, "dao_code", event.DaoCode, "proposal_id", event.ProposalID, "size", len(payload))
+		return
+	}
+	if err := s.db.Exec("select pg_notify(?, ?)", liveEventChannel, payload).Error; err != nil {
+		slog.Warn("Failed to notify live event", "type", event.Type, "dao_code", event.DaoCode, "proposal_id", event.ProposalID, "error", err)
+	}
+}
+
+func (s *LiveEventService) notificationPayload(event types.LiveEvent) (string, error) {
+	if event.Proposal != nil && event.Proposal.ActionsJSON != nil {
+		// the actions are not part of the events, they are resolved from the proposal
+		proposal := *event.Proposal
+		proposal.ActionsJSON = nil
+		event.Proposal = &proposal
+	}
+	payload, err := json.Marshal(liveEventNotification{Source: s.source, Event: event})
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+// Listen delivers the events published by the other replicas to the subscribers of this one until the context is done
+func (s *LiveEventService) Listen(ctx context.Context) {
+	if s.db == nil {
+		return
+	}
+	go func() {
+		for ctx.Err() == nil {
+			err := s.listen(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("Live event listener stopped, reconnecting", "error", err)
+			select {
+			case <-time.After(liveEventListenRetry):
+			case <-ctx.Done():
+			}
+		}
+	}()
+}
+
+func (s *LiveEventService) listen(ctx context.Context) error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("live events need a postgres connection, got %T", driverConn)
+		}
+		pgConn := stdlibConn.Conn()
+		if _, err := pgConn.Exec(ctx, "listen "+liveEventChannel); err != nil {
+			return fmt.Errorf("failed to listen: %w", err)
+		}
+		// the connection goes back to the pool, it must not keep receiving the notifications
+		defer pgConn.Exec(context.Background(), "unlisten "+liveEventChannel)
+
+		slog.Info("Listening for live events of the other replicas")
+		for {
+			notification, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			s.receive(notification.Payload)
+		}
+	})
+}
+
+// receive delivers an event notified by another replica
+func (s *LiveEventService) receive(payload string) {
+	var notification liveEventNotification
+	if err := json.Unmarshal([]byte(payload), &notification); err != nil {
+		slog.Warn("Failed to decode live event notification", "error", err)
+		return
+	}
+	if notification.Source == s.source {
+		return
+	}
+	s.deliver(notification.Event)
+}
+
+// deliver sends the event to the matching subscribers without blocking the caller
+func (s *LiveEventService) deliver(event types.LiveEvent) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for id, subscriber := range s.subscribers {
+		if !subscriber.matches(event) {
+			continue
+		}
+		select {
+		case subscriber.events <- event:
+		default:
+			slog.Warn("Live event subscriber is too slow, event dropped", "subscriber", id, "type", event.Type, "dao_code", event.DaoCode, "proposal_id", event.ProposalID)
+		}
+	}
+}
+
+// Subscribe returns the events matching the filter, the channel is closed when the context is done
+func (s *LiveEventService) Subscribe(ctx context.Context, filter types.LiveEventFilter) <-chan types.LiveEvent {
+	subscriber := &liveEventSubscriber{
+		filter: filter,
+		events: make(chan types.LiveEvent, liveEventBufferSize),
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	id := s.nextID
+	s.subscribers[id] = subscriber
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		// closing under the write lock guarantees that Publish never sends to a closed channel
+		s.mu.Lock()
+		delete(s.subscribers, id)
+		close(subscriber.events)
+		s.mu.Unlock()
+	}()
+	return subscriber.events
+}
+
+func (s *liveEventSubscriber) matches(event types.LiveEvent) bool {
+	if s.filter.Type != event.Type || s.filter.DaoCode != event.DaoCode {
+		return false
+	}
+	return s.filter.ProposalID == "" || s.filter.ProposalID == event.ProposalID
+}
+
+func (s *LiveEventService) ProposalCreated(ctx context.Context, daoCode string) <-chan *gqlmodels.Proposal {
+	return subscribeLiveEvent(ctx, s, types.LiveEventFilter{
+		Type:    types.LiveEventProposalCreated,
+		DaoCode: daoCode,
+	}, func(event types.LiveEvent) (*gqlmodels.Proposal, error) {
+		proposal := &gqlmodels.Proposal{}
+		if err := copier.Copy(proposal, event.Proposal); err != nil {
+			return nil, err
+		}
+		return proposal, nil
+	})
+}
+
+func (s *LiveEventService) ProposalStateChanged(ctx context.Context, daoCode string, proposalID *string) <-chan *gqlmodels.ProposalStateChangedEvent {
+	return subscribeLiveEvent(ctx, s, types.LiveEventFilter{
+		Type:       types.LiveEventProposalStateChanged,
+		DaoCode:    daoCode,
+		ProposalID: liveEventProposalID(proposalID),
+	}, func(event types.LiveEvent) (*gqlmodels.ProposalStateChangedEvent, error) {
+		output := &gqlmodels.ProposalStateChangedEvent{
+			DaoCode:    event.DaoCode,
+			ChainID:    int32(event.Proposal.ChainId),
+			ProposalID: event.ProposalID,
+			NewState:   gqlmodels.ProposalState(event.Proposal.State),
+			TimeEvent:  event.TimeEvent,
+		}
+		if event.OldState != nil && *event.OldState != dbmodels.ProposalStateUnknown {
+			oldState := gqlmodels.ProposalState(*event.OldState)
+			output.OldState = &oldState
+		}
+		return output, nil
+	})
+}
+
+func (s *LiveEventService) VoteCast(ctx context.Context, daoCode string, proposalID *string) <-chan *gqlmodels.VoteCastEvent {
+	return subscribeLiveEvent(ctx, s, types.LiveEventFilter{
+		Type:       types.LiveEventVoteCast,
+		DaoCode:    daoCode,
+		ProposalID: liveEventProposalID(proposalID),
+	}, func(event types.LiveEvent) (*gqlmodels.VoteCastEvent, error) {
+		vote := event.Vote
+		output := &gqlmodels.VoteCastEvent{
+			DaoCode:         event.DaoCode,
+			ChainID:         int32(event.Proposal.ChainId),
+			ProposalID:      event.ProposalID,
+			VoteID:          vote.ID,
+			Voter:           vote.Voter,
+			Support:         int32(vote.Support),
+			Weight:          vote.Weight,
+			TransactionHash: vote.TransactionHash,
+			BlockNumber:     vote.BlockNumber,
+			BlockTimestamp:  vote.BlockTimestamp,
+		}
+		if vote.Reason != "" {
+			output.Reason = &vote.Reason
+		}
+		return output, nil
+	})
+}
+
+// subscribeLiveEvent converts the matching events into graphql models, the returned channel is closed with the context
+func subscribeLiveEvent[T any](ctx context.Context, s *LiveEventService, filter types.LiveEventFilter, convert func(event types.LiveEvent) (*T, error)) <-chan *T {
+	events := s.Subscribe(ctx, filter)
+	output := make(chan *T, 1)
+	go func() {
+		defer close(output)
+		for event := range events {
+			item, err := convert(event)
+			if err != nil {
+				slog.Warn("Failed to convert live event", "type", event.Type, "error", err)
+				continue
+			}
+			select {
+			case output <- item:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
+
+func liveEventProposalID(proposalID *string) string {
+	if proposalID == nil {
+		return ""
+	}
+	return *proposalID
+}
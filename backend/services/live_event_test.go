@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+func newTestLiveEventService(source string) *LiveEventService {
+	return &LiveEventService{source: source, subscribers: make(map[uint64]*liveEventSubscriber)}
+}
+
+func TestLiveEventNotificationReachesOtherReplicas(t *testing.T) {
+	publisher := newTestLiveEventService("publisher")
+	replica := newTestLiveEventService("replica")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	filter := types.LiveEventFilter{Type: types.LiveEventProposalCreated, DaoCode: "dao"}
+	publisherEvents := publisher.Subscribe(ctx, filter)
+	replicaEvents := replica.Subscribe(ctx, filter)
+
+	actions := `[{"target":"0x1234"}]`
+	event := types.LiveEvent{
+		Type:       types.LiveEventProposalCreated,
+		DaoCode:    "dao",
+		ProposalID: "0x01",
+		Proposal:   &dbmodels.ProposalTracking{ID: "proposal", DaoCode: "dao", ProposalID: "0x01", Title: "Upgrade", State: dbmodels.ProposalStateUnknown, ActionsJSON: &actions},
+		TimeEvent:  time.Now().Truncate(time.Second),
+	}
+	payload, err := publisher.notificationPayload(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(payload, "0x1234") {
+		t.Fatal("expected the actions to be left out of the notification")
+	}
+	if event.Proposal.ActionsJSON == nil {
+		t.Fatal("expected the published proposal to keep its actions")
+	}
+
+	publisher.receive(payload)
+	replica.receive(payload)
+
+	select {
+	case received := <-replicaEvents:
+		if received.Type != event.Type || received.ProposalID != "0x01" || received.Proposal == nil ||
+			received.Proposal.Title != "Upgrade" || !received.TimeEvent.Equal(event.TimeEvent) {
+			t.Fatalf("expected the published event, got %+v", received)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the other replica to deliver the event")
+	}
+	select {
+	case received := <-publisherEvents:
+		t.Fatalf("expected the publisher to skip its own notification, got %+v", received)
+	default:
+	}
+
+	replica.receive("not json")
+	select {
+	case received := <-replicaEvents:
+		t.Fatalf("expected an invalid notification to be dropped, got %+v", received)
+	default:
+	}
+}
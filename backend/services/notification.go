@@ -1,6 +1,7 @@
 package services
 
 import (
+	"fmt"
 	"math"
 	"time"
 
@@ -8,6 +9,7 @@ import (
 
 	"github.com/ringecosystem/degov-apps/database"
 	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	gqlmodels "github.com/ringecosystem/degov-apps/graph/models"
 	"github.com/ringecosystem/degov-apps/internal/utils"
 	"github.com/ringecosystem/degov-apps/types"
 )
@@ -65,6 +67,16 @@ func (s *NotificationService) InspectEventWithProposal(input types.InspectNotifi
 	return &event, nil
 }
 
+// ListEventsWithProposal lists the events of the type of the proposal, e.g. the VOTE_END events of each lead time
+func (s *NotificationService) ListEventsWithProposal(input types.InspectNotificationEventInput) ([]dbmodels.NotificationEvent, error) {
+	var events []dbmodels.NotificationEvent
+	if err := s.db.Where("dao_code = ? AND proposal_id = ? AND type = ?", input.DaoCode, input.ProposalID, input.Type).
+		Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to list notification events: %w", err)
+	}
+	return events, nil
+}
+
 func (s *NotificationService) StoreRecords(records []dbmodels.NotificationRecord) error {
 	if len(records) == 0 {
 		return nil
@@ -183,13 +195,17 @@ func (s *NotificationService) ListLimitRecords(input types.ListLimitRecordsInput
 }
 
 func (s *NotificationService) UpdateRecordState(input types.UpdateRecordStateInput) error {
+	updates := map[string]interface{}{
+		"state": input.State,
+		"utime": time.Now(),
+	}
+	if input.Message != nil {
+		updates["message"] = *input.Message
+	}
 	return s.db.
 		Model(&dbmodels.NotificationRecord{}).
 		Where("id = ?", input.ID).
-		Updates(map[string]interface{}{
-			"state": input.State,
-			"utime": time.Now(),
-		}).Error
+		Updates(updates).Error
 }
 
 func (s *NotificationService) UpdateRecordRetryTimes(input types.UpdateRecordRetryTimes) error {
@@ -213,3 +229,197 @@ func (s *NotificationService) UpdateRecordRetryTimes(input types.UpdateRecordRet
 
 	return s.db.Model(&dbmodels.NotificationRecord{}).Where("id = ?", input.ID).Updates(updates).Error
 }
+
+// EnsureDeliveries returns the deliveries of the record for the given channels, creating the missing ones as pending.
+// deliveries of channels which are no longer in the list are left untouched and not returned
+func (s *NotificationService) EnsureDeliveries(record *dbmodels.NotificationRecord, channels []dbmodels.NotificationChannel) ([]dbmodels.NotificationDelivery, error) {
+	if len(channels) == 0 {
+		return nil, nil
+	}
+
+	var existing []dbmodels.NotificationDelivery
+	if err := s.db.Where("record_id = ?", record.ID).Find(&existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to list deliveries: %w", err)
+	}
+	existingByChannel := make(map[string]dbmodels.NotificationDelivery, len(existing))
+	for _, delivery := range existing {
+		existingByChannel[delivery.ChannelID] = delivery
+	}
+
+	deliveries := make([]dbmodels.NotificationDelivery, 0, len(channels))
+	var deliveriesToCreate []dbmodels.NotificationDelivery
+	for _, channel := range channels {
+		if delivery, ok := existingByChannel[channel.ID]; ok {
+			deliveries = append(deliveries, delivery)
+			continue
+		}
+		deliveriesToCreate = append(deliveriesToCreate, dbmodels.NotificationDelivery{
+			ID:           utils.NextIDString(),
+			RecordID:     record.ID,
+			ChannelID:    channel.ID,
+			ChannelType:  channel.ChannelType,
+			ChannelValue: channel.ChannelValue,
+			UserID:       record.UserID,
+			State:        dbmodels.NotificationDeliveryStatePending,
+			CTime:        time.Now(),
+		})
+	}
+
+	if len(deliveriesToCreate) > 0 {
+		if err := s.db.Create(&deliveriesToCreate).Error; err != nil {
+			return nil, fmt.Errorf("failed to create deliveries: %w", err)
+		}
+		deliveries = append(deliveries, deliveriesToCreate...)
+	}
+	return deliveries, nil
+}
+
+func (s *NotificationService) UpdateDelivery(input types.UpdateDeliveryInput) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"state":             input.State,
+		"times_attempt":     input.TimesAttempt,
+		"time_last_attempt": now,
+		"utime":             now,
+	}
+	if input.LastError != nil {
+		updates["last_error"] = *input.LastError
+	}
+	if input.ProviderMessageID != nil {
+		updates["provider_message_id"] = *input.ProviderMessageID
+	}
+	return s.db.Model(&dbmodels.NotificationDelivery{}).Where("id = ?", input.ID).Updates(updates).Error
+}
+
+// DeriveRecordState returns SENT_OK when every delivery succeeded, otherwise the record stays PENDING to be retried
+func (s *NotificationService) DeriveRecordState(deliveries []dbmodels.NotificationDelivery) dbmodels.NotificationRecordState {
+	for _, delivery := range deliveries {
+		if delivery.State != dbmodels.NotificationDeliveryStateSentOk {
+			return dbmodels.NotificationRecordStatePending
+		}
+	}
+	return dbmodels.NotificationRecordStateSentOk
+}
+
+const (
+	failedNotificationDefaultLimit = 50
+	failedNotificationMaxLimit     = 500
+)
+
+// applyFailedNotificationFilter applies the filter shared by failed events and records
+func applyFailedNotificationFilter(query *gorm.DB, filter *gqlmodels.FailedNotificationFilter) *gorm.DB {
+	if filter == nil {
+		return query
+	}
+	if filter.DaoCode != nil && *filter.DaoCode != "" {
+		query = query.Where("dao_code = ?", *filter.DaoCode)
+	}
+	if filter.Type != nil {
+		query = query.Where("type = ?", filter.Type.String())
+	}
+	if filter.TimeFrom != nil {
+		query = query.Where("ctime >= ?", *filter.TimeFrom)
+	}
+	if filter.TimeTo != nil {
+		query = query.Where("ctime < ?", *filter.TimeTo)
+	}
+	return query
+}
+
+// scopesFailedNotifications reports whether the filter narrows a requeue down, the type, limit and offset
+// alone still match every failed item
+func scopesFailedNotifications(filter *gqlmodels.FailedNotificationFilter) bool {
+	if filter == nil {
+		return false
+	}
+	return (filter.DaoCode != nil && *filter.DaoCode != "") || filter.TimeFrom != nil || filter.TimeTo != nil
+}
+
+func paginateFailedNotification(query *gorm.DB, filter *gqlmodels.FailedNotificationFilter) *gorm.DB {
+	limit := failedNotificationDefaultLimit
+	offset := 0
+	if filter != nil {
+		if filter.Limit != nil && *filter.Limit > 0 {
+			limit = int(*filter.Limit)
+		}
+		if filter.Offset != nil && *filter.Offset > 0 {
+			offset = int(*filter.Offset)
+		}
+	}
+	if limit > failedNotificationMaxLimit {
+		limit = failedNotificationMaxLimit
+	}
+	return query.Order("ctime desc").Limit(limit).Offset(offset)
+}
+
+func (s *NotificationService) ListFailedEvents(baseInput types.BasicInput[*gqlmodels.FailedNotificationFilter]) ([]dbmodels.NotificationEvent, error) {
+	var events []dbmodels.NotificationEvent
+	query := s.db.Model(&dbmodels.NotificationEvent{}).Where("state = ?", dbmodels.NotificationEventStateFailed)
+	query = applyFailedNotificationFilter(query, baseInput.Input)
+	if err := paginateFailedNotification(query, baseInput.Input).Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to list failed events: %w", err)
+	}
+	return events, nil
+}
+
+func (s *NotificationService) ListFailedRecords(baseInput types.BasicInput[*gqlmodels.FailedNotificationFilter]) ([]dbmodels.NotificationRecord, error) {
+	var records []dbmodels.NotificationRecord
+	query := s.db.Model(&dbmodels.NotificationRecord{}).Where("state = ?", dbmodels.NotificationRecordStateSentFail)
+	query = applyFailedNotificationFilter(query, baseInput.Input)
+	if err := paginateFailedNotification(query, baseInput.Input).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to list failed records: %w", err)
+	}
+	return records, nil
+}
+
+// RequeueFailedEvents moves failed events back to pending so that they are processed again immediately
+func (s *NotificationService) RequeueFailedEvents(baseInput types.BasicInput[gqlmodels.RequeueNotificationInput]) (int64, error) {
+	return s.requeueFailed(
+		&dbmodels.NotificationEvent{},
+		dbmodels.NotificationEventStateFailed,
+		dbmodels.NotificationEventStatePending,
+		baseInput,
+	)
+}
+
+// RequeueFailedRecords moves failed records back to pending, only their failed deliveries are sent again
+func (s *NotificationService) RequeueFailedRecords(baseInput types.BasicInput[gqlmodels.RequeueNotificationInput]) (int64, error) {
+	return s.requeueFailed(
+		&dbmodels.NotificationRecord{},
+		dbmodels.NotificationRecordStateSentFail,
+		dbmodels.NotificationRecordStatePending,
+		baseInput,
+	)
+}
+
+func (s *NotificationService) requeueFailed(model interface{}, failedState, pendingState interface{}, baseInput types.BasicInput[gqlmodels.RequeueNotificationInput]) (int64, error) {
+	input := baseInput.Input
+	if len(input.Ids) == 0 && !scopesFailedNotifications(input.Filter) {
+		return 0, fmt.Errorf("ids, daoCode or a time bound is required")
+	}
+
+	query := s.db.Model(model).Where("state = ?", failedState)
+	if len(input.Ids) > 0 {
+		query = query.Where("id IN ?", input.Ids)
+	}
+	query = applyFailedNotificationFilter(query, input.Filter)
+
+	operator := "unknown"
+	if baseInput.User != nil {
+		operator = baseInput.User.Address
+	}
+	now := time.Now()
+	note := fmt.Sprintf("[requeue] requeued by %s at %s", operator, now.UTC().Format(time.RFC3339))
+
+	result := query.Updates(map[string]interface{}{
+		"state":             pendingState,
+		"times_retry":       0,
+		"time_next_execute": now,
+		"utime":             now,
+		"message":           gorm.Expr("concat_ws(?, message, ?)", "\n\n-------\n", note),
+	})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to requeue: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	gqlmodels "github.com/ringecosystem/degov-apps/graph/models"
+	"github.com/ringecosystem/degov-apps/internal/utils"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+// notificationMessageSeparator separates the entries appended to dgv_notification_record.message
+const notificationMessageSeparator = "\n\n-------\n"
+
+var deliveryStatusCodeRegex = regexp.MustCompile(`non-2xx status: (\d{3})`)
+
+// NotificationHistory lists the notification records of the user with the state of every channel delivery
+func (s *NotificationService) NotificationHistory(baseInput types.BasicInput[types.ListNotificationHistoryInput]) (*gqlmodels.NotificationHistoryConnection, error) {
+	if baseInput.User == nil {
+		return nil, fmt.Errorf("not logged in")
+	}
+	input := baseInput.Input
+
+	first, err := connectionPageSize(input.First)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.db.Model(&dbmodels.NotificationRecord{}).Where("user_id = ?", baseInput.User.Id)
+	if input.State != nil {
+		query = query.Where("state = ?", *input.State)
+	}
+	if input.After != nil && *input.After != "" {
+		cursorTime, cursorID, err := utils.DecodeCursor(*input.After)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("(ctime, id) < (?, ?)", cursorTime, cursorID)
+	}
+
+	// one more row tells whether there is a next page
+	var records []*dbmodels.NotificationRecord
+	err = query.
+		Order("ctime desc, id desc").
+		Limit(first + 1).
+		Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification history: %w", err)
+	}
+
+	connection := &gqlmodels.NotificationHistoryConnection{
+		Edges:    make([]*gqlmodels.NotificationHistoryEdge, 0, len(records)),
+		PageInfo: &gqlmodels.PageInfo{HasNextPage: len(records) > first},
+	}
+	if len(records) > first {
+		records = records[:first]
+	}
+	if len(records) == 0 {
+		return connection, nil
+	}
+
+	recordIDs := make([]string, 0, len(records))
+	for _, record := range records {
+		recordIDs = append(recordIDs, record.ID)
+	}
+	// the inbox is shown by the notifications query, it is not a delivery the user has to care about
+	var deliveries []dbmodels.NotificationDelivery
+	err = s.db.
+		Where("record_id IN ? AND channel_type <> ?", recordIDs, dbmodels.NotificationChannelTypeInbox).
+		Order("ctime asc").
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification deliveries: %w", err)
+	}
+	deliveriesByRecord := make(map[string][]dbmodels.NotificationDelivery, len(records))
+	for _, delivery := range deliveries {
+		deliveriesByRecord[delivery.RecordID] = append(deliveriesByRecord[delivery.RecordID], delivery)
+	}
+
+	for _, record := range records {
+		connection.Edges = append(connection.Edges, &gqlmodels.NotificationHistoryEdge{
+			Cursor: utils.EncodeCursor(record.CTime, record.ID),
+			Node:   convertNotificationHistoryItem(record, deliveriesByRecord[record.ID]),
+		})
+	}
+	endCursor := connection.Edges[len(connection.Edges)-1].Cursor
+	connection.PageInfo.EndCursor = &endCursor
+	return connection, nil
+}
+
+func convertNotificationHistoryItem(record *dbmodels.NotificationRecord, deliveries []dbmodels.NotificationDelivery) *gqlmodels.NotificationHistoryItem {
+	item := &gqlmodels.NotificationHistoryItem{
+		ID:         record.ID,
+		Type:       gqlmodels.FeatureName(record.Type),
+		DaoCode:    record.DaoCode,
+		ChainID:    int32(record.ChainID),
+		ProposalID: record.ProposalID,
+		Title:      record.InboxTitle,
+		State:      gqlmodels.NotificationDeliveryState(record.State),
+		TimesRetry: int32(record.TimesRetry),
+		Deliveries: make([]*gqlmodels.NotificationHistoryDelivery, 0, len(deliveries)),
+		Ctime:      record.CTime,
+		Utime:      record.UTime,
+	}
+
+	for _, delivery := range deliveries {
+		historyDelivery := &gqlmodels.NotificationHistoryDelivery{
+			ChannelID:       delivery.ChannelID,
+			ChannelType:     gqlmodels.NotificationChannelType(delivery.ChannelType),
+			ChannelValue:    displayChannelValue(delivery.ChannelType, delivery.ChannelValue),
+			State:           gqlmodels.NotificationDeliveryState(delivery.State),
+			TimesAttempt:    int32(delivery.TimesAttempt),
+			TimeLastAttempt: delivery.TimeLastAttempt,
+		}
+		if delivery.State == dbmodels.NotificationDeliveryStateSentFail && delivery.LastError != nil {
+			reason := SanitizeDeliveryError(*delivery.LastError)
+			historyDelivery.Reason = &reason
+			if item.Reason == nil {
+				item.Reason = &reason
+			}
+		}
+		item.Deliveries = append(item.Deliveries, historyDelivery)
+	}
+
+	// failures before any delivery, e.g. the channels could not be loaded, are only kept in the record message
+	if item.Reason == nil && record.State != dbmodels.NotificationRecordStateSentOk && record.Message != nil && *record.Message != "" {
+		entries := strings.Split(*record.Message, notificationMessageSeparator)
+		reason := SanitizeDeliveryError(entries[len(entries)-1])
+		item.Reason = &reason
+	}
+	return item
+}
+
+// displayChannelValue hides the path of url channels, incoming webhook urls of discord and slack embed their token
+func displayChannelValue(channelType dbmodels.NotificationChannelType, value string) string {
+	switch channelType {
+	case dbmodels.NotificationChannelTypeWebhook, dbmodels.NotificationChannelTypeDiscord, dbmodels.NotificationChannelTypeSlack:
+		if host := utils.ExtractHost(value); host != "" {
+			return host
+		}
+		return "***"
+	default:
+		return value
+	}
+}
+
+// SanitizeDeliveryError turns a raw delivery error into a reason which can be shown to users.
+// raw errors may contain provider responses, urls and internal details, so only a known category is returned
+func SanitizeDeliveryError(rawError string) string {
+	message := strings.ToLower(rawError)
+
+	if matches := deliveryStatusCodeRegex.FindStringSubmatch(message); len(matches) == 2 {
+		switch code := matches[1]; {
+		case code == "401" || code == "403":
+			return "The destination rejected the notification, please check the channel settings"
+		case code == "404" || code == "410":
+			return "The destination no longer exists, please update the channel"
+		case code == "429":
+			return "The destination is rate limiting notifications, it will be retried"
+		case strings.HasPrefix(code, "5"):
+			return "The destination returned a server error, it will be retried"
+		default:
+			return fmt.Sprintf("The destination refused the notification (HTTP %s)", code)
+		}
+	}
+
+	switch {
+	case strings.Contains(message, "rcpt to rejected"):
+		return "The mail server rejected the email address"
+	case strings.Contains(message, "chat not found"), strings.Contains(message, "bot was blocked"), strings.Contains(message, "user is deactivated"):
+		return "The Telegram chat can not be reached, the bot may have been blocked"
+	case strings.Contains(message, "no notifier registered"), strings.Contains(message, "no signing secret"):
+		return "The channel is not available on this server"
+	case strings.Contains(message, "timeout"), strings.Contains(message, "deadline exceeded"):
+		return "The destination did not respond in time, it will be retried"
+	case strings.Contains(message, "no such host"), strings.Contains(message, "connection refused"), strings.Contains(message, "failed to connect"), strings.Contains(message, "failed to send request"):
+		return "The destination could not be reached, it will be retried"
+	case strings.Contains(message, "failed to list user channels"), strings.Contains(message, "failed to get"), strings.Contains(message, "failed to render"), strings.Contains(message, "failed to inspect"):
+		return "The notification could not be prepared, it will be retried"
+	default:
+		return "The notification could not be delivered"
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeDeliveryError(t *testing.T) {
+	const (
+		hookURL = "https://discord.com/api/webhooks/123/s3cr3t-hook-token"
+		body    = `{"message":"Invalid Webhook Token","internal_trace":"abc-secret-trace"}`
+	)
+	tests := map[string]string{
+		"discord: non-2xx status: 401 " + body:                                                                   "The destination rejected the notification, please check the channel settings",
+		"webhook: non-2xx status: 403 forbidden for " + hookURL:                                                  "The destination rejected the notification, please check the channel settings",
+		"slack: non-2xx status: 404 no_team " + hookURL:                                                          "The destination no longer exists, please update the channel",
+		"webhook: non-2xx status: 410 gone":                                                                      "The destination no longer exists, please update the channel",
+		"discord: non-2xx status: 429 " + body:                                                                   "The destination is rate limiting notifications, it will be retried",
+		"webhook: non-2xx status: 502 <html>bad gateway at 10.0.0.7</html>":                                      "The destination returned a server error, it will be retried",
+		"sendgrid: non-2xx status: 400 " + body:                                                                  "The destination refused the notification (HTTP 400)",
+		"webhook: non-2xx status: 302 Location: " + hookURL:                                                      "The destination refused the notification (HTTP 302)",
+		"smtp: RCPT TO rejected: 550 5.1.1 <member@example.com>: user unknown":                                   "The mail server rejected the email address",
+		"[telegram] sendMessage: Bad Request: chat not found (chat 123456789)":                                   "The Telegram chat can not be reached, the bot may have been blocked",
+		"[telegram] sendMessage: Forbidden: bot was blocked by the user":                                         "The Telegram chat can not be reached, the bot may have been blocked",
+		"no notifier registered for channel type: SMS":                                                           "The channel is not available on this server",
+		"no signing secret available for webhook " + hookURL:                                                     "The channel is not available on this server",
+		`webhook: failed to send request: Post "` + hookURL + `": context deadline exceeded`:                     "The destination did not respond in time, it will be retried",
+		`webhook: failed to send request: Post "` + hookURL + `": dial tcp: lookup discord.com: no such host`:    "The destination could not be reached, it will be retried",
+		`webhook: failed to send request: Post "` + hookURL + `": webhook address 169.254.169.254 is not public`: "The destination could not be reached, it will be retried",
+		"smtp: failed to connect smtp.internal:25: dial tcp 10.0.0.5:25: connection refused":                     "The destination could not be reached, it will be retried",
+		"failed to render template proposal_new.md: template: missing key token":                                 "The notification could not be prepared, it will be retried",
+		"smtp: failed to authenticate: 535 5.7.8 password=hunter2 rejected":                                      "The notification could not be delivered",
+		body: "The notification could not be delivered",
+		"":   "The notification could not be delivered",
+	}
+	leaks := []string{"://", "discord.com", "s3cr3t", "webhooks/123", "trace", "10.0.0", "169.254", "example.com", "123456789", "hunter2", "{", "<"}
+	for rawError, expected := range tests {
+		reason := SanitizeDeliveryError(rawError)
+		if reason != expected {
+			t.Errorf("%q: expected %q, got %q", rawError, expected, reason)
+		}
+		for _, leak := range leaks {
+			if strings.Contains(strings.ToLower(reason), leak) {
+				t.Errorf("%q: the reason %q leaks %q", rawError, reason, leak)
+			}
+		}
+	}
+}
@@ -1,59 +1,234 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
 	"log/slog"
+	"net"
+	"net/http"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
 	"github.com/ringecosystem/degov-apps/internal/config"
+	"github.com/ringecosystem/degov-apps/internal/utils"
 	"github.com/ringecosystem/degov-apps/types"
-	"github.com/sendgrid/sendgrid-go"
-	"github.com/sendgrid/sendgrid-go/helpers/mail"
-	"gorm.io/gorm"
 )
 
-var globalNotifier *NotifierService
-
-func getNotifier() *NotifierService {
-	if globalNotifier == nil {
-		globalNotifier = &NotifierService{}
-	}
-	return globalNotifier
+// Notifier delivers a rendered notification through one channel type.
+// Implementations must return an error when the delivery failed so that the dispatcher can retry it
+type Notifier interface {
+	ChannelType() dbmodels.NotificationChannelType
+	Notify(input types.NotifyInput) (*types.NotifyOutput, error)
 }
 
+var (
+	globalNotifier *NotifierService
+	notifierOnce   sync.Once
+)
+
 type NotifierService struct {
-	db *gorm.DB
+	mu        sync.RWMutex
+	notifiers map[dbmodels.NotificationChannelType]Notifier
 }
 
 func NewNotifierService() *NotifierService {
-	return getNotifier()
+	notifierOnce.Do(func() {
+		globalNotifier = &NotifierService{
+			notifiers: make(map[dbmodels.NotificationChannelType]Notifier),
+		}
+		for _, notifier := range defaultNotifiers() {
+			globalNotifier.Register(notifier)
+		}
+		// the inbox lives in the database, it is never replaced by a sink
+		globalNotifier.Register(NewInboxNotifier())
+	})
+	return globalNotifier
+}
+
+// Register adds or replaces the notifier of its channel type
+func (n *NotifierService) Register(notifier Notifier) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notifiers[notifier.ChannelType()] = notifier
+}
+
+func (n *NotifierService) Notify(input types.NotifyInput) (*types.NotifyOutput, error) {
+	n.mu.RLock()
+	notifier, ok := n.notifiers[input.Type]
+	n.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no notifier registered for channel type: %s", input.Type)
+	}
+	return notifier.Notify(input)
 }
 
-func (n *NotifierService) Notify(input types.NotifyInput) error {
-	sendgridApiKey := config.GetString("SENDGRID_API_KEY")
-	if sendgridApiKey != "" {
-		if err := n.notifyUseSendGrid(input); err != nil {
-			slog.Warn("Failed to send notification [sendgrid]", "error", err)
+// defaultNotifiers builds the notifiers from configuration.
+// NOTIFIER_SINK=file|log replaces every channel with a local sink, which is useful for development
+func defaultNotifiers() []Notifier {
+	channelTypes := []dbmodels.NotificationChannelType{
+		dbmodels.NotificationChannelTypeEmail,
+		dbmodels.NotificationChannelTypeWebhook,
+		dbmodels.NotificationChannelTypeTelegram,
+		dbmodels.NotificationChannelTypeDiscord,
+		dbmodels.NotificationChannelTypeSlack,
+	}
+
+	sink := strings.ToLower(config.GetString("NOTIFIER_SINK"))
+	switch sink {
+	case "file", "log":
+		slog.Warn("Notifications are delivered to a local sink instead of the real channels", "sink", sink)
+		notifiers := make([]Notifier, 0, len(channelTypes))
+		for _, channelType := range channelTypes {
+			if sink == "file" {
+				notifiers = append(notifiers, NewFileSinkNotifier(channelType, config.GetString("NOTIFIER_SINK_PATH")))
+			} else {
+				notifiers = append(notifiers, NewLogSinkNotifier(channelType))
+			}
 		}
+		return notifiers
+	case "":
+	default:
+		slog.Warn("Unknown notifier sink, ignored", "sink", sink)
 	}
-	return nil
+
+	httpClient := newWebhookHTTPClient()
+	notifiers := []Notifier{
+		NewWebhookNotifier(httpClient),
+		NewTelegramNotifier(),
+		NewDiscordNotifier(httpClient),
+		NewSlackNotifier(httpClient),
+	}
+	if emailNotifier := newEmailNotifier(); emailNotifier != nil {
+		notifiers = append(notifiers, emailNotifier)
+	} else {
+		slog.Warn("No email provider configured, email notifications are disabled")
+	}
+	return notifiers
+}
+
+// newWebhookHTTPClient builds the client of the user supplied webhook urls. It only connects to public addresses,
+// checked on the resolved address of every connection so dns rebinding can not reach internal services, and it
+// does not follow redirects
+func newWebhookHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !allowedWebhookIP(ip) {
+				return fmt.Errorf("webhook address %s is not public", host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConnsPerHost: 4,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// nonPublicNetworks are the ranges besides loopback, private, link-local (incl. 169.254.169.254) and multicast
+// which must not be reached through webhooks
+var nonPublicNetworks = func() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",     // this network
+		"100.64.0.0/10", // carrier-grade nat
+		"192.0.0.0/24",  // ietf protocol assignments
+		"198.18.0.0/15", // benchmarking
+		"240.0.0.0/4",   // reserved
+		"64:ff9b::/96",  // nat64, may embed an internal ipv4 address
+		"2001:db8::/32", // documentation
+	} {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// allowedWebhookIP reports whether webhooks may be delivered to the ip, WEBHOOK_ALLOW_PRIVATE_ADDRESSES allows
+// local receivers during development
+func allowedWebhookIP(ip net.IP) bool {
+	if config.GetBool("WEBHOOK_ALLOW_PRIVATE_ADDRESSES") {
+		return true
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
 }
 
-func (n *NotifierService) notifyUseSendGrid(input types.NotifyInput) error {
-	template := input.Template
-	from := mail.NewEmail(config.GetString("SENDGRID_FROM_USER"), config.GetString("SENDGRID_FROM_EMAIL"))
-	nameParts := strings.Split(input.To, "@")
-	name := nameParts[0]
-	to := mail.NewEmail(name, input.To)
-	subject := template.Title
-	plainTextContent := template.PlainTextContent
-	htmlContent := template.RichTextContent
-	message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
-	client := sendgrid.NewSendClient(config.GetString("SENDGRID_API_KEY"))
-	response, err := client.Send(message)
+// checkWebhookHost resolves the host of a webhook url and rejects it unless every address is public
+func checkWebhookHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if !allowedWebhookIP(ip) {
+			return fmt.Errorf("address %s is not public", host)
+		}
+		return nil
+	}
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
 	if err != nil {
-		slog.Error("Failed to send notification", "error", err)
-		return err
+		return fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	if len(addresses) == 0 {
+		return fmt.Errorf("%s has no address", host)
+	}
+	for _, address := range addresses {
+		if !allowedWebhookIP(address.IP) {
+			return fmt.Errorf("%s resolves to the address %s which is not public", host, address.IP)
+		}
 	}
-	slog.Info("Notification sent successfully", "to", input.To, "status_code", response.StatusCode)
 	return nil
 }
+
+// postJSON posts the body to the url and returns the status code and response body, non-2xx responses are returned as error
+func postJSON(httpClient *http.Client, url string, body []byte, headers map[string]string) (int, []byte, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "degov-app/1.0")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, respBody, fmt.Errorf("non-2xx status: %d %s", resp.StatusCode, utils.TruncateText(strings.TrimSpace(string(respBody)), 512))
+	}
+	return resp.StatusCode, respBody, nil
+}
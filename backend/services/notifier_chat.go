@@ -0,0 +1,255 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	"github.com/ringecosystem/degov-apps/internal"
+	"github.com/ringecosystem/degov-apps/internal/config"
+	"github.com/ringecosystem/degov-apps/internal/utils"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+// DiscordNotifier posts embeds to a discord incoming webhook
+type DiscordNotifier struct {
+	httpClient *http.Client
+}
+
+func NewDiscordNotifier(httpClient *http.Client) *DiscordNotifier {
+	return &DiscordNotifier{
+		httpClient: httpClient,
+	}
+}
+
+func (n *DiscordNotifier) ChannelType() dbmodels.NotificationChannelType {
+	return dbmodels.NotificationChannelTypeDiscord
+}
+
+func (n *DiscordNotifier) Notify(input types.NotifyInput) (*types.NotifyOutput, error) {
+	template := input.Template
+	embed := types.DiscordEmbed{
+		Title:     utils.TruncateText(template.Title, 256),
+		Color:     proposalStateColor(""),
+		Footer:    &types.DiscordEmbedFooter{Text: config.GetDegovSiteConfig().Name},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if summary := template.Summary; summary != nil {
+		embed.Title = utils.TruncateText(summary.ProposalTitle, 256)
+		embed.URL = summary.ProposalLink
+		embed.Color = proposalStateColor(summary.State)
+		embed.Description = fmt.Sprintf("**%s**\n%s", summary.DaoName, summary.Headline)
+		embed.Fields = []types.DiscordEmbedField{
+			{Name: "State", Value: proposalStateBadge(summary.State), Inline: true},
+			{Name: "For", Value: utils.FormatPercent(summary.PercentFor), Inline: true},
+			{Name: "Against", Value: utils.FormatPercent(summary.PercentAgainst), Inline: true},
+			{Name: "Abstain", Value: utils.FormatPercent(summary.PercentAbstain), Inline: true},
+		}
+		links := make([]string, 0, len(summary.Links))
+		for _, link := range summary.Links {
+			links = append(links, fmt.Sprintf("[%s](%s)", link.Name, link.URL))
+		}
+		if len(links) > 0 {
+			embed.Fields = append(embed.Fields, types.DiscordEmbedField{Name: "Links", Value: strings.Join(links, " · ")})
+		}
+	} else {
+		embed.Description = utils.TruncateText(template.PlainTextContent, 4096)
+	}
+
+	body, err := json.Marshal(types.DiscordWebhookPayload{
+		Username: config.GetDegovSiteConfig().Name,
+		Embeds:   []types.DiscordEmbed{embed},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal discord payload: %w", err)
+	}
+	// wait=true makes discord return the created message, so that its id can be recorded
+	_, respBody, err := postJSON(n.httpClient, discordWaitURL(input.To), body, nil)
+	if err != nil {
+		return nil, fmt.Errorf("discord: %w", err)
+	}
+
+	output := &types.NotifyOutput{}
+	var message struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(respBody, &message); err == nil && message.ID != "" {
+		output.ProviderMessageID = &message.ID
+	}
+	slog.Info("Discord notification sent successfully")
+	return output, nil
+}
+
+func discordWaitURL(webhookURL string) string {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil {
+		return webhookURL
+	}
+	query := parsed.Query()
+	query.Set("wait", "true")
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// SlackNotifier posts Block Kit messages to a slack incoming webhook
+type SlackNotifier struct {
+	httpClient *http.Client
+}
+
+func NewSlackNotifier(httpClient *http.Client) *SlackNotifier {
+	return &SlackNotifier{
+		httpClient: httpClient,
+	}
+}
+
+func (n *SlackNotifier) ChannelType() dbmodels.NotificationChannelType {
+	return dbmodels.NotificationChannelTypeSlack
+}
+
+func (n *SlackNotifier) Notify(input types.NotifyInput) (*types.NotifyOutput, error) {
+	template := input.Template
+	payload := types.SlackWebhookPayload{
+		Text: template.Title,
+	}
+
+	if summary := template.Summary; summary != nil {
+		payload.Blocks = []types.SlackBlock{
+			{
+				Type: "header",
+				Text: &types.SlackText{Type: "plain_text", Text: utils.TruncateText(summary.ProposalTitle, 150)},
+			},
+			{
+				Type: "section",
+				Text: &types.SlackText{Type: "mrkdwn", Text: fmt.Sprintf("*%s*\n%s", slackEscape(summary.DaoName), slackEscape(summary.Headline))},
+			},
+			{
+				Type: "section",
+				Fields: []types.SlackText{
+					{Type: "mrkdwn", Text: "*State*\n" + proposalStateBadge(summary.State)},
+					{Type: "mrkdwn", Text: "*For*\n" + utils.FormatPercent(summary.PercentFor)},
+					{Type: "mrkdwn", Text: "*Against*\n" + utils.FormatPercent(summary.PercentAgainst)},
+					{Type: "mrkdwn", Text: "*Abstain*\n" + utils.FormatPercent(summary.PercentAbstain)},
+				},
+			},
+		}
+		if len(summary.Links) > 0 {
+			buttons := make([]types.SlackElement, 0, len(summary.Links))
+			for _, link := range summary.Links {
+				buttons = append(buttons, types.SlackElement{
+					Type: "button",
+					Text: &types.SlackText{Type: "plain_text", Text: link.Name},
+					URL:  link.URL,
+				})
+			}
+			payload.Blocks = append(payload.Blocks, types.SlackBlock{Type: "actions", Elements: buttons})
+		}
+	} else {
+		payload.Blocks = []types.SlackBlock{
+			{
+				Type: "section",
+				Text: &types.SlackText{Type: "mrkdwn", Text: utils.TruncateText(slackEscape(template.PlainTextContent), 3000)},
+			},
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal slack payload: %w", err)
+	}
+	if _, _, err := postJSON(n.httpClient, input.To, body, nil); err != nil {
+		return nil, fmt.Errorf("slack: %w", err)
+	}
+
+	slog.Info("Slack notification sent successfully")
+	return &types.NotifyOutput{}, nil
+}
+
+func proposalStateBadge(state dbmodels.ProposalState) string {
+	switch state {
+	case dbmodels.ProposalStatePending:
+		return "🕒 Pending"
+	case dbmodels.ProposalStateActive:
+		return "🟢 Active"
+	case dbmodels.ProposalStateSucceeded:
+		return "✅ Succeeded"
+	case dbmodels.ProposalStateQueued:
+		return "⏳ Queued"
+	case dbmodels.ProposalStateExecuted:
+		return "🚀 Executed"
+	case dbmodels.ProposalStateDefeated:
+		return "❌ Defeated"
+	case dbmodels.ProposalStateCanceled:
+		return "🚫 Canceled"
+	case dbmodels.ProposalStateExpired:
+		return "⌛ Expired"
+	default:
+		return "⚪️ Unknown"
+	}
+}
+
+// proposalStateColor is the discord embed color of the state
+func proposalStateColor(state dbmodels.ProposalState) int {
+	switch state {
+	case dbmodels.ProposalStateActive:
+		return 0x2ecc71
+	case dbmodels.ProposalStateSucceeded, dbmodels.ProposalStateQueued, dbmodels.ProposalStateExecuted:
+		return 0x3498db
+	case dbmodels.ProposalStateDefeated, dbmodels.ProposalStateCanceled, dbmodels.ProposalStateExpired:
+		return 0xe74c3c
+	default:
+		return 0x95a5a6
+	}
+}
+
+// slackEscape escapes the control characters of slack mrkdwn
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// TelegramNotifier sends html messages rendered from the .md templates through the bot
+type TelegramNotifier struct{}
+
+func NewTelegramNotifier() *TelegramNotifier {
+	return &TelegramNotifier{}
+}
+
+func (n *TelegramNotifier) ChannelType() dbmodels.NotificationChannelType {
+	return dbmodels.NotificationChannelTypeTelegram
+}
+
+func (n *TelegramNotifier) Notify(input types.NotifyInput) (*types.NotifyOutput, error) {
+	bot := NewTelegramBot()
+	if bot == nil {
+		return nil, fmt.Errorf("telegram bot is not configured")
+	}
+
+	text := fmt.Sprintf(
+		"<b>%s</b>\n\n%s",
+		strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(input.Template.Title),
+		internal.TelegramHTMLFromMarkdown(input.Template.PlainTextContent),
+	)
+	message, err := bot.SendMessage(input.To, internal.TruncateTelegramMessage(text))
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Telegram notification sent successfully", "chat_id", input.To)
+	messageID := strconv.FormatInt(message.MessageID, 10)
+	return &types.NotifyOutput{ProviderMessageID: &messageID}, nil
+}
+
+// NewTelegramBot returns the configured telegram bot, nil if TELEGRAM_BOT_TOKEN is not set
+func NewTelegramBot() *internal.TelegramBot {
+	token := config.GetString("TELEGRAM_BOT_TOKEN")
+	if token == "" {
+		return nil
+	}
+	return internal.NewTelegramBot(config.GetString("TELEGRAM_BOT_API_URL"), token)
+}
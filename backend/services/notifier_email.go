@@ -0,0 +1,258 @@
+package services
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	"github.com/ringecosystem/degov-apps/internal/config"
+	"github.com/ringecosystem/degov-apps/internal/utils"
+	"github.com/ringecosystem/degov-apps/types"
+	"github.com/sendgrid/sendgrid-go"
+	sgmail "github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// newEmailNotifier picks the email provider by EMAIL_PROVIDER (sendgrid, smtp), when it is not set the
+// provider is detected from SENDGRID_API_KEY and SMTP_HOST. nil is returned if no provider is configured
+func newEmailNotifier() Notifier {
+	provider := strings.ToLower(config.GetString("EMAIL_PROVIDER"))
+	if provider == "" {
+		switch {
+		case config.GetString("SENDGRID_API_KEY") != "":
+			provider = "sendgrid"
+		case config.GetString("SMTP_HOST") != "":
+			provider = "smtp"
+		}
+	}
+
+	switch provider {
+	case "sendgrid":
+		return NewSendGridNotifier(config.GetString("SENDGRID_API_KEY"))
+	case "smtp":
+		return NewSMTPNotifier(types.SMTPConfig{
+			Host:      config.GetString("SMTP_HOST"),
+			Port:      config.GetString("SMTP_PORT"),
+			Username:  config.GetString("SMTP_USERNAME"),
+			Password:  config.GetString("SMTP_PASSWORD"),
+			TLSMode:   strings.ToLower(config.GetString("SMTP_TLS_MODE")),
+			FromUser:  config.GetStringWithDefault("SMTP_FROM_USER", config.GetString("SENDGRID_FROM_USER")),
+			FromEmail: config.GetStringWithDefault("SMTP_FROM_EMAIL", config.GetString("SENDGRID_FROM_EMAIL")),
+		})
+	case "":
+		return nil
+	default:
+		slog.Warn("Unknown email provider", "provider", provider)
+		return nil
+	}
+}
+
+type SendGridNotifier struct {
+	client *sendgrid.Client
+}
+
+func NewSendGridNotifier(apiKey string) *SendGridNotifier {
+	return &SendGridNotifier{
+		client: sendgrid.NewSendClient(apiKey),
+	}
+}
+
+func (n *SendGridNotifier) ChannelType() dbmodels.NotificationChannelType {
+	return dbmodels.NotificationChannelTypeEmail
+}
+
+func (n *SendGridNotifier) Notify(input types.NotifyInput) (*types.NotifyOutput, error) {
+	template := input.Template
+	from := sgmail.NewEmail(config.GetString("SENDGRID_FROM_USER"), config.GetString("SENDGRID_FROM_EMAIL"))
+	nameParts := strings.Split(input.To, "@")
+	name := nameParts[0]
+	to := sgmail.NewEmail(name, input.To)
+	subject := template.Title
+	plainTextContent := template.PlainTextContent
+	htmlContent := template.RichTextContent
+	message := sgmail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
+	for key, value := range listUnsubscribeHeaders(template) {
+		message.SetHeader(key, value)
+	}
+	response, err := n.client.Send(message)
+	if err != nil {
+		return nil, fmt.Errorf("sendgrid: failed to send email: %w", err)
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, fmt.Errorf("sendgrid: non-2xx status: %d %s", response.StatusCode, utils.TruncateText(response.Body, 512))
+	}
+	slog.Info("Notification sent successfully", "to", input.To, "status_code", response.StatusCode)
+
+	output := &types.NotifyOutput{}
+	if messageIDs := response.Headers["X-Message-Id"]; len(messageIDs) > 0 {
+		output.ProviderMessageID = &messageIDs[0]
+	}
+	return output, nil
+}
+
+// SMTPNotifier sends multipart (plain text + html) emails through any smtp server
+type SMTPNotifier struct {
+	config types.SMTPConfig
+}
+
+func NewSMTPNotifier(smtpConfig types.SMTPConfig) *SMTPNotifier {
+	if smtpConfig.Port == "" {
+		smtpConfig.Port = "587"
+	}
+	if smtpConfig.TLSMode == "" {
+		smtpConfig.TLSMode = "starttls"
+	}
+	return &SMTPNotifier{
+		config: smtpConfig,
+	}
+}
+
+func (n *SMTPNotifier) ChannelType() dbmodels.NotificationChannelType {
+	return dbmodels.NotificationChannelTypeEmail
+}
+
+func (n *SMTPNotifier) Notify(input types.NotifyInput) (*types.NotifyOutput, error) {
+	message, messageID, err := n.buildMessage(input)
+	if err != nil {
+		return nil, fmt.Errorf("smtp: failed to build message: %w", err)
+	}
+
+	if err := n.send(input.To, message); err != nil {
+		return nil, fmt.Errorf("smtp: %w", err)
+	}
+	slog.Info("Notification sent successfully [smtp]", "to", input.To)
+	return &types.NotifyOutput{ProviderMessageID: &messageID}, nil
+}
+
+func (n *SMTPNotifier) send(to string, message []byte) error {
+	cfg := n.config
+	addr := net.JoinHostPort(cfg.Host, cfg.Port)
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+
+	var conn net.Conn
+	var err error
+	if cfg.TLSMode == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: cfg.Host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect %s: %w", addr, err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	if cfg.TLSMode == "starttls" {
+		if err := client.StartTLS(&tls.Config{ServerName: cfg.Host}); err != nil {
+			return fmt.Errorf("failed to start tls: %w", err)
+		}
+	}
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+	if err := client.Mail(cfg.FromEmail); err != nil {
+		return fmt.Errorf("MAIL FROM rejected: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("RCPT TO rejected: %w", err)
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("DATA rejected: %w", err)
+	}
+	if _, err := writer.Write(message); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("message rejected: %w", err)
+	}
+	return client.Quit()
+}
+
+// buildMessage returns the raw message and its Message-ID
+func (n *SMTPNotifier) buildMessage(input types.NotifyInput) ([]byte, string, error) {
+	template := input.Template
+	from := mail.Address{Name: n.config.FromUser, Address: n.config.FromEmail}
+	to := mail.Address{Address: input.To}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{contentType: "text/plain; charset=UTF-8", content: template.PlainTextContent},
+		{contentType: "text/html; charset=UTF-8", content: template.RichTextContent},
+	}
+	for _, part := range parts {
+		if part.content == "" {
+			continue
+		}
+		partWriter, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		qp := quotedprintable.NewWriter(partWriter)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, "", err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+
+	messageID := fmt.Sprintf("%s@%s", utils.NextIDString(), n.config.Host)
+	var message bytes.Buffer
+	headers := []string{
+		"From: " + from.String(),
+		"To: " + to.String(),
+		"Subject: " + mime.QEncoding.Encode("utf-8", template.Title),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		fmt.Sprintf("Message-ID: <%s>", messageID),
+		"MIME-Version: 1.0",
+		fmt.Sprintf("Content-Type: multipart/alternative; boundary=%q", writer.Boundary()),
+	}
+	for key, value := range listUnsubscribeHeaders(template) {
+		headers = append(headers, key+": "+value)
+	}
+	message.WriteString(strings.Join(headers, "\r\n"))
+	message.WriteString("\r\n\r\n")
+	message.Write(body.Bytes())
+	return message.Bytes(), messageID, nil
+}
+
+// listUnsubscribeHeaders returns the RFC 8058 one-click unsubscribe headers, empty for emails without unsubscribe link
+func listUnsubscribeHeaders(template *types.TemplateOutput) map[string]string {
+	if template.ListUnsubscribeURL == "" {
+		return nil
+	}
+	return map[string]string{
+		"List-Unsubscribe":      fmt.Sprintf("<%s>", template.ListUnsubscribeURL),
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/ringecosystem/degov-apps/database"
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	"github.com/ringecosystem/degov-apps/internal/utils"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+// inboxChannelID is the channel id of the inbox deliveries, the pseudo channel has no row of its own
+const inboxChannelID = "INBOX"
+
+// NewInboxChannel returns the inbox pseudo channel of the user, the dispatcher delivers every record to it
+func NewInboxChannel(userID, userAddress string) dbmodels.NotificationChannel {
+	return dbmodels.NotificationChannel{
+		ID:           inboxChannelID,
+		UserID:       userID,
+		UserAddress:  userAddress,
+		Verified:     1,
+		ChannelType:  dbmodels.NotificationChannelTypeInbox,
+		ChannelValue: userID,
+	}
+}
+
+// InboxNotifier stores the markdown rendering of the record so that the inbox does not render it on every read
+type InboxNotifier struct {
+	db *gorm.DB
+}
+
+func NewInboxNotifier() *InboxNotifier {
+	return &InboxNotifier{
+		db: database.GetDB(),
+	}
+}
+
+func (n *InboxNotifier) ChannelType() dbmodels.NotificationChannelType {
+	return dbmodels.NotificationChannelTypeInbox
+}
+
+func (n *InboxNotifier) Notify(input types.NotifyInput) (*types.NotifyOutput, error) {
+	if input.Record == nil {
+		return nil, fmt.Errorf("inbox: only notification records can be delivered")
+	}
+	err := n.db.Model(&dbmodels.NotificationRecord{}).
+		Where("id = ?", input.Record.ID).
+		Updates(map[string]interface{}{
+			"inbox_title": utils.TruncateText(input.Template.Title, 255),
+			"inbox_body":  input.Template.PlainTextContent,
+			"utime":       time.Now(),
+		}).Error
+	if err != nil {
+		return nil, fmt.Errorf("inbox: failed to store notification: %w", err)
+	}
+	return &types.NotifyOutput{}, nil
+}
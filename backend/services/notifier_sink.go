@@ -0,0 +1,82 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	"github.com/ringecosystem/degov-apps/internal/utils"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+// FileSinkNotifier writes notifications to local files instead of delivering them, for development only
+type FileSinkNotifier struct {
+	channelType dbmodels.NotificationChannelType
+	dir         string
+}
+
+func NewFileSinkNotifier(channelType dbmodels.NotificationChannelType, dir string) *FileSinkNotifier {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "degov-notifications")
+	}
+	return &FileSinkNotifier{
+		channelType: channelType,
+		dir:         dir,
+	}
+}
+
+func (n *FileSinkNotifier) ChannelType() dbmodels.NotificationChannelType {
+	return n.channelType
+}
+
+func (n *FileSinkNotifier) Notify(input types.NotifyInput) (*types.NotifyOutput, error) {
+	if err := os.MkdirAll(n.dir, 0755); err != nil {
+		return nil, fmt.Errorf("file sink: failed to create directory %s: %w", n.dir, err)
+	}
+
+	baseName := fmt.Sprintf("%s_%s_%s", time.Now().Format("20060102T150405"), strings.ToLower(string(n.channelType)), utils.NextIDString())
+	content := fmt.Sprintf("To: %s\nTitle: %s\n\n%s\n", input.To, input.Template.Title, input.Template.PlainTextContent)
+	mdPath := filepath.Join(n.dir, baseName+".md")
+	if err := os.WriteFile(mdPath, []byte(content), 0644); err != nil {
+		return nil, fmt.Errorf("file sink: failed to write %s: %w", mdPath, err)
+	}
+	if input.Template.RichTextContent != "" {
+		htmlPath := filepath.Join(n.dir, baseName+".html")
+		if err := os.WriteFile(htmlPath, []byte(input.Template.RichTextContent), 0644); err != nil {
+			return nil, fmt.Errorf("file sink: failed to write %s: %w", htmlPath, err)
+		}
+	}
+
+	slog.Info("Notification written to file sink", "channel_type", n.channelType, "to", input.To, "path", mdPath)
+	return &types.NotifyOutput{ProviderMessageID: &baseName}, nil
+}
+
+// LogSinkNotifier logs notifications instead of delivering them, for development only
+type LogSinkNotifier struct {
+	channelType dbmodels.NotificationChannelType
+}
+
+func NewLogSinkNotifier(channelType dbmodels.NotificationChannelType) *LogSinkNotifier {
+	return &LogSinkNotifier{
+		channelType: channelType,
+	}
+}
+
+func (n *LogSinkNotifier) ChannelType() dbmodels.NotificationChannelType {
+	return n.channelType
+}
+
+func (n *LogSinkNotifier) Notify(input types.NotifyInput) (*types.NotifyOutput, error) {
+	slog.Info(
+		"Notification written to log sink",
+		"channel_type", n.channelType,
+		"to", input.To,
+		"title", input.Template.Title,
+		"content", input.Template.PlainTextContent,
+	)
+	return &types.NotifyOutput{}, nil
+}
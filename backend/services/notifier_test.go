@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAllowedWebhookIP(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_ADDRESSES", "false")
+
+	tests := map[string]bool{
+		// public
+		"8.8.8.8":              true,
+		"1.1.1.1":              true,
+		"2606:4700:4700::1111": true,
+		"::ffff:8.8.8.8":       true,
+		// loopback
+		"127.0.0.1":        false,
+		"127.255.255.254":  false,
+		"::1":              false,
+		"::ffff:127.0.0.1": false,
+		// private
+		"10.0.0.1":           false,
+		"172.16.0.1":         false,
+		"172.31.255.255":     false,
+		"192.168.1.1":        false,
+		"fc00::1":            false,
+		"fd00:ec2::254":      false, // aws metadata over ipv6
+		"::ffff:10.0.0.1":    false,
+		"::ffff:192.168.0.1": false,
+		// link-local and the cloud metadata address
+		"169.254.169.254":        false,
+		"169.254.0.1":            false,
+		"fe80::1":                false,
+		"::ffff:169.254.169.254": false,
+		// unspecified, multicast and the other non public ranges
+		"0.0.0.0":         false,
+		"::":              false,
+		"0.1.2.3":         false,
+		"224.0.0.1":       false,
+		"ff02::1":         false,
+		"100.64.0.1":      false,
+		"192.0.0.8":       false,
+		"198.18.0.1":      false,
+		"240.0.0.1":       false,
+		"255.255.255.255": false,
+		"64:ff9b::a00:1":  false, // nat64 of 10.0.0.1
+		"2001:db8::1":     false,
+	}
+	for address, expected := range tests {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			t.Fatalf("invalid test address %s", address)
+		}
+		if allowed := allowedWebhookIP(ip); allowed != expected {
+			t.Errorf("%s: expected allowed=%v, got %v", address, expected, allowed)
+		}
+	}
+
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_ADDRESSES", "true")
+	if !allowedWebhookIP(net.ParseIP("127.0.0.1")) {
+		t.Error("expected private addresses to be allowed with WEBHOOK_ALLOW_PRIVATE_ADDRESSES")
+	}
+}
+
+func TestCheckWebhookHost(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_ADDRESSES", "false")
+
+	tests := map[string]bool{
+		"8.8.8.8":              true,
+		"2606:4700:4700::1111": true,
+		"127.0.0.1":            false,
+		"169.254.169.254":      false,
+		"::ffff:127.0.0.1":     false,
+		"localhost":            false, // resolves to loopback
+		"webhook.invalid":      false, // does not resolve
+	}
+	for host, expected := range tests {
+		err := checkWebhookHost(context.Background(), host)
+		if (err == nil) != expected {
+			t.Errorf("%s: expected allowed=%v, got error %v", host, expected, err)
+		}
+	}
+}
+
+func TestWebhookHTTPClientRefusesPrivateAddressesAndRedirects(t *testing.T) {
+	var redirectTargetHits atomic.Int32
+	redirectTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirectTargetHits.Add(1)
+	}))
+	defer redirectTarget.Close()
+	redirecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, redirectTarget.URL, http.StatusTemporaryRedirect)
+	}))
+	defer redirecting.Close()
+
+	// the test servers listen on loopback, which is refused when the connection is made
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_ADDRESSES", "false")
+	if _, _, err := postJSON(newWebhookHTTPClient(), redirecting.URL, []byte(`{}`), nil); err == nil || !strings.Contains(err.Error(), "not public") {
+		t.Fatalf("expected the loopback address to be refused, got %v", err)
+	}
+
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_ADDRESSES", "true")
+	statusCode, _, err := postJSON(newWebhookHTTPClient(), redirecting.URL, []byte(`{}`), nil)
+	if err == nil || statusCode != http.StatusTemporaryRedirect {
+		t.Fatalf("expected the redirect to be returned as a failed delivery, got %d %v", statusCode, err)
+	}
+	if redirectTargetHits.Load() != 0 {
+		t.Fatal("expected the redirect not to be followed")
+	}
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	// hex(HMAC-SHA256("whsec_test", "1700000000.{\"version\":\"1\"}")), computed independently
+	const expected = "4ed4b166d16eebe9f4ac3aa557eb7a1e0739e12dbcd1aff2ba1fec35041bcb73"
+	if signature := SignWebhookPayload("whsec_test", "1700000000", []byte(`{"version":"1"}`)); signature != expected {
+		t.Fatalf("expected signature %s, got %s", expected, signature)
+	}
+	if SignWebhookPayload("whsec_test", "1700000001", []byte(`{"version":"1"}`)) == expected {
+		t.Fatal("expected the timestamp to be signed")
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	"github.com/ringecosystem/degov-apps/internal/config"
+	"github.com/ringecosystem/degov-apps/internal/utils"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+const (
+	WebhookHeaderSignature = "X-Degov-Signature"
+	WebhookHeaderTimestamp = "X-Degov-Timestamp"
+	WebhookHeaderEvent     = "X-Degov-Event"
+	WebhookHeaderVersion   = "X-Degov-Webhook-Version"
+)
+
+// WebhookNotifier posts a signed versioned json payload to the channel url
+type WebhookNotifier struct {
+	httpClient *http.Client
+}
+
+func NewWebhookNotifier(httpClient *http.Client) *WebhookNotifier {
+	return &WebhookNotifier{
+		httpClient: httpClient,
+	}
+}
+
+func (n *WebhookNotifier) ChannelType() dbmodels.NotificationChannelType {
+	return dbmodels.NotificationChannelTypeWebhook
+}
+
+func (n *WebhookNotifier) Notify(input types.NotifyInput) (*types.NotifyOutput, error) {
+	secret := webhookSecret(input.ChannelPayload)
+	if secret == "" {
+		return nil, fmt.Errorf("no signing secret available for webhook %s", input.To)
+	}
+
+	now := time.Now()
+	payload := types.WebhookPayload{
+		Version:   types.WebhookPayloadVersion,
+		ID:        utils.NextIDString(),
+		Timestamp: now.Unix(),
+		Notification: types.WebhookPayloadBody{
+			Title:    input.Template.Title,
+			Markdown: input.Template.PlainTextContent,
+		},
+	}
+	if record := input.Record; record != nil {
+		payload.ID = record.ID
+		payload.Event = types.WebhookPayloadEvent{
+			ID:         record.EventID,
+			Type:       record.Type,
+			ChainID:    record.ChainID,
+			DaoCode:    record.DaoCode,
+			ProposalID: record.ProposalID,
+			VoteID:     record.VoteID,
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	statusCode, _, err := postJSON(n.httpClient, input.To, body, map[string]string{
+		WebhookHeaderTimestamp: timestamp,
+		WebhookHeaderSignature: "sha256=" + SignWebhookPayload(secret, timestamp, body),
+		WebhookHeaderEvent:     string(payload.Event.Type),
+		WebhookHeaderVersion:   payload.Version,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("webhook: %w", err)
+	}
+
+	slog.Info("Webhook notification sent successfully", "to", input.To, "status_code", statusCode)
+	return &types.NotifyOutput{}, nil
+}
+
+// SignWebhookPayload computes hex(HMAC-SHA256(secret, timestamp + "." + body)).
+// Receivers verify a delivery by recomputing it from the X-Degov-Timestamp header and the raw body.
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookSecret reads the per-channel secret, falling back to the global WEBHOOK_SIGNING_SECRET
+func webhookSecret(channelPayload *string) string {
+	if channelPayload != nil && *channelPayload != "" {
+		var payload types.NotificationChannelPayload
+		if err := json.Unmarshal([]byte(*channelPayload), &payload); err != nil {
+			slog.Warn("Failed to parse notification channel payload", "error", err)
+		} else if payload.WebhookSecret != nil && *payload.WebhookSecret != "" {
+			return *payload.WebhookSecret
+		}
+	}
+	return config.GetString("WEBHOOK_SIGNING_SECRET")
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/ringecosystem/degov-apps/database"
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	"gorm.io/gorm"
+)
+
+// OwnedResourceKind is a kind of per-user resource which can be named by a field argument
+type OwnedResourceKind string
+
+const (
+	OwnedResourceUser                 OwnedResourceKind = "userId"
+	OwnedResourceNotificationChannel  OwnedResourceKind = "channelId"
+	OwnedResourceSubscription         OwnedResourceKind = "subscriptionId"
+	OwnedResourceNotificationRecord   OwnedResourceKind = "recordId"
+	OwnedResourceNotificationDelivery OwnedResourceKind = "deliveryId"
+)
+
+// OwnedResourceKinds lists the kinds in the order they are checked
+var OwnedResourceKinds = []OwnedResourceKind{
+	OwnedResourceUser,
+	OwnedResourceNotificationChannel,
+	OwnedResourceSubscription,
+	OwnedResourceNotificationRecord,
+	OwnedResourceNotificationDelivery,
+}
+
+type OwnershipService struct {
+	db *gorm.DB
+}
+
+func NewOwnershipService() *OwnershipService {
+	return &OwnershipService{
+		db: database.GetDB(),
+	}
+}
+
+// OwnerOf returns the id of the user owning the resource, an empty string is returned when the resource does not exist
+func (s *OwnershipService) OwnerOf(kind OwnedResourceKind, id string) (string, error) {
+	switch kind {
+	case OwnedResourceUser:
+		return s.ownerFrom(&dbmodels.User{}, "id", id)
+	case OwnedResourceNotificationChannel:
+		return s.ownerFrom(&dbmodels.NotificationChannel{}, "user_id", id)
+	case OwnedResourceSubscription:
+		// subscription ids are unique across dao and proposal subscriptions
+		owner, err := s.ownerFrom(&dbmodels.UserSubscribedDao{}, "user_id", id)
+		if err != nil || owner != "" {
+			return owner, err
+		}
+		return s.ownerFrom(&dbmodels.UserSubscribedProposal{}, "user_id", id)
+	case OwnedResourceNotificationRecord:
+		return s.ownerFrom(&dbmodels.NotificationRecord{}, "user_id", id)
+	case OwnedResourceNotificationDelivery:
+		return s.ownerFrom(&dbmodels.NotificationDelivery{}, "user_id", id)
+	default:
+		return "", fmt.Errorf("unknown resource kind: %s", kind)
+	}
+}
+
+func (s *OwnershipService) ownerFrom(model interface{}, column string, id string) (string, error) {
+	var owners []string
+	if err := s.db.Model(model).Where("id = ?", id).Limit(1).Pluck(column, &owners).Error; err != nil {
+		return "", fmt.Errorf("failed to query resource owner: %w", err)
+	}
+	if len(owners) == 0 {
+		return "", nil
+	}
+	return owners[0], nil
+}
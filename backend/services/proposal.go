@@ -1,8 +1,11 @@
 package services
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -15,15 +18,24 @@ import (
 	"github.com/ringecosystem/degov-apps/types"
 )
 
+const (
+	connectionDefaultPageSize = 20
+	connectionMaxPageSize     = 100
+)
+
+var proposalSearchEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 type ProposalService struct {
 	db                  *gorm.DB
 	notificationService *NotificationService
+	liveEventService    *LiveEventService
 }
 
 func NewProposalService() *ProposalService {
 	return &ProposalService{
 		db:                  database.GetDB(),
 		notificationService: NewNotificationService(),
+		liveEventService:    NewLiveEventService(),
 	}
 }
 
@@ -74,6 +86,13 @@ func (s *ProposalService) StoreProposalTracking(input types.ProposalTrackingInpu
 	}); err != nil {
 		slog.Warn("failed to save notification event for new proposal", "error", err, "proposal_id", newProposal.ProposalID, "dao_code", newProposal.DaoCode)
 	}
+	s.liveEventService.Publish(types.LiveEvent{
+		Type:       types.LiveEventProposalCreated,
+		DaoCode:    newProposal.DaoCode,
+		ProposalID: newProposal.ProposalID,
+		Proposal:   newProposal,
+		TimeEvent:  newProposal.CTime,
+	})
 
 	return true, nil
 }
@@ -151,6 +170,29 @@ func (s *ProposalService) UpdateProposalTrackingError(proposalID, daoCode string
 		}).Error
 }
 
+// UpdateProposalTimelock stores the timelock eta of a queued proposal and the time it expires, nil without grace period
+func (s *ProposalService) UpdateProposalTimelock(proposalID, daoCode string, eta time.Time, expiresAt *time.Time) error {
+	return s.db.Model(&dbmodels.ProposalTracking{}).
+		Where("proposal_id = ? AND dao_code = ?", proposalID, daoCode).
+		Updates(map[string]interface{}{
+			"eta":        eta,
+			"expires_at": expiresAt,
+			"utime":      time.Now(),
+		}).Error
+}
+
+// QueuedProposals lists the queued proposals whose timelock eta is known
+func (s *ProposalService) QueuedProposals(daoCode string) ([]*dbmodels.ProposalTracking, error) {
+	var proposals []*dbmodels.ProposalTracking
+	err := s.db.Where("dao_code = ? AND state = ? AND eta IS NOT NULL", daoCode, dbmodels.ProposalStateQueued).
+		Order("eta asc").
+		Find(&proposals).Error
+	if err != nil {
+		return nil, err
+	}
+	return proposals, nil
+}
+
 func (s *ProposalService) UpdateOffsetTrackingVote(proposalID, daoCode string, offset int) error {
 	return s.db.Model(&dbmodels.ProposalTracking{}).
 		Where("proposal_id = ? AND dao_code = ?", proposalID, daoCode).
@@ -190,8 +232,149 @@ func (s *ProposalService) InspectProposal(input types.InspectProposalInput) (*db
 	return &proposal, nil
 }
 
+// ListProposals pages through the proposals of active daos, newest first.
+// The cursor is the sort key of the last returned proposal, so pages stay stable while new proposals arrive
+func (s *ProposalService) ListProposals(baseInput types.BasicInput[types.ListProposalsInput]) (*gqlmodels.ProposalConnection, error) {
+	input := baseInput.Input
+
+	first, err := connectionPageSize(input.First)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.db.Model(&dbmodels.ProposalTracking{}).
+		Where("dao_code IN (?)", s.db.Model(&dbmodels.Dao{}).Select("code").Where("state = ?", dbmodels.DaoStateActive))
+	query = applyProposalFilter(query, input.Filter)
+
+	var totalCount int64
+	if err := query.Session(&gorm.Session{}).Count(&totalCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count proposals: %w", err)
+	}
+
+	pageQuery := query.Session(&gorm.Session{})
+	if input.After != nil && *input.After != "" {
+		cursorTime, cursorID, err := utils.DecodeCursor(*input.After)
+		if err != nil {
+			return nil, err
+		}
+		pageQuery = pageQuery.Where("(coalesce(proposal_created_at, ctime), id) < (?, ?)", cursorTime, cursorID)
+	}
+
+	// one more row tells whether there is a next page
+	var proposals []*dbmodels.ProposalTracking
+	err = pageQuery.
+		Order("coalesce(proposal_created_at, ctime) desc, id desc").
+		Limit(first + 1).
+		Find(&proposals).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list proposals: %w", err)
+	}
+
+	connection := &gqlmodels.ProposalConnection{
+		Edges:      make([]*gqlmodels.ProposalEdge, 0, len(proposals)),
+		PageInfo:   &gqlmodels.PageInfo{HasNextPage: len(proposals) > first},
+		TotalCount: int32(totalCount),
+	}
+	if len(proposals) > first {
+		proposals = proposals[:first]
+	}
+	for _, proposal := range proposals {
+		connection.Edges = append(connection.Edges, &gqlmodels.ProposalEdge{
+			Cursor: encodeProposalCursor(proposal),
+			Node:   s.ConvertToGqlProposal(proposal),
+		})
+	}
+	if len(connection.Edges) > 0 {
+		endCursor := connection.Edges[len(connection.Edges)-1].Cursor
+		connection.PageInfo.EndCursor = &endCursor
+	}
+	return connection, nil
+}
+
+// GetProposal returns nil when the proposal is not tracked
+func (s *ProposalService) GetProposal(daoCode, proposalID string) (*gqlmodels.Proposal, error) {
+	proposal, err := s.InspectProposal(types.InspectProposalInput{
+		DaoCode:    daoCode,
+		ProposalID: proposalID,
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get proposal: %w", err)
+	}
+	return s.ConvertToGqlProposal(proposal), nil
+}
+
+func applyProposalFilter(query *gorm.DB, filter *gqlmodels.ProposalFilter) *gorm.DB {
+	if filter == nil {
+		return query
+	}
+	if len(filter.DaoCodes) > 0 {
+		query = query.Where("dao_code IN ?", filter.DaoCodes)
+	}
+	if filter.ChainID != nil {
+		query = query.Where("chain_id = ?", *filter.ChainID)
+	}
+	if len(filter.States) > 0 {
+		states := make([]string, 0, len(filter.States))
+		for _, state := range filter.States {
+			states = append(states, state.String())
+		}
+		query = query.Where("state IN ?", states)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("coalesce(proposal_created_at, ctime) >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("coalesce(proposal_created_at, ctime) < ?", *filter.CreatedTo)
+	}
+	if filter.Search != nil {
+		if search := strings.TrimSpace(*filter.Search); search != "" {
+			// served by the trigram index on title
+			query = query.Where("title ILIKE ?", "%"+proposalSearchEscaper.Replace(search)+"%")
+		}
+	}
+	return query
+}
+
+func encodeProposalCursor(proposal *dbmodels.ProposalTracking) string {
+	sortTime := proposal.CTime
+	if proposal.ProposalCreatedAt != nil {
+		sortTime = *proposal.ProposalCreatedAt
+	}
+	return utils.EncodeCursor(sortTime, proposal.ID)
+}
+
+// connectionPageSize reads the relay "first" argument
+func connectionPageSize(first *int32) (int, error) {
+	if first == nil {
+		return connectionDefaultPageSize, nil
+	}
+	if *first < 0 {
+		return 0, fmt.Errorf("first must not be negative")
+	}
+	if *first > connectionMaxPageSize {
+		return connectionMaxPageSize, nil
+	}
+	return int(*first), nil
+}
+
+// StoreProposalActions stores the decoded actions of the proposal as json, lists of proposals return them without decoding
+func (s *ProposalService) StoreProposalActions(daoCode, proposalID string, actions []*gqlmodels.ProposalAction) error {
+	return s.db.Model(&dbmodels.ProposalTracking{}).
+		Where("dao_code = ? AND proposal_id = ?", daoCode, proposalID).
+		Update("actions", utils.ToJSON(actions)).Error
+}
+
 func (s *ProposalService) ConvertToGqlProposal(input *dbmodels.ProposalTracking) *gqlmodels.Proposal {
 	gqlProposal := gqlmodels.Proposal{}
 	copier.Copy(&gqlProposal, input)
+	if input.ActionsJSON != nil {
+		if err := json.Unmarshal([]byte(*input.ActionsJSON), &gqlProposal.Actions); err != nil {
+			slog.Warn("Failed to decode stored proposal actions", "dao_code", input.DaoCode, "proposal_id", input.ProposalID, "error", err)
+			gqlProposal.Actions = nil
+		}
+	}
 	return &gqlProposal
 }
@@ -0,0 +1,352 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gqlmodels "github.com/ringecosystem/degov-apps/graph/models"
+	"github.com/ringecosystem/degov-apps/internal"
+	"github.com/ringecosystem/degov-apps/internal/config"
+	"github.com/ringecosystem/degov-apps/internal/kvstore"
+	"github.com/ringecosystem/degov-apps/internal/utils"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+const (
+	// proposal actions never change, the cache only expires to pick up abis verified later
+	proposalActionsCacheTTL = 24 * time.Hour
+	// actions with a failed lookup are cached shortly, so that a failing explorer or rpc is not asked on every request
+	proposalActionsIncompleteCacheTTL = 5 * time.Minute
+)
+
+type ProposalActionService struct {
+	proposalService   *ProposalService
+	daoConfigService  *DaoConfigService
+	evmChainService   *EvmChainService
+	signatureDatabase *internal.SignatureDatabase
+	store             kvstore.Store
+}
+
+func NewProposalActionService() *ProposalActionService {
+	return &ProposalActionService{
+		proposalService:   NewProposalService(),
+		daoConfigService:  NewDaoConfigService(),
+		evmChainService:   NewEvmChainService(),
+		signatureDatabase: internal.NewSignatureDatabase(config.GetString("FOUR_BYTE_API_URL")),
+		store:             kvstore.GetStore(),
+	}
+}
+
+func proposalActionsKey(daoCode, proposalID string) string {
+	return fmt.Sprintf("proposal_actions:%s:%s", daoCode, internal.NormalizeProposalID(proposalID))
+}
+
+// CachedActions returns the decoded actions of the proposal when they are cached, ok is false otherwise
+func (s *ProposalActionService) CachedActions(daoCode, proposalID string) (actions []*gqlmodels.ProposalAction, ok bool) {
+	cached, err := s.store.Get(proposalActionsKey(daoCode, proposalID))
+	if err != nil {
+		if !errors.Is(err, kvstore.ErrNotFound) {
+			slog.Warn("Failed to read cached proposal actions", "dao_code", daoCode, "proposal_id", proposalID, "error", err)
+		}
+		return nil, false
+	}
+	if err := json.Unmarshal([]byte(cached), &actions); err != nil {
+		return nil, false
+	}
+	return actions, true
+}
+
+// Actions returns the decoded actions of the proposal, read from its ProposalCreated log
+func (s *ProposalActionService) Actions(ctx context.Context, daoCode, proposalID string) ([]*gqlmodels.ProposalAction, error) {
+	if actions, ok := s.CachedActions(daoCode, proposalID); ok {
+		return actions, nil
+	}
+
+	proposal, err := s.proposalService.InspectProposal(types.InspectProposalInput{
+		DaoCode:    daoCode,
+		ProposalID: proposalID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proposal: %w", err)
+	}
+	daoConfig, err := s.daoConfigService.StandardConfig(daoCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dao config: %w", err)
+	}
+	if !common.IsHexAddress(daoConfig.Contracts.Governor) {
+		return nil, fmt.Errorf("invalid governor address: %q", daoConfig.Contracts.Governor)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	pool := internal.GetRPCPool(daoConfig.Chain.ID, daoConfig.Chain.RPCs)
+	rawActions, err := internal.QueryProposalActions(ctx, pool, common.HexToAddress(daoConfig.Contracts.Governor), proposal.ProposalID, uint64(max(proposal.ProposalAtBlock, 0)))
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := &proposalActionDecoder{
+		service:   s,
+		backend:   pool,
+		chainID:   daoConfig.Chain.ID,
+		daoConfig: daoConfig,
+		tokens:    map[common.Address]*internal.TokenMetadata{},
+	}
+	actions := make([]*gqlmodels.ProposalAction, 0, len(rawActions))
+	for _, rawAction := range rawActions {
+		actions = append(actions, decoder.decode(ctx, rawAction))
+	}
+
+	ttl := proposalActionsCacheTTL
+	if decoder.incomplete {
+		ttl = proposalActionsIncompleteCacheTTL
+	} else if err := s.proposalService.StoreProposalActions(daoCode, proposal.ProposalID, actions); err != nil {
+		slog.Warn("Failed to store proposal actions", "dao_code", daoCode, "proposal_id", proposalID, "error", err)
+	}
+	if err := s.store.Set(proposalActionsKey(daoCode, proposalID), utils.ToJSON(actions), ttl); err != nil {
+		slog.Warn("Failed to cache proposal actions", "dao_code", daoCode, "proposal_id", proposalID, "error", err)
+	}
+	return actions, nil
+}
+
+// proposalActionDecoder decodes the actions of one proposal, token metadata is read once per target
+type proposalActionDecoder struct {
+	service   *ProposalActionService
+	backend   internal.ContractBackend
+	chainID   int
+	daoConfig *types.DaoConfig
+	tokens    map[common.Address]*internal.TokenMetadata
+	// incomplete is set when a lookup failed, such actions are cached shortly
+	incomplete bool
+}
+
+func (d *proposalActionDecoder) decode(ctx context.Context, action internal.ProposalAction) *gqlmodels.ProposalAction {
+	value := action.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	output := &gqlmodels.ProposalAction{
+		Target:   strings.ToLower(action.Target.Hex()),
+		Value:    value.String(),
+		Calldata: hexutil.Encode(action.Calldata),
+		Args:     []*gqlmodels.ProposalActionArg{},
+		Decoder:  gqlmodels.ProposalActionDecoderNone,
+	}
+	if action.Signature != "" {
+		output.Signature = &action.Signature
+	}
+
+	if len(action.Calldata) == 0 {
+		if value.Sign() > 0 {
+			output.Summary = fmt.Sprintf("transfer %s to %s", d.nativeAmount(value), shortAddress(action.Target))
+		} else {
+			output.Summary = fmt.Sprintf("call %s without data", shortAddress(action.Target))
+		}
+		return output
+	}
+
+	method, args, decoder, ok := d.decodeCalldata(ctx, action)
+	if !ok {
+		output.Summary = fmt.Sprintf("call %s with unknown function %s", shortAddress(action.Target), hexutil.Encode(action.Calldata[:min(len(action.Calldata), 4)]))
+		if value.Sign() > 0 {
+			output.Summary += fmt.Sprintf(" sending %s", d.nativeAmount(value))
+		}
+		return output
+	}
+
+	signature := method.Sig
+	output.Signature = &signature
+	output.FunctionName = &method.RawName
+	output.Decoder = decoder
+	for i, input := range method.Inputs {
+		name := input.Name
+		if name == "" {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		output.Args = append(output.Args, &gqlmodels.ProposalActionArg{
+			Name:  name,
+			Type:  input.Type.String(),
+			Value: formatActionArg(args[i]),
+		})
+	}
+	output.Summary = d.summarize(ctx, action.Target, value, method, args, output.Args)
+	return output
+}
+
+// decodeCalldata tries the abis of the target and its implementations first, then the signature emitted by the
+// governor and the signatures of the selector. Signatures are only accepted when the arguments re-encode to the calldata
+func (d *proposalActionDecoder) decodeCalldata(ctx context.Context, action internal.ProposalAction) (abi.Method, []interface{}, gqlmodels.ProposalActionDecoder, bool) {
+	if len(action.Calldata) < 4 {
+		return abi.Method{}, nil, "", false
+	}
+	selector, data := action.Calldata[:4], action.Calldata[4:]
+
+	if hasCode, err := internal.HasCode(ctx, d.backend, action.Target); err != nil {
+		d.incomplete = true
+		slog.Warn("Failed to check code of proposal target", "target", action.Target.Hex(), "error", err)
+	} else if hasCode {
+		abis, err := d.service.evmChainService.GetAbi(gqlmodels.EvmAbiInput{Chain: int32(d.chainID), Contract: action.Target.Hex()})
+		if err != nil {
+			slog.Debug("No abi of proposal target", "target", action.Target.Hex(), "error", err)
+		}
+		for _, contract := range abis {
+			parsed, err := abi.JSON(strings.NewReader(contract.Abi))
+			if err != nil {
+				continue
+			}
+			method, err := parsed.MethodById(selector)
+			if err != nil {
+				continue
+			}
+			if args, err := method.Inputs.Unpack(data); err == nil {
+				return *method, args, gqlmodels.ProposalActionDecoderAbi, true
+			}
+		}
+	}
+
+	var signatures []string
+	if action.Signature != "" {
+		signatures = append(signatures, action.Signature)
+	}
+	candidates, err := d.service.signatureDatabase.LookupFunction(ctx, selector)
+	if err != nil {
+		d.incomplete = true
+		slog.Warn("Failed to look up function selector", "selector", hexutil.Encode(selector), "error", err)
+	}
+	signatures = append(signatures, candidates...)
+	for _, signature := range signatures {
+		method, err := internal.MethodFromSignature(signature)
+		if err != nil || !bytes.Equal(method.ID, selector) {
+			continue
+		}
+		args, err := method.Inputs.Unpack(data)
+		if err != nil {
+			continue
+		}
+		if packed, err := method.Inputs.Pack(args...); err != nil || !bytes.Equal(packed, data) {
+			continue
+		}
+		return method, args, gqlmodels.ProposalActionDecoderSelector, true
+	}
+	return abi.Method{}, nil, "", false
+}
+
+func (d *proposalActionDecoder) summarize(ctx context.Context, target common.Address, value *big.Int, method abi.Method, args []interface{}, formattedArgs []*gqlmodels.ProposalActionArg) string {
+	var summary string
+	switch method.Sig {
+	case "transfer(address,uint256)":
+		summary = fmt.Sprintf("transfer %s to %s", d.tokenAmount(ctx, target, args[1]), shortAddressOf(args[0]))
+	case "transferFrom(address,address,uint256)":
+		summary = fmt.Sprintf("transfer %s from %s to %s", d.tokenAmount(ctx, target, args[2]), shortAddressOf(args[0]), shortAddressOf(args[1]))
+	case "approve(address,uint256)":
+		summary = fmt.Sprintf("approve %s to spend %s", shortAddressOf(args[0]), d.tokenAmount(ctx, target, args[1]))
+	default:
+		values := make([]string, 0, len(formattedArgs))
+		for _, arg := range formattedArgs {
+			values = append(values, arg.Value)
+		}
+		summary = fmt.Sprintf("call %s(%s) on %s", method.RawName, strings.Join(values, ", "), shortAddress(target))
+	}
+	if value.Sign() > 0 {
+		summary += fmt.Sprintf(" sending %s", d.nativeAmount(value))
+	}
+	return summary
+}
+
+func (d *proposalActionDecoder) nativeAmount(value *big.Int) string {
+	nativeToken := d.daoConfig.Chain.NativeToken
+	if nativeToken.Symbol == "" {
+		return utils.FormatTokenAmount(value, 18) + " native token"
+	}
+	return utils.FormatTokenAmount(value, nativeToken.Decimals) + " " + nativeToken.Symbol
+}
+
+// tokenAmount formats the amount of the token, the raw amount is shown when the token metadata can not be read
+func (d *proposalActionDecoder) tokenAmount(ctx context.Context, token common.Address, amount interface{}) string {
+	value, ok := amount.(*big.Int)
+	if !ok {
+		return formatActionArg(amount)
+	}
+	metadata, cached := d.tokens[token]
+	if !cached {
+		var err error
+		metadata, err = internal.QueryTokenMetadata(ctx, d.backend, token)
+		if err != nil {
+			slog.Debug("Failed to query token metadata", "token", token.Hex(), "error", err)
+			d.incomplete = true
+		}
+		d.tokens[token] = metadata
+	}
+	if metadata == nil {
+		return fmt.Sprintf("%s of token %s", value.String(), shortAddress(token))
+	}
+	return utils.FormatTokenAmount(value, metadata.Decimals) + " " + metadata.Symbol
+}
+
+func shortAddress(address common.Address) string {
+	hex := strings.ToLower(address.Hex())
+	return hex[:6] + "…" + hex[len(hex)-4:]
+}
+
+func shortAddressOf(value interface{}) string {
+	if address, ok := value.(common.Address); ok {
+		return shortAddress(address)
+	}
+	return formatActionArg(value)
+}
+
+// formatActionArg formats an unpacked abi value, bytes as hex and arrays and tuples in brackets
+func formatActionArg(value interface{}) string {
+	switch v := value.(type) {
+	case common.Address:
+		return strings.ToLower(v.Hex())
+	case *big.Int:
+		return v.String()
+	case []byte:
+		return hexutil.Encode(v)
+	case string:
+		return v
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			// fixed bytes such as bytes32
+			data := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(data), rv)
+			return hexutil.Encode(data)
+		}
+		fallthrough
+	case reflect.Slice:
+		items := make([]string, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			items = append(items, formatActionArg(rv.Index(i).Interface()))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case reflect.Struct:
+		fields := make([]string, 0, rv.NumField())
+		for i := 0; i < rv.NumField(); i++ {
+			fields = append(fields, formatActionArg(rv.Field(i).Interface()))
+		}
+		return "(" + strings.Join(fields, ", ") + ")"
+	}
+	return fmt.Sprintf("%v", value)
+}
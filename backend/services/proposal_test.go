@@ -0,0 +1,53 @@
+package services
+
+import (
+	"testing"
+
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	gqlmodels "github.com/ringecosystem/degov-apps/graph/models"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+func TestStoredProposalActions(t *testing.T) {
+	db := newTestDB(t, &dbmodels.ProposalTracking{})
+	service := &ProposalService{db: db}
+	for _, proposal := range []*dbmodels.ProposalTracking{
+		{ID: "decoded", DaoCode: "dao", ProposalID: "0x01", State: dbmodels.ProposalStateActive},
+		{ID: "undecoded", DaoCode: "dao", ProposalID: "0x02", State: dbmodels.ProposalStateActive},
+	} {
+		if err := db.Create(proposal).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	signature := "transfer(address,uint256)"
+	actions := []*gqlmodels.ProposalAction{{
+		Target:    "0x1234",
+		Value:     "0",
+		Calldata:  "0xa9059cbb",
+		Signature: &signature,
+		Args:      []*gqlmodels.ProposalActionArg{},
+		Decoder:   gqlmodels.ProposalActionDecoderSelector,
+	}}
+	if err := service.StoreProposalActions("dao", "0x01", actions); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := service.InspectProposal(types.InspectProposalInput{DaoCode: "dao", ProposalID: "0x01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted := service.ConvertToGqlProposal(decoded)
+	if len(converted.Actions) != 1 || converted.Actions[0].Target != "0x1234" || *converted.Actions[0].Signature != signature ||
+		converted.Actions[0].Decoder != gqlmodels.ProposalActionDecoderSelector {
+		t.Fatalf("expected the stored actions, got %+v", converted.Actions)
+	}
+
+	undecoded, err := service.InspectProposal(types.InspectProposalInput{DaoCode: "dao", ProposalID: "0x02"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if converted := service.ConvertToGqlProposal(undecoded); converted.Actions != nil {
+		t.Fatalf("expected no actions for a proposal without decoded actions, got %+v", converted.Actions)
+	}
+}
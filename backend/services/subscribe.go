@@ -1,8 +1,10 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
 	"time"
 
@@ -64,12 +66,12 @@ type InspectSubscribeProposalInput struct {
 
 func (s *SubscribeService) buildFeatures(
 	input *buildFeatureInput,
-) []dbmodels.SubscribeFeature {
+) ([]dbmodels.SubscribeFeature, error) {
 	featureSettings := input.FeatureSettings
 	var features []dbmodels.SubscribeFeature
 
 	if featureSettings == nil {
-		return features
+		return features, nil
 	}
 
 	for _, featureSetting := range featureSettings {
@@ -78,7 +80,6 @@ func (s *SubscribeService) buildFeatures(
 		}
 
 		var dbFeatureName dbmodels.SubscribeFeatureName
-		var strategy string
 
 		switch featureSetting.Name {
 		case gqlmodels.FeatureNameVoteEnd:
@@ -89,16 +90,23 @@ func (s *SubscribeService) buildFeatures(
 			dbFeatureName = dbmodels.SubscribeFeatureProposalStateChanged
 		case gqlmodels.FeatureNameProposalNew:
 			dbFeatureName = dbmodels.SubscribeFeatureProposalNew
+		case gqlmodels.FeatureNameProposalExecutable:
+			dbFeatureName = dbmodels.SubscribeFeatureProposalExecutable
+		case gqlmodels.FeatureNameProposalExpiring:
+			dbFeatureName = dbmodels.SubscribeFeatureProposalExpiring
 		default:
 			// skip unsupported feature
 			slog.Warn("skip unsupported feature", "feature", featureSetting.Name)
 			continue
 		}
 
-		if featureSetting.Strategy != nil && *featureSetting.Strategy != "" {
-			strategy = *featureSetting.Strategy
-		} else {
-			strategy = "true"
+		var rawStrategy string
+		if featureSetting.Strategy != nil {
+			rawStrategy = *featureSetting.Strategy
+		}
+		strategy, err := NormalizeSubscribeStrategy(dbFeatureName, rawStrategy)
+		if err != nil {
+			return nil, err
 		}
 
 		features = append(features, dbmodels.SubscribeFeature{
@@ -113,7 +121,7 @@ func (s *SubscribeService) buildFeatures(
 		})
 	}
 
-	return features
+	return features, nil
 }
 
 func (s *SubscribeService) SubscribeDao(baseInput types.BasicInput[gqlmodels.SubscribeDaoInput]) (*gqlmodels.SubscribedDaoOutput, error) {
@@ -129,11 +137,24 @@ func (s *SubscribeService) SubscribeDao(baseInput types.BasicInput[gqlmodels.Sub
 		return nil, fmt.Errorf("failed to inspect existing DAO: %w", err)
 	}
 
+	chainId := int(existingDao.ChainID)
+
+	// build the features first so that an invalid strategy does not leave a subscription behind
+	features, err := s.buildFeatures(&buildFeatureInput{
+		ChainID:         chainId,
+		DaoCode:         sdInput.DaoCode,
+		UserID:          user.Id,
+		UserAddress:     user.Address,
+		FeatureSettings: featureSettings,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	existingSubscribedDao, err1 := s.InspectSubscribeDao(types.BasicInput[string]{
 		User:  user,
 		Input: sdInput.DaoCode,
 	})
-	chainId := int(existingDao.ChainID)
 
 	if err1 == nil {
 		existingSubscribedDao.UTime = time.Now()
@@ -155,14 +176,6 @@ func (s *SubscribeService) SubscribeDao(baseInput types.BasicInput[gqlmodels.Sub
 		}
 	}
 
-	features := s.buildFeatures(&buildFeatureInput{
-		ChainID:         chainId,
-		DaoCode:         sdInput.DaoCode,
-		UserID:          user.Id,
-		UserAddress:     user.Address,
-		FeatureSettings: featureSettings,
-	})
-
 	if err := s.resetDaoFeatures(resetDaoFeaturesInput{
 		UserID:   user.Id,
 		DaoCode:  sdInput.DaoCode,
@@ -257,6 +270,19 @@ func (s *SubscribeService) SubscribeProposal(baseInput types.BasicInput[gqlmodel
 	}
 	chainId := int(existingDao.ChainID)
 
+	// build the features first so that an invalid strategy does not leave a subscription behind
+	features, err := s.buildFeatures(&buildFeatureInput{
+		ChainID:         chainId,
+		DaoCode:         spInput.DaoCode,
+		ProposalID:      &spInput.ProposalID,
+		UserID:          user.Id,
+		UserAddress:     user.Address,
+		FeatureSettings: featureSettings,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	// check existing subscribed proposal
 	existingSubscribedProposal, err1 := s.InspectSubscribeProposal(types.BasicInput[InspectSubscribeProposalInput]{
 		User: user,
@@ -286,15 +312,6 @@ func (s *SubscribeService) SubscribeProposal(baseInput types.BasicInput[gqlmodel
 		}
 	}
 
-	features := s.buildFeatures(&buildFeatureInput{
-		ChainID:         chainId,
-		DaoCode:         spInput.DaoCode,
-		ProposalID:      &spInput.ProposalID,
-		UserID:          user.Id,
-		UserAddress:     user.Address,
-		FeatureSettings: featureSettings,
-	})
-
 	if err := s.resetProposalFeatures(resetProposalFeaturesInput{
 		UserID:     user.Id,
 		DaoCode:    spInput.DaoCode,
@@ -343,17 +360,78 @@ func (s *SubscribeService) UnsubscribeProposal(baseInput types.BasicInput[gqlmod
 	return output, nil
 }
 
+// ApplyUnsubscribe applies a verified unsubscribe token. it is idempotent, so a link can be used more than once
+func (s *SubscribeService) ApplyUnsubscribe(claims types.UnsubscribeClaims) error {
+	var user dbmodels.User
+	if err := s.db.Where("id = ?", claims.UserID).First(&user).Error; err != nil {
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+	sessInfo := &types.UserSessInfo{
+		Id:      user.ID,
+		Address: user.Address,
+	}
+
+	var err error
+	switch claims.Scope {
+	case types.UnsubscribeScopeDao:
+		_, err = s.UnsubscribeDao(types.BasicInput[gqlmodels.UnsubscribeDaoInput]{
+			User:  sessInfo,
+			Input: gqlmodels.UnsubscribeDaoInput{DaoCode: claims.DaoCode},
+		})
+	case types.UnsubscribeScopeProposal:
+		_, err = s.UnsubscribeProposal(types.BasicInput[gqlmodels.UnsubscribeProposalInput]{
+			User: sessInfo,
+			Input: gqlmodels.UnsubscribeProposalInput{
+				DaoCode:    claims.DaoCode,
+				ProposalID: claims.ProposalID,
+			},
+		})
+	case types.UnsubscribeScopeFeature:
+		// the feature is removed from the dao and from every proposal of it
+		err = s.db.
+			Where("user_id = ? AND dao_code = ? AND feature = ?", user.ID, claims.DaoCode, claims.Feature).
+			Delete(&dbmodels.SubscribeFeature{}).Error
+	case types.UnsubscribeScopeAllEmail:
+		// the email channels have to be verified again to receive emails
+		err = s.db.Model(&dbmodels.NotificationChannel{}).
+			Where("user_id = ? AND channel_type = ?", user.ID, dbmodels.NotificationChannelTypeEmail).
+			Update("verified", 0).Error
+	default:
+		return fmt.Errorf("unsupported unsubscribe scope: %s", claims.Scope)
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to unsubscribe %s: %w", claims.Scope, err)
+	}
+	return nil
+}
+
+// ListSubscribedUser lists the users subscribed to the feature, one row per user whichever of its linked
+// addresses the subscription was made with, the user address is the primary address of the user
 func (s *SubscribeService) ListSubscribedUser(input types.ListSubscribeUserInput) ([]types.ListSubscribedUserOutput, error) {
 	strategies := input.Strategies
-	if len(strategies) == 0 {
+	if len(strategies) == 0 && len(input.StrategyTokens) == 0 {
 		return nil, fmt.Errorf("no strategies provided for feature %s", input.Feature)
 	}
 
 	queryParams := make([]interface{}, 0)
 	whereConditions := make([]string, 0)
 
-	whereConditions = append(whereConditions, "f.feature = ?", "f.strategy IN ?", "f.dao_code = ?")
-	queryParams = append(queryParams, input.Feature, strategies, input.DaoCode)
+	// strategies are stored in their canonical form, so a token is matched as a whole comma separated item
+	strategyConditions := make([]string, 0, len(input.StrategyTokens)+1)
+	strategyParams := make([]interface{}, 0, len(input.StrategyTokens)+1)
+	if len(strategies) > 0 {
+		strategyConditions = append(strategyConditions, "f.strategy IN ?")
+		strategyParams = append(strategyParams, strategies)
+	}
+	for _, token := range input.StrategyTokens {
+		strategyConditions = append(strategyConditions, "(',' || f.strategy || ',') LIKE ?")
+		strategyParams = append(strategyParams, "%,"+token+",%")
+	}
+
+	whereConditions = append(whereConditions, "f.feature = ?", "("+strings.Join(strategyConditions, " OR ")+")", "f.dao_code = ?")
+	queryParams = append(queryParams, input.Feature)
+	queryParams = append(queryParams, strategyParams...)
+	queryParams = append(queryParams, input.DaoCode)
 
 	if input.ProposalID != nil {
 		whereConditions = append(whereConditions, "(f.proposal_id = ? OR f.proposal_id IS NULL)")
@@ -372,11 +450,11 @@ func (s *SubscribeService) ListSubscribedUser(input types.ListSubscribeUserInput
 	sqlTemplate := `
 WITH RankedResults AS (
     SELECT
-        f.user_id, f.user_address, f.chain_id, f.dao_code,
+        f.user_id, COALESCE(u.address, f.user_address) AS user_address, f.chain_id, f.dao_code, f.strategy,
         LEAST(d.ctime, p.ctime) AS ctime,
         f.ctime AS order_ctime,
         ROW_NUMBER() OVER(
-            PARTITION BY f.user_id, f.user_address, f.chain_id, f.dao_code, LEAST(d.ctime, p.ctime)
+            PARTITION BY f.user_id, f.chain_id, f.dao_code, LEAST(d.ctime, p.ctime)
             ORDER BY f.ctime ASC, f.user_id ASC
         ) as rn
     FROM
@@ -385,10 +463,12 @@ WITH RankedResults AS (
         dgv_user_subscribed_dao AS d ON f.user_id = d.user_id AND f.dao_code = d.dao_code
     LEFT JOIN
         dgv_user_subscribed_proposal AS p ON f.user_id = p.user_id AND f.proposal_id = p.proposal_id
+    LEFT JOIN
+        dgv_user AS u ON f.user_id = u.id
     WHERE
         %s
 )
-SELECT user_id, user_address, chain_id, dao_code, ctime
+SELECT user_id, user_address, chain_id, dao_code, strategy, ctime
 FROM RankedResults
 WHERE rn = 1
 ORDER BY order_ctime ASC, user_id ASC
@@ -411,6 +491,38 @@ LIMIT ? OFFSET ?
 	return outputs, nil
 }
 
+// VoteEndLeadTimes returns the distinct lead times of the VOTE_END subscriptions of the dao, longest first.
+// the default lead time is always included, it keeps the vote end event of every proposal
+func (s *SubscribeService) VoteEndLeadTimes(daoCode string) ([]time.Duration, error) {
+	var strategies []string
+	if err := s.db.Model(&dbmodels.SubscribeFeature{}).
+		Where("dao_code = ? AND feature = ?", daoCode, dbmodels.SubscribeFeatureVoteEnd).
+		Distinct().
+		Pluck("strategy", &strategies).Error; err != nil {
+		return nil, fmt.Errorf("failed to list vote end strategies: %w", err)
+	}
+
+	seen := map[time.Duration]bool{VoteEndDefaultLeadTime: true}
+	leadTimes := []time.Duration{VoteEndDefaultLeadTime}
+	for _, strategy := range strategies {
+		parsed, err := ParseVoteEndStrategy(strategy)
+		if err != nil {
+			slog.Warn("Invalid vote end strategy", "dao_code", daoCode, "strategy", strategy, "error", err)
+			continue
+		}
+		for _, leadTime := range parsed.LeadTimes {
+			if !seen[leadTime] {
+				seen[leadTime] = true
+				leadTimes = append(leadTimes, leadTime)
+			}
+		}
+	}
+	sort.Slice(leadTimes, func(i, j int) bool {
+		return leadTimes[i] > leadTimes[j]
+	})
+	return leadTimes, nil
+}
+
 func (s *SubscribeService) resetDaoFeatures(input resetDaoFeaturesInput) error {
 	if err := s.db.Where(
 		"dao_code = ? and user_id =?",
@@ -491,15 +603,7 @@ func (s *UserSubscribedDaoService) SubscribedDaos(baseInput types.BasicInput[*st
 		return nil, fmt.Errorf("not logged in")
 	}
 
-	// First get subscribed dao codes by joining with dgv_dao to ensure only ACTIVE DAOs
-	var daoCodes []string
-	err := s.db.Table("dgv_user_subscribed_dao").
-		Select("dgv_user_subscribed_dao.dao_code").
-		Joins("INNER JOIN dgv_dao ON dgv_user_subscribed_dao.dao_code = dgv_dao.code").
-		Where("dgv_user_subscribed_dao.user_id = ? AND dgv_user_subscribed_dao.state = ? AND dgv_dao.state = ?",
-			baseInput.User.Id, "ACTIVE", "ACTIVE").
-		Pluck("dao_code", &daoCodes).
-		Error
+	daoCodes, err := s.SubscribedDaoCodes(baseInput.User.Id)
 	if err != nil {
 		return nil, err
 	}
@@ -551,6 +655,22 @@ func (s *UserSubscribedDaoService) SubscribedDaos(baseInput types.BasicInput[*st
 	return results, nil
 }
 
+// SubscribedDaoCodes returns the codes of the ACTIVE daos subscribed by the user
+func (s *UserSubscribedDaoService) SubscribedDaoCodes(userID string) ([]string, error) {
+	var daoCodes []string
+	err := s.db.Table("dgv_user_subscribed_dao").
+		Select("dgv_user_subscribed_dao.dao_code").
+		Joins("INNER JOIN dgv_dao ON dgv_user_subscribed_dao.dao_code = dgv_dao.code").
+		Where("dgv_user_subscribed_dao.user_id = ? AND dgv_user_subscribed_dao.state = ? AND dgv_dao.state = ?",
+			userID, "ACTIVE", "ACTIVE").
+		Pluck("dao_code", &daoCodes).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	return daoCodes, nil
+}
+
 func (s *UserSubscribedDaoService) SubscribedProposals(baseInput types.BasicInput[*string]) ([]*gqlmodels.SubscribedProposal, error) {
 	if baseInput.User == nil {
 		return nil, fmt.Errorf("not logged in")
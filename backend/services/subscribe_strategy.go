@@ -0,0 +1,147 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+const (
+	// SubscribeStrategyDefault enables a feature with its default behaviour
+	SubscribeStrategyDefault = "true"
+	// VoteEndStrategyUnvoted is the VOTE_END strategy flag to be reminded only when not voted yet
+	VoteEndStrategyUnvoted = "unvoted"
+
+	// VoteEndDefaultLeadTime is the lead time of the default VOTE_END strategy
+	VoteEndDefaultLeadTime = 48 * time.Hour
+
+	voteEndMinLeadTime  = 5 * time.Minute
+	voteEndMaxLeadTime  = 30 * 24 * time.Hour
+	voteEndMaxLeadTimes = 5
+)
+
+// NormalizeSubscribeStrategy validates the strategy of the feature and returns its canonical form, which is what
+// the notification fan-out matches against. An empty strategy is the default one.
+//
+// VOTE_END accepts comma separated lead times and the "unvoted" flag, e.g. "24h,1h,unvoted" or "2d,unvoted".
+// Lead times are whole minutes between 5m and 30d, the canonical form lists them longest first, such as "48h,90m".
+// The other features only accept "true".
+func NormalizeSubscribeStrategy(feature dbmodels.SubscribeFeatureName, strategy string) (string, error) {
+	strategy = strings.ToLower(strings.TrimSpace(strategy))
+	if strategy == "" || strategy == SubscribeStrategyDefault {
+		return SubscribeStrategyDefault, nil
+	}
+	if feature != dbmodels.SubscribeFeatureVoteEnd {
+		return "", fmt.Errorf("unsupported strategy %q for feature %s", strategy, feature)
+	}
+
+	parsed, err := ParseVoteEndStrategy(strategy)
+	if err != nil {
+		return "", err
+	}
+	return FormatVoteEndStrategy(parsed), nil
+}
+
+// ParseVoteEndStrategy parses a VOTE_END strategy, the default strategy reminds at VoteEndDefaultLeadTime
+func ParseVoteEndStrategy(strategy string) (*types.VoteEndStrategy, error) {
+	parsed := &types.VoteEndStrategy{}
+	strategy = strings.ToLower(strings.TrimSpace(strategy))
+	if strategy == "" || strategy == SubscribeStrategyDefault {
+		parsed.LeadTimes = []time.Duration{VoteEndDefaultLeadTime}
+		return parsed, nil
+	}
+
+	seen := make(map[time.Duration]bool)
+	for _, token := range strings.Split(strategy, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		if token == VoteEndStrategyUnvoted {
+			parsed.Unvoted = true
+			continue
+		}
+		leadTime, err := ParseLeadTime(token)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[leadTime] {
+			seen[leadTime] = true
+			parsed.LeadTimes = append(parsed.LeadTimes, leadTime)
+		}
+	}
+
+	if len(parsed.LeadTimes) > voteEndMaxLeadTimes {
+		return nil, fmt.Errorf("at most %d lead times are allowed", voteEndMaxLeadTimes)
+	}
+	if len(parsed.LeadTimes) == 0 {
+		parsed.LeadTimes = []time.Duration{VoteEndDefaultLeadTime}
+	}
+	sort.Slice(parsed.LeadTimes, func(i, j int) bool {
+		return parsed.LeadTimes[i] > parsed.LeadTimes[j]
+	})
+	return parsed, nil
+}
+
+// FormatVoteEndStrategy returns the canonical form of the strategy
+func FormatVoteEndStrategy(strategy *types.VoteEndStrategy) string {
+	tokens := make([]string, 0, len(strategy.LeadTimes)+1)
+	for _, leadTime := range strategy.LeadTimes {
+		tokens = append(tokens, FormatLeadTime(leadTime))
+	}
+	if strategy.Unvoted {
+		tokens = append(tokens, VoteEndStrategyUnvoted)
+	}
+	return strings.Join(tokens, ",")
+}
+
+// ParseLeadTime parses a lead time such as "1h", "90m" or "2d"
+func ParseLeadTime(value string) (time.Duration, error) {
+	var leadTime time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid lead time %q", value)
+		}
+		leadTime = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid lead time %q", value)
+		}
+		leadTime = d
+	}
+
+	if leadTime%time.Minute != 0 {
+		return 0, fmt.Errorf("lead time %q must be whole minutes", value)
+	}
+	if leadTime < voteEndMinLeadTime || leadTime > voteEndMaxLeadTime {
+		return 0, fmt.Errorf("lead time %q must be between %s and %s", value, FormatLeadTime(voteEndMinLeadTime), FormatLeadTime(voteEndMaxLeadTime))
+	}
+	return leadTime, nil
+}
+
+// FormatLeadTime formats the lead time in whole hours when possible, otherwise in minutes, e.g. "24h" or "90m"
+func FormatLeadTime(leadTime time.Duration) string {
+	if leadTime%time.Hour == 0 {
+		return fmt.Sprintf("%dh", leadTime/time.Hour)
+	}
+	return fmt.Sprintf("%dm", leadTime/time.Minute)
+}
+
+// VoteEndEventLeadTime returns the canonical lead time of a VOTE_END event
+func VoteEndEventLeadTime(event *dbmodels.NotificationEvent) string {
+	if event.Payload != nil && *event.Payload != "" {
+		var payload types.VoteEndEventPayload
+		if err := json.Unmarshal([]byte(*event.Payload), &payload); err == nil && payload.LeadTime != "" {
+			return payload.LeadTime
+		}
+	}
+	return FormatLeadTime(VoteEndDefaultLeadTime)
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+)
+
+func TestParseLeadTime(t *testing.T) {
+	tests := map[string]time.Duration{
+		"5m":     5 * time.Minute,
+		"90m":    90 * time.Minute,
+		"1h30m":  90 * time.Minute,
+		"24h":    24 * time.Hour,
+		"2d":     48 * time.Hour,
+		"30d":    30 * 24 * time.Hour,
+		"720h":   30 * 24 * time.Hour,
+		"300s":   5 * time.Minute,
+		"4m":     0, // shorter than 5m
+		"4m59s":  0,
+		"30d1m":  0, // not a duration
+		"721h":   0, // longer than 30d
+		"31d":    0,
+		"5m30s":  0, // not whole minutes
+		"90.5m":  0,
+		"0m":     0,
+		"-1h":    0,
+		"-2d":    0,
+		"1.5d":   0,
+		"d":      0,
+		"1w":     0,
+		"hourly": 0,
+		"":       0,
+	}
+	for value, expected := range tests {
+		leadTime, err := ParseLeadTime(value)
+		if expected == 0 {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %s", value, leadTime)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: expected %s, got error %v", value, expected, err)
+		} else if leadTime != expected {
+			t.Errorf("%q: expected %s, got %s", value, expected, leadTime)
+		}
+	}
+}
+
+func TestParseVoteEndStrategy(t *testing.T) {
+	tests := []struct {
+		strategy  string
+		leadTimes []time.Duration
+		unvoted   bool
+	}{
+		{"", []time.Duration{48 * time.Hour}, false},
+		{"true", []time.Duration{48 * time.Hour}, false},
+		{" TRUE ", []time.Duration{48 * time.Hour}, false},
+		{"unvoted", []time.Duration{48 * time.Hour}, true},
+		{"1h", []time.Duration{time.Hour}, false},
+		{"1h,24h,unvoted", []time.Duration{24 * time.Hour, time.Hour}, true},
+		{"unvoted, 2d ,,5m", []time.Duration{48 * time.Hour, 5 * time.Minute}, true},
+		{"60m,1h,unvoted,unvoted", []time.Duration{time.Hour}, true},
+		{"5m,10m,1h,1d,30d", []time.Duration{30 * 24 * time.Hour, 24 * time.Hour, time.Hour, 10 * time.Minute, 5 * time.Minute}, false},
+		// duplicates do not count towards the limit
+		{"5m,10m,1h,1d,30d,24h", []time.Duration{30 * 24 * time.Hour, 24 * time.Hour, time.Hour, 10 * time.Minute, 5 * time.Minute}, false},
+	}
+	for _, test := range tests {
+		parsed, err := ParseVoteEndStrategy(test.strategy)
+		if err != nil {
+			t.Errorf("%q: expected a strategy, got error %v", test.strategy, err)
+			continue
+		}
+		if parsed.Unvoted != test.unvoted || len(parsed.LeadTimes) != len(test.leadTimes) {
+			t.Errorf("%q: expected lead times %v unvoted=%v, got %v unvoted=%v", test.strategy, test.leadTimes, test.unvoted, parsed.LeadTimes, parsed.Unvoted)
+			continue
+		}
+		for i := range test.leadTimes {
+			if parsed.LeadTimes[i] != test.leadTimes[i] {
+				t.Errorf("%q: expected lead times %v, got %v", test.strategy, test.leadTimes, parsed.LeadTimes)
+				break
+			}
+		}
+	}
+
+	for _, strategy := range []string{
+		"5m,10m,1h,1d,2d,30d", // more than 5 lead times
+		"1h,4m",
+		"1h,31d",
+		"1h,5m30s",
+		"1h,voted",
+		"false",
+	} {
+		if parsed, err := ParseVoteEndStrategy(strategy); err == nil {
+			t.Errorf("%q: expected an error, got %+v", strategy, parsed)
+		}
+	}
+}
+
+func TestNormalizeSubscribeStrategy(t *testing.T) {
+	tests := []struct {
+		feature  dbmodels.SubscribeFeatureName
+		strategy string
+		expected string
+	}{
+		{dbmodels.SubscribeFeatureVoteEnd, "", "true"},
+		{dbmodels.SubscribeFeatureVoteEnd, "True", "true"},
+		{dbmodels.SubscribeFeatureVoteEnd, "unvoted", "48h,unvoted"},
+		{dbmodels.SubscribeFeatureVoteEnd, "UNVOTED,1h,24h", "24h,1h,unvoted"},
+		{dbmodels.SubscribeFeatureVoteEnd, "2d", "48h"},
+		{dbmodels.SubscribeFeatureVoteEnd, "90m,1h30m", "90m"},
+		{dbmodels.SubscribeFeatureVoteEnd, "300s,30d", "720h,5m"},
+		{dbmodels.SubscribeFeatureProposalNew, "", "true"},
+		{dbmodels.SubscribeFeatureProposalNew, " true ", "true"},
+	}
+	for _, test := range tests {
+		normalized, err := NormalizeSubscribeStrategy(test.feature, test.strategy)
+		if err != nil {
+			t.Errorf("%s %q: expected %q, got error %v", test.feature, test.strategy, test.expected, err)
+		} else if normalized != test.expected {
+			t.Errorf("%s %q: expected %q, got %q", test.feature, test.strategy, test.expected, normalized)
+		}
+	}
+
+	for _, test := range []struct {
+		feature  dbmodels.SubscribeFeatureName
+		strategy string
+	}{
+		{dbmodels.SubscribeFeatureVoteEnd, "1m"},
+		{dbmodels.SubscribeFeatureVoteEnd, "1h,2h,3h,4h,5h,6h"},
+		{dbmodels.SubscribeFeatureProposalNew, "1h"},
+		{dbmodels.SubscribeFeatureVoteEmitted, "unvoted"},
+	} {
+		if normalized, err := NormalizeSubscribeStrategy(test.feature, test.strategy); err == nil {
+			t.Errorf("%s %q: expected an error, got %q", test.feature, test.strategy, normalized)
+		}
+	}
+}
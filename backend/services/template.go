@@ -2,6 +2,7 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	tplHtml "html/template"
@@ -31,12 +32,14 @@ import (
 )
 
 type TemplateService struct {
-	daoService       *DaoService
-	proposalService  *ProposalService
-	daoConfigService *DaoConfigService
-	htmlTemplates    map[string]*tplHtml.Template
-	textTemplates    map[string]*tplText.Template
-	userService      *UserService
+	daoService            *DaoService
+	proposalService       *ProposalService
+	daoConfigService      *DaoConfigService
+	proposalActionService *ProposalActionService
+	htmlTemplates         map[string]*tplHtml.Template
+	textTemplates         map[string]*tplText.Template
+	userService           *UserService
+	unsubscribeService    *UnsubscribeService
 }
 
 func NewTemplateService() *TemplateService {
@@ -87,37 +90,41 @@ func NewTemplateService() *TemplateService {
 		textTmpls[fileName] = tmpl
 	}
 	return &TemplateService{
-		daoService:       NewDaoService(),
-		proposalService:  NewProposalService(),
-		daoConfigService: NewDaoConfigService(),
-		htmlTemplates:    htmlTmpls,
-		textTemplates:    textTmpls,
-		userService:      NewUserService(),
+		daoService:            NewDaoService(),
+		proposalService:       NewProposalService(),
+		daoConfigService:      NewDaoConfigService(),
+		proposalActionService: NewProposalActionService(),
+		htmlTemplates:         htmlTmpls,
+		textTemplates:         textTmpls,
+		userService:           NewUserService(),
+		unsubscribeService:    NewUnsubscribeService(),
 	}
 }
 
 type templateNotificationRecordData struct {
-	DegovSiteConfig types.DegovSiteConfig  `json:"degov_site_config"`
-	EmailStyle      *types.EmailStyle      `json:"email_style"`
-	Title           *string                `json:"title"`
-	DaoConfig       *types.DaoConfig       `json:"dao_config"`
-	Dao             *gqlmodels.Dao         `json:"dao"`
-	Proposal        *emailProposalInfo     `json:"proposal"`
-	Vote            *emailVoteInfo         `json:"vote,omitempty"`
-	PayloadData     map[string]interface{} `json:"payload_data"`
-	EventID         string                 `json:"event_id"`
-	UserID          string                 `json:"user_id"`
-	UserAddress     string                 `json:"user_address"`
-	EnsName         *string                `json:"ens_name"`
+	DegovSiteConfig types.DegovSiteConfig   `json:"degov_site_config"`
+	EmailStyle      *types.EmailStyle       `json:"email_style"`
+	Title           *string                 `json:"title"`
+	DaoConfig       *types.DaoConfig        `json:"dao_config"`
+	Dao             *gqlmodels.Dao          `json:"dao"`
+	Proposal        *emailProposalInfo      `json:"proposal"`
+	Vote            *emailVoteInfo          `json:"vote,omitempty"`
+	PayloadData     map[string]interface{}  `json:"payload_data"`
+	EventID         string                  `json:"event_id"`
+	UserID          string                  `json:"user_id"`
+	UserAddress     string                  `json:"user_address"`
+	EnsName         *string                 `json:"ens_name"`
+	Unsubscribe     *types.UnsubscribeLinks `json:"unsubscribe,omitempty"`
 }
 
 type emailProposalInfo struct {
-	ProposalDb                  *dbmodels.ProposalTracking `json:"proposal_db"`
-	ProposalIndexer             *internal.Proposal         `json:"proposal_indexer"`
-	ProposalDescriptionMarkdown *string                    `json:"proposal_description_markdown"`
-	ProposalDescriptionHtml     *tplHtml.HTML              `json:"proposal_description_html"`
-	ProposerEnsName             *string                    `json:"proposer_ens_name"`
-	TweetLink                   *string                    `json:"tweet_link"`
+	ProposalDb                  *dbmodels.ProposalTracking  `json:"proposal_db"`
+	ProposalIndexer             *internal.Proposal          `json:"proposal_indexer"`
+	ProposalDescriptionMarkdown *string                     `json:"proposal_description_markdown"`
+	ProposalDescriptionHtml     *tplHtml.HTML               `json:"proposal_description_html"`
+	ProposerEnsName             *string                     `json:"proposer_ens_name"`
+	TweetLink                   *string                     `json:"tweet_link"`
+	Actions                     []*gqlmodels.ProposalAction `json:"actions"`
 }
 
 type emailVoteInfo struct {
@@ -158,12 +165,17 @@ func (s *TemplateService) getTemplateFileName(notificationType dbmodels.Subscrib
 		return "vote_end." + mode
 	case dbmodels.SubscribeFeatureVoteEmitted:
 		return "vote_emitted." + mode
+	case dbmodels.SubscribeFeatureProposalExecutable:
+		return "proposal_executable." + mode
+	case dbmodels.SubscribeFeatureProposalExpiring:
+		return "proposal_expiring." + mode
 	default:
 		return "unknown." + mode // fallback
 	}
 }
 
-func (s *TemplateService) GenerateTemplateByNotificationRecord(record *dbmodels.NotificationRecord) (*types.TemplateOutput, error) {
+// GenerateTemplateByNotificationRecord renders the notification record, once for emails and once for the other channels
+func (s *TemplateService) GenerateTemplateByNotificationRecord(record *dbmodels.NotificationRecord) (*types.NotificationTemplateOutput, error) {
 	// Get DAO information
 	dao, err := s.daoService.Inspect(types.BasicInput[string]{
 		User:  nil,
@@ -221,7 +233,17 @@ func (s *TemplateService) GenerateTemplateByNotificationRecord(record *dbmodels.
 	}
 
 	if record.Type == dbmodels.SubscribeFeatureVoteEnd {
-		voteIndexer, err := degovIndexer.QueryVoteByVoter(proposal.ProposalID, record.UserAddress)
+		// the user may have voted with any of its linked addresses
+		voters := []string{record.UserAddress}
+		if addresses, err := s.userService.ListAddresses(record.UserID); err != nil {
+			slog.Warn("failed to list addresses of user", "user_id", record.UserID, "error", err)
+		} else if len(addresses) > 0 {
+			voters = voters[:0]
+			for _, address := range addresses {
+				voters = append(voters, address.Address)
+			}
+		}
+		voteIndexer, err := degovIndexer.QueryVoteByVoters(proposal.ProposalID, voters)
 		if err != nil {
 			slog.Warn("failed to get vote for this user", "user_address", record.UserAddress, "error", err)
 		} else {
@@ -251,6 +273,13 @@ func (s *TemplateService) GenerateTemplateByNotificationRecord(record *dbmodels.
 		} else {
 			emailProposal.ProposerEnsName = ensName
 		}
+
+		actions, err := s.proposalActionService.Actions(context.Background(), dao.Code, proposal.ProposalID)
+		if err != nil {
+			slog.Warn("failed to decode proposal actions", "proposal_id", proposal.ProposalID, "error", err)
+		} else {
+			emailProposal.Actions = actions
+		}
 		degovAgent := internal.NewDegovAgent()
 		agentVote, err := degovAgent.QueryVote(int(dao.ChainID), proposal.ProposalID)
 		if err != nil {
@@ -282,6 +311,20 @@ func (s *TemplateService) GenerateTemplateByNotificationRecord(record *dbmodels.
 		}
 	case dbmodels.SubscribeFeatureVoteEmitted:
 		title = fmt.Sprintf("[%s] Vote Emitted: %s", dao.Name, proposal.Title)
+	case dbmodels.SubscribeFeatureProposalExecutable:
+		title = fmt.Sprintf("[%s] Ready to Execute: %s", dao.Name, proposal.Title)
+	case dbmodels.SubscribeFeatureProposalExpiring:
+		title = fmt.Sprintf("[%s] Execution Window Closing: %s", dao.Name, proposal.Title)
+		if proposal.ExpiresAt != nil {
+			payloadData["TimeRemaining"] = utils.FormatDurationShort(time.Until(*proposal.ExpiresAt))
+		}
+	}
+	// timelock times as millisecond timestamps, the format the template functions take
+	if proposal.Eta != nil {
+		payloadData["Eta"] = strconv.FormatInt(proposal.Eta.UnixMilli(), 10)
+	}
+	if proposal.ExpiresAt != nil {
+		payloadData["ExpiresAt"] = strconv.FormatInt(proposal.ExpiresAt.UnixMilli(), 10)
 	}
 
 	ensName, err := s.userService.GetENSName(record.UserAddress)
@@ -308,26 +351,110 @@ func (s *TemplateService) GenerateTemplateByNotificationRecord(record *dbmodels.
 		UserAddress:     record.UserAddress,
 		EnsName:         ensName,
 	}
+	output := &types.NotificationTemplateOutput{}
+	output.Default, err = s.renderNotificationRecord(record, &templateData)
+	if err != nil {
+		return nil, err
+	}
+
+	// the unsubscribe links act without login, they are only rendered into the email which is read by the user alone
+	emailData := templateData
+	emailData.Unsubscribe, err = s.unsubscribeService.LinksForRecord(record)
+	if err != nil {
+		slog.Warn("failed to build unsubscribe links", "record_id", record.ID, "error", err)
+	}
+	output.Email, err = s.renderNotificationRecord(record, &emailData)
+	if err != nil {
+		return nil, err
+	}
+	if emailData.Unsubscribe != nil {
+		output.Email.ListUnsubscribeURL = emailData.Unsubscribe.AllEmailURL
+	}
+	return output, nil
+}
 
+func (s *TemplateService) renderNotificationRecord(record *dbmodels.NotificationRecord, data *templateNotificationRecordData) (*types.TemplateOutput, error) {
 	richTemplateFileName := s.getTemplateFileName(record.Type, "html")
 	plainTemplateFileName := s.getTemplateFileName(record.Type, "md")
 
-	richText, err := s.renderTemplate(richTemplateFileName, templateData)
+	richText, err := s.renderTemplate(richTemplateFileName, data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to render rich text template %s: %w", richTemplateFileName, err)
 	}
-	plainText, err := s.renderTemplate(plainTemplateFileName, templateData)
+	plainText, err := s.renderTemplate(plainTemplateFileName, data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to render plain text template %s: %w", plainTemplateFileName, err)
 	}
 
 	return &types.TemplateOutput{
-		Title:            utils.TruncateText(title, 80),
+		Title:            utils.TruncateText(*data.Title, 80),
 		RichTextContent:  richText,
 		PlainTextContent: plainText,
+		Summary:          buildTemplateSummary(record, data),
 	}, nil
 }
 
+// buildTemplateSummary collects the key facts of the notification for channels rendering their own layout
+func buildTemplateSummary(record *dbmodels.NotificationRecord, data *templateNotificationRecordData) *types.TemplateSummary {
+	proposalDb := data.Proposal.ProposalDb
+	proposalIndexer := data.Proposal.ProposalIndexer
+
+	summary := &types.TemplateSummary{
+		Type:          record.Type,
+		DaoName:       data.Dao.Name,
+		ProposalTitle: proposalDb.Title,
+		ProposalLink:  proposalDb.ProposalLink,
+		State:         proposalDb.State,
+	}
+
+	totalVotePower := calculateTotalVotePower(proposalIndexer)
+	if proposalIndexer.MetricsVotesWeightForSum != nil {
+		summary.PercentFor = utils.CalculateBigIntRatioPercentage(*proposalIndexer.MetricsVotesWeightForSum, totalVotePower)
+	}
+	if proposalIndexer.MetricsVotesWeightAgainstSum != nil {
+		summary.PercentAgainst = utils.CalculateBigIntRatioPercentage(*proposalIndexer.MetricsVotesWeightAgainstSum, totalVotePower)
+	}
+	if proposalIndexer.MetricsVotesWeightAbstainSum != nil {
+		summary.PercentAbstain = utils.CalculateBigIntRatioPercentage(*proposalIndexer.MetricsVotesWeightAbstainSum, totalVotePower)
+	}
+
+	switch record.Type {
+	case dbmodels.SubscribeFeatureProposalNew:
+		summary.Headline = fmt.Sprintf("A new proposal has been created in %s", data.Dao.Name)
+	case dbmodels.SubscribeFeatureProposalStateChanged:
+		summary.Headline = fmt.Sprintf("The proposal status has changed to %s", proposalDb.State)
+	case dbmodels.SubscribeFeatureVoteEnd:
+		summary.Headline = "Voting on this proposal is ending soon"
+		if timeRemaining, ok := data.PayloadData["TimeRemaining"].(string); ok {
+			summary.Headline = fmt.Sprintf("Voting on this proposal ends in %s", timeRemaining)
+		}
+	case dbmodels.SubscribeFeatureVoteEmitted:
+		summary.Headline = "A new vote has been cast on this proposal"
+	case dbmodels.SubscribeFeatureProposalExecutable:
+		summary.Headline = "The timelock has passed, this proposal can be executed now"
+	case dbmodels.SubscribeFeatureProposalExpiring:
+		summary.Headline = "The execution window of this proposal is closing soon"
+		if timeRemaining, ok := data.PayloadData["TimeRemaining"].(string); ok {
+			summary.Headline = fmt.Sprintf("This proposal expires in %s unless it is executed", timeRemaining)
+		}
+	}
+
+	summary.Links = append(summary.Links, types.TemplateSummaryLink{Name: "View Proposal", URL: proposalDb.ProposalLink})
+	if data.DaoConfig.OffChainDiscussionURL != "" {
+		summary.Links = append(summary.Links, types.TemplateSummaryLink{Name: "Join Discussion", URL: data.DaoConfig.OffChainDiscussionURL})
+	}
+	if data.Proposal.TweetLink != nil {
+		summary.Links = append(summary.Links, types.TemplateSummaryLink{Name: "View Tweet", URL: *data.Proposal.TweetLink})
+	}
+	if record.Type == dbmodels.SubscribeFeatureVoteEmitted && data.Vote.VoteIndexer != nil && len(data.DaoConfig.Chain.Explorers) > 0 {
+		summary.Links = append(summary.Links, types.TemplateSummaryLink{
+			Name: "View Vote",
+			URL:  fmt.Sprintf("%s/tx/%s", data.DaoConfig.Chain.Explorers[0], data.Vote.VoteIndexer.TransactionHash),
+		})
+	}
+	return summary
+}
+
 func (s *TemplateService) renderTemplate(templateName string, data interface{}) (string, error) {
 	var finData interface{}
 	templateData, serr := structToMap(data)
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	gqlmodels "github.com/ringecosystem/degov-apps/graph/models"
+	"github.com/ringecosystem/degov-apps/internal"
+	"github.com/ringecosystem/degov-apps/internal/config"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+const (
+	// TrackingSourceIndexer discovers proposals and votes through the degov indexer
+	TrackingSourceIndexer = "indexer"
+	// TrackingSourceChain reads the governor logs from the chain
+	TrackingSourceChain = "chain"
+	// TrackingSourceAuto uses the indexer and falls back to the chain logs when a query of the indexer fails
+	TrackingSourceAuto = "auto"
+)
+
+type TrackingSourceService struct {
+	daoConfigService *DaoConfigService
+}
+
+func NewTrackingSourceService() *TrackingSourceService {
+	return &TrackingSourceService{
+		daoConfigService: NewDaoConfigService(),
+	}
+}
+
+// SourceKind returns the tracking source of the dao, TRACKING_SOURCE_OVERRIDES ("dao-a=chain,dao-b=auto")
+// overrides the default TRACKING_SOURCE, which is the indexer
+func (s *TrackingSourceService) SourceKind(daoCode string) string {
+	for _, override := range strings.Split(config.GetString("TRACKING_SOURCE_OVERRIDES"), ",") {
+		code, kind, ok := strings.Cut(strings.TrimSpace(override), "=")
+		if ok && strings.TrimSpace(code) == daoCode {
+			return normalizeTrackingSource(kind)
+		}
+	}
+	return normalizeTrackingSource(config.GetString("TRACKING_SOURCE"))
+}
+
+func normalizeTrackingSource(kind string) string {
+	switch kind = strings.ToLower(strings.TrimSpace(kind)); kind {
+	case TrackingSourceChain, TrackingSourceAuto:
+		return kind
+	case "", TrackingSourceIndexer:
+		return TrackingSourceIndexer
+	default:
+		slog.Warn("Unknown tracking source, using the indexer", "source", kind)
+		return TrackingSourceIndexer
+	}
+}
+
+// Source returns the source the tracking tasks discover the proposals and votes of the dao from
+func (s *TrackingSourceService) Source(daoCode string, daoConfig *types.DaoConfig) internal.GovernanceSource {
+	indexer := internal.NewDegovIndexer(daoConfig.Indexer.Endpoint)
+	kind := s.SourceKind(daoCode)
+	if kind == TrackingSourceIndexer {
+		return indexer
+	}
+
+	chain, err := s.ChainLogSource(daoCode, daoConfig)
+	if err != nil {
+		slog.Warn("Chain log source unavailable, using the indexer", "dao_code", daoCode, "error", err)
+		return indexer
+	}
+	if kind == TrackingSourceChain {
+		return chain
+	}
+	return &fallbackSource{daoCode: daoCode, primary: indexer, fallback: chain}
+}
+
+// ChainLogSource returns the chain log source of the dao, it scans the governor from the start block of the indexer
+func (s *TrackingSourceService) ChainLogSource(daoCode string, daoConfig *types.DaoConfig) (*internal.ChainLogSource, error) {
+	if !common.IsHexAddress(daoConfig.Contracts.Governor) {
+		return nil, fmt.Errorf("invalid governor address: %q", daoConfig.Contracts.Governor)
+	}
+	if daoConfig.Chain.ID == 0 {
+		return nil, fmt.Errorf("chain id is not configured")
+	}
+
+	pool := internal.GetRPCPool(daoConfig.Chain.ID, daoConfig.Chain.RPCs)
+	return internal.GetChainLogSource(daoCode, pool, internal.ChainLogSourceConfig{
+		Governor:      common.HexToAddress(daoConfig.Contracts.Governor),
+		StartBlock:    uint64(max(daoConfig.Indexer.StartBlock, 0)),
+		BlockRange:    uint64(max(config.GetInt("CHAIN_LOG_BLOCK_RANGE"), 0)),
+		MaxRanges:     config.GetInt("CHAIN_LOG_MAX_RANGES"),
+		Confirmations: chainLogConfirmations(daoConfig.Chain.ID),
+	}), nil
+}
+
+// chainLogConfirmations returns CHAIN_LOG_CONFIRMATIONS_<chainId>, chains without their own value use CHAIN_LOG_CONFIRMATIONS
+func chainLogConfirmations(chainID int) uint64 {
+	key := fmt.Sprintf("CHAIN_LOG_CONFIRMATIONS_%d", chainID)
+	if config.GetString(key) == "" {
+		key = "CHAIN_LOG_CONFIRMATIONS"
+	}
+	return uint64(max(config.GetInt(key), 0))
+}
+
+// fallbackSource queries the primary source and switches to the fallback for the queries the primary fails
+type fallbackSource struct {
+	daoCode  string
+	primary  internal.GovernanceSource
+	fallback internal.GovernanceSource
+}
+
+func (f *fallbackSource) QueryProposalsOffset(ctx context.Context, offset int) ([]internal.Proposal, error) {
+	proposals, err := f.primary.QueryProposalsOffset(ctx, offset)
+	if err == nil {
+		return proposals, nil
+	}
+	slog.Warn("Failed to query proposals from the indexer, falling back to chain logs", "dao_code", f.daoCode, "offset", offset, "error", err)
+	return f.fallback.QueryProposalsOffset(ctx, offset)
+}
+
+func (f *fallbackSource) QueryVotesOffset(ctx context.Context, offset int, proposalId string) ([]internal.VoteCast, error) {
+	votes, err := f.primary.QueryVotesOffset(ctx, offset, proposalId)
+	if err == nil {
+		return votes, nil
+	}
+	slog.Warn("Failed to query votes from the indexer, falling back to chain logs", "dao_code", f.daoCode, "proposal_id", proposalId, "offset", offset, "error", err)
+	return f.fallback.QueryVotesOffset(ctx, offset, proposalId)
+}
+
+// Reconcile compares the proposals and vote counts of the indexer with the governor logs of the chain.
+// Proposals after the last scanned block are not reported as missing on chain, vote counts are only
+// compared once the scan reached the confirmed head of the chain
+func (s *TrackingSourceService) Reconcile(daoCode string) (*gqlmodels.TrackingSourceReconciliation, error) {
+	daoConfig, err := s.daoConfigService.StandardConfig(daoCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dao config: %w", err)
+	}
+	chain, err := s.ChainLogSource(daoCode, daoConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &gqlmodels.TrackingSourceReconciliation{
+		DaoCode:          daoCode,
+		Source:           s.SourceKind(daoCode),
+		MissingInIndexer: []string{},
+		MissingOnChain:   []string{},
+		VoteMismatches:   []*gqlmodels.TrackingSourceVoteMismatch{},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if err := chain.Sync(ctx); err != nil {
+		message := err.Error()
+		output.ChainError = &message
+	}
+	scannedBlock, headBlock := chain.Progress()
+	output.ChainScannedBlock = strconv.FormatUint(scannedBlock, 10)
+	output.ChainHeadBlock = strconv.FormatUint(headBlock, 10)
+	output.ChainComplete = chain.Complete()
+
+	chainProposals := chain.Proposals()
+	output.ChainProposals = int32(len(chainProposals))
+
+	indexerProposals, err := queryAllProposals(ctx, internal.NewDegovIndexer(daoConfig.Indexer.Endpoint))
+	if err != nil {
+		message := err.Error()
+		output.IndexerError = &message
+	}
+	output.IndexerProposals = int32(len(indexerProposals))
+
+	onChain := make(map[string]bool, len(chainProposals))
+	for _, proposal := range chainProposals {
+		onChain[internal.NormalizeProposalID(proposal.ProposalID)] = true
+	}
+	inIndexer := make(map[string]bool, len(indexerProposals))
+	for _, proposal := range indexerProposals {
+		proposalID := internal.NormalizeProposalID(proposal.ProposalID)
+		inIndexer[proposalID] = true
+
+		if !onChain[proposalID] {
+			blockNumber, err := strconv.ParseUint(proposal.BlockNumber, 10, 64)
+			if err == nil && blockNumber <= scannedBlock {
+				output.MissingOnChain = append(output.MissingOnChain, proposal.ProposalID)
+			}
+			continue
+		}
+		if !output.ChainComplete {
+			continue
+		}
+		indexerVotes := 0
+		if proposal.MetricsVotesCount != nil {
+			indexerVotes = *proposal.MetricsVotesCount
+		}
+		if chainVotes := chain.VotesCount(proposalID); chainVotes != indexerVotes {
+			output.VoteMismatches = append(output.VoteMismatches, &gqlmodels.TrackingSourceVoteMismatch{
+				ProposalID:   proposal.ProposalID,
+				IndexerVotes: int32(indexerVotes),
+				ChainVotes:   int32(chainVotes),
+			})
+		}
+	}
+	if output.IndexerError == nil {
+		for _, proposal := range chainProposals {
+			if !inIndexer[internal.NormalizeProposalID(proposal.ProposalID)] {
+				output.MissingInIndexer = append(output.MissingInIndexer, proposal.ProposalID)
+			}
+		}
+	}
+
+	output.Diverged = output.IndexerError != nil || len(output.MissingInIndexer) > 0 || len(output.MissingOnChain) > 0 || len(output.VoteMismatches) > 0
+	if output.Diverged {
+		slog.Warn("Tracking sources diverged",
+			"dao_code", daoCode,
+			"indexer_proposals", output.IndexerProposals,
+			"chain_proposals", output.ChainProposals,
+			"missing_in_indexer", len(output.MissingInIndexer),
+			"missing_on_chain", len(output.MissingOnChain),
+			"vote_mismatches", len(output.VoteMismatches),
+			"indexer_error", output.IndexerError != nil)
+	}
+	return output, nil
+}
+
+func queryAllProposals(ctx context.Context, indexer *internal.DegovIndexer) ([]internal.Proposal, error) {
+	var proposals []internal.Proposal
+	for {
+		page, err := indexer.QueryProposalsOffset(ctx, len(proposals))
+		if err != nil {
+			return proposals, err
+		}
+		if len(page) == 0 {
+			return proposals, nil
+		}
+		proposals = append(proposals, page...)
+	}
+}
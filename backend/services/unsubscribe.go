@@ -0,0 +1,121 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	"github.com/ringecosystem/degov-apps/internal/config"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+// UnsubscribeService signs and applies the one-click unsubscribe tokens of notification emails.
+// A token is base64url(json claims) + "." + base64url(HMAC-SHA256(secret, encoded claims)), it needs no login
+type UnsubscribeService struct {
+	secret           []byte
+	subscribeService *SubscribeService
+}
+
+func NewUnsubscribeService() *UnsubscribeService {
+	return &UnsubscribeService{
+		secret:           unsubscribeSecret(),
+		subscribeService: NewSubscribeService(),
+	}
+}
+
+// unsubscribeSecret returns UNSUBSCRIBE_TOKEN_SECRET, without it a key is derived from JWT_SECRET,
+// so the jwt signing key itself never signs anything else
+func unsubscribeSecret() []byte {
+	if secret := config.GetString("UNSUBSCRIBE_TOKEN_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	mac := hmac.New(sha256.New, []byte(config.GetStringRequired("JWT_SECRET")))
+	mac.Write([]byte("unsubscribe"))
+	return mac.Sum(nil)
+}
+
+func (s *UnsubscribeService) Sign(claims types.UnsubscribeClaims) (string, error) {
+	if claims.Expiry == 0 {
+		claims.Expiry = time.Now().Add(config.GetDuration("UNSUBSCRIBE_TOKEN_TTL")).Unix()
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal unsubscribe claims: %w", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.signature(encoded), nil
+}
+
+func (s *UnsubscribeService) Verify(token string) (*types.UnsubscribeClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.signature(encoded))) {
+		return nil, fmt.Errorf("invalid unsubscribe token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid unsubscribe token")
+	}
+	var claims types.UnsubscribeClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("invalid unsubscribe token")
+	}
+	if claims.UserID == "" || time.Now().Unix() > claims.Expiry {
+		return nil, fmt.Errorf("unsubscribe token is expired")
+	}
+	return &claims, nil
+}
+
+// Unsubscribe verifies the token and applies it
+func (s *UnsubscribeService) Unsubscribe(token string) (*types.UnsubscribeClaims, error) {
+	claims, err := s.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.subscribeService.ApplyUnsubscribe(*claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// LinksForRecord builds the unsubscribe urls of every scope covering the notification record
+func (s *UnsubscribeService) LinksForRecord(record *dbmodels.NotificationRecord) (*types.UnsubscribeLinks, error) {
+	scopes := []types.UnsubscribeClaims{
+		{Scope: types.UnsubscribeScopeDao, DaoCode: record.DaoCode},
+		{Scope: types.UnsubscribeScopeProposal, DaoCode: record.DaoCode, ProposalID: record.ProposalID},
+		{Scope: types.UnsubscribeScopeFeature, DaoCode: record.DaoCode, Feature: record.Type},
+		{Scope: types.UnsubscribeScopeAllEmail},
+	}
+	urls := make([]string, 0, len(scopes))
+	for _, claims := range scopes {
+		claims.UserID = record.UserID
+		token, err := s.Sign(claims)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, UnsubscribeURL(token))
+	}
+	return &types.UnsubscribeLinks{
+		DaoURL:      urls[0],
+		ProposalURL: urls[1],
+		FeatureURL:  urls[2],
+		AllEmailURL: urls[3],
+	}, nil
+}
+
+// UnsubscribeURL is the url of the unsubscribe route for the token
+func UnsubscribeURL(token string) string {
+	baseURL := strings.TrimRight(config.GetString("DEGOV_API_URL"), "/")
+	return baseURL + "/unsubscribe?token=" + url.QueryEscape(token)
+}
+
+func (s *UnsubscribeService) signature(encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
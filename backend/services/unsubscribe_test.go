@@ -0,0 +1,181 @@
+package services
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+func testUnsubscribeService(secret string) *UnsubscribeService {
+	return &UnsubscribeService{secret: []byte(secret)}
+}
+
+func TestUnsubscribeTokenVerify(t *testing.T) {
+	service := testUnsubscribeService("secret")
+	claims := types.UnsubscribeClaims{
+		UserID:  "user",
+		Scope:   types.UnsubscribeScopeDao,
+		DaoCode: "dao",
+		Expiry:  time.Now().Add(time.Hour).Unix(),
+	}
+	token, err := service.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified, err := service.Verify(token)
+	if err != nil {
+		t.Fatalf("expected the token to be valid: %v", err)
+	}
+	if *verified != claims {
+		t.Fatalf("expected claims %+v, got %+v", claims, *verified)
+	}
+
+	encoded, signature, _ := strings.Cut(token, ".")
+	// the scope widened to every email, under the signature of the dao scope
+	tampered := base64.RawURLEncoding.EncodeToString([]byte(`{"u":"user","s":"ALL_EMAIL","e":9999999999}`))
+	expired, err := service.Sign(types.UnsubscribeClaims{UserID: "user", Scope: types.UnsubscribeScopeDao, Expiry: time.Now().Add(-time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := testUnsubscribeService("other secret").Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"tampered claims":       tampered + "." + signature,
+		"tampered signature":    encoded + "." + strings.Repeat("A", len(signature)),
+		"missing signature":     encoded,
+		"empty signature":       encoded + ".",
+		"signed by another key": otherKey,
+		"expired":               expired,
+		"not base64":            "%%%." + service.signature("%%%"),
+		"not json":              base64.RawURLEncoding.EncodeToString([]byte("x")) + "." + service.signature(base64.RawURLEncoding.EncodeToString([]byte("x"))),
+	}
+	for name, token := range tests {
+		if _, err := service.Verify(token); err == nil {
+			t.Errorf("%s: expected the token to be rejected", name)
+		}
+	}
+}
+
+func TestUnsubscribeSignDefaultsExpiry(t *testing.T) {
+	service := testUnsubscribeService("secret")
+	token, err := service.Sign(types.UnsubscribeClaims{UserID: "user", Scope: types.UnsubscribeScopeAllEmail})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := service.Verify(token)
+	if err != nil {
+		t.Fatalf("expected the token to be valid: %v", err)
+	}
+	if claims.Expiry <= time.Now().Unix() {
+		t.Fatalf("expected an expiry in the future, got %d", claims.Expiry)
+	}
+}
+
+func TestApplyUnsubscribe(t *testing.T) {
+	db := newTestDB(t,
+		&dbmodels.User{},
+		&dbmodels.UserSubscribedDao{},
+		&dbmodels.UserSubscribedProposal{},
+		&dbmodels.SubscribeFeature{},
+		&dbmodels.NotificationChannel{},
+	)
+	service := &SubscribeService{db: db}
+
+	proposalID := "0x01"
+	otherProposalID := "0x02"
+	rows := []interface{}{
+		&dbmodels.User{ID: "user", Address: "0xuser"},
+		&dbmodels.UserSubscribedDao{ID: "dao", DaoCode: "dao", UserID: "user", UserAddress: "0xuser", State: dbmodels.SubscribeStateActive},
+		&dbmodels.UserSubscribedDao{ID: "other-dao", DaoCode: "other", UserID: "user", UserAddress: "0xuser", State: dbmodels.SubscribeStateActive},
+		&dbmodels.UserSubscribedProposal{ID: "proposal", DaoCode: "dao", ProposalID: proposalID, UserID: "user", UserAddress: "0xuser", State: dbmodels.SubscribeStateActive},
+		&dbmodels.UserSubscribedProposal{ID: "other-proposal", DaoCode: "dao", ProposalID: otherProposalID, UserID: "user", UserAddress: "0xuser", State: dbmodels.SubscribeStateActive},
+		&dbmodels.SubscribeFeature{ID: "feature-dao", DaoCode: "dao", UserID: "user", Feature: dbmodels.SubscribeFeatureVoteEnd},
+		&dbmodels.SubscribeFeature{ID: "feature-proposal", DaoCode: "dao", UserID: "user", Feature: dbmodels.SubscribeFeatureVoteEnd, ProposalID: &proposalID},
+		&dbmodels.SubscribeFeature{ID: "feature-other", DaoCode: "dao", UserID: "user", Feature: dbmodels.SubscribeFeatureProposalNew},
+		&dbmodels.NotificationChannel{ID: "email", UserID: "user", UserAddress: "0xuser", Verified: 1, ChannelType: dbmodels.NotificationChannelTypeEmail, ChannelValue: "user@example.com"},
+		&dbmodels.NotificationChannel{ID: "webhook", UserID: "user", UserAddress: "0xuser", Verified: 1, ChannelType: dbmodels.NotificationChannelTypeWebhook, ChannelValue: "https://example.com"},
+	}
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	daoState := func(id string) dbmodels.SubscribeState {
+		var row dbmodels.UserSubscribedDao
+		if err := db.Where("id = ?", id).First(&row).Error; err != nil {
+			t.Fatal(err)
+		}
+		return row.State
+	}
+	proposalState := func(id string) dbmodels.SubscribeState {
+		var row dbmodels.UserSubscribedProposal
+		if err := db.Where("id = ?", id).First(&row).Error; err != nil {
+			t.Fatal(err)
+		}
+		return row.State
+	}
+	features := func() []string {
+		var ids []string
+		if err := db.Model(&dbmodels.SubscribeFeature{}).Order("id").Pluck("id", &ids).Error; err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+	verified := func(id string) int {
+		var row dbmodels.NotificationChannel
+		if err := db.Where("id = ?", id).First(&row).Error; err != nil {
+			t.Fatal(err)
+		}
+		return row.Verified
+	}
+
+	if err := service.ApplyUnsubscribe(types.UnsubscribeClaims{UserID: "user", Scope: types.UnsubscribeScopeProposal, DaoCode: "dao", ProposalID: proposalID}); err != nil {
+		t.Fatal(err)
+	}
+	if proposalState("proposal") != dbmodels.SubscribeStateInactive || proposalState("other-proposal") != dbmodels.SubscribeStateActive {
+		t.Fatal("expected only the proposal of the token to be unsubscribed")
+	}
+	if daoState("dao") != dbmodels.SubscribeStateActive {
+		t.Fatal("expected the dao to stay subscribed")
+	}
+
+	if err := service.ApplyUnsubscribe(types.UnsubscribeClaims{UserID: "user", Scope: types.UnsubscribeScopeFeature, DaoCode: "dao", Feature: dbmodels.SubscribeFeatureVoteEnd}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(features(), ","); got != "feature-other" {
+		t.Fatalf("expected the feature to be removed from the dao and its proposals, left %s", got)
+	}
+
+	if err := service.ApplyUnsubscribe(types.UnsubscribeClaims{UserID: "user", Scope: types.UnsubscribeScopeDao, DaoCode: "dao"}); err != nil {
+		t.Fatal(err)
+	}
+	if daoState("dao") != dbmodels.SubscribeStateInactive || daoState("other-dao") != dbmodels.SubscribeStateActive {
+		t.Fatal("expected only the dao of the token to be unsubscribed")
+	}
+
+	if err := service.ApplyUnsubscribe(types.UnsubscribeClaims{UserID: "user", Scope: types.UnsubscribeScopeAllEmail}); err != nil {
+		t.Fatal(err)
+	}
+	if verified("email") != 0 || verified("webhook") != 1 {
+		t.Fatal("expected only the email channels to be unverified")
+	}
+
+	// a link can be used again, also when the subscription is gone
+	if err := service.ApplyUnsubscribe(types.UnsubscribeClaims{UserID: "user", Scope: types.UnsubscribeScopeDao, DaoCode: "unknown"}); err != nil {
+		t.Fatalf("expected unsubscribing an unknown dao to succeed: %v", err)
+	}
+	if err := service.ApplyUnsubscribe(types.UnsubscribeClaims{UserID: "user", Scope: "UNKNOWN"}); err == nil {
+		t.Fatal("expected an unknown scope to be rejected")
+	}
+	if err := service.ApplyUnsubscribe(types.UnsubscribeClaims{UserID: "nobody", Scope: types.UnsubscribeScopeAllEmail}); err == nil {
+		t.Fatal("expected an unknown user to be rejected")
+	}
+}
@@ -5,13 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"os"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/ringecosystem/degov-apps/database"
 	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	"github.com/ringecosystem/degov-apps/internal"
 	"github.com/ringecosystem/degov-apps/internal/utils"
 	"github.com/wealdtech/go-ens/v3"
 
@@ -20,20 +22,36 @@ import (
 )
 
 type UserService struct {
-	db *gorm.DB
+	db                 *gorm.DB
+	userSessionService *UserSessionService
 }
 
 func NewUserService() *UserService {
 	return &UserService{
-		db: database.GetDB(),
+		db:                 database.GetDB(),
+		userSessionService: NewUserSessionService(),
 	}
 }
 
 func (s *UserService) Modify(input dbmodels.User) (*dbmodels.User, error) {
 	address := strings.ToLower(input.Address)
-	// check if address already exists
+	// check if address already exists, it may be a linked address of the user
 	var existingUser dbmodels.User
-	err := s.db.Where("address = ?", address).First(&existingUser).Error
+	err := s.db.Where("id IN (SELECT user_id FROM dgv_user_address WHERE address = ?)", address).First(&existingUser).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// an address unlinked from another user goes back to the user it is the primary address of
+		err = s.db.Where("address = ?", address).First(&existingUser).Error
+		if err == nil {
+			if err := s.db.Create(&dbmodels.UserAddress{
+				ID:      utils.NextIDString(),
+				UserID:  existingUser.ID,
+				Address: address,
+			}).Error; err != nil {
+				return nil, fmt.Errorf("error restoring user address: %w", err)
+			}
+		}
+	}
 
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		// Address does not exist, create new user
@@ -44,12 +62,25 @@ func (s *UserService) Modify(input dbmodels.User) (*dbmodels.User, error) {
 			Email:   input.Email,
 		}
 
-		if err := s.db.Create(user).Error; err != nil {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(user).Error; err != nil {
+				return err
+			}
+			return tx.Create(&dbmodels.UserAddress{
+				ID:      user.ID,
+				UserID:  user.ID,
+				Address: address,
+			}).Error
+		})
+		if err != nil {
 			return nil, fmt.Errorf("error creating user: %w", err)
 		}
 
 		return user, nil
 	}
+	if err != nil {
+		return nil, fmt.Errorf("error finding user: %w", err)
+	}
 
 	if existingUser.Email != nil && input.Email != nil && *existingUser.Email != *input.Email {
 		existingUser.Email = input.Email
@@ -60,6 +91,131 @@ func (s *UserService) Modify(input dbmodels.User) (*dbmodels.User, error) {
 	return &existingUser, nil
 }
 
+// ListAddresses returns the addresses of the user, the primary address first
+func (s *UserService) ListAddresses(userID string) ([]dbmodels.UserAddress, error) {
+	var addresses []dbmodels.UserAddress
+	err := s.db.Raw(`
+		SELECT a.* FROM dgv_user_address a
+		JOIN dgv_user u ON u.id = a.user_id
+		WHERE a.user_id = ?
+		ORDER BY (a.address = u.address) DESC, a.ctime ASC`, userID).Scan(&addresses).Error
+	if err != nil {
+		return nil, fmt.Errorf("error listing user addresses: %w", err)
+	}
+	return addresses, nil
+}
+
+// ListUsersAddresses returns the addresses of each of the users
+func (s *UserService) ListUsersAddresses(userIDs []string) (map[string][]string, error) {
+	addressesByUser := make(map[string][]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return addressesByUser, nil
+	}
+	var addresses []dbmodels.UserAddress
+	if err := s.db.Where("user_id IN ?", userIDs).Find(&addresses).Error; err != nil {
+		return nil, fmt.Errorf("error listing users addresses: %w", err)
+	}
+	for _, address := range addresses {
+		addressesByUser[address.UserID] = append(addressesByUser[address.UserID], address.Address)
+	}
+	return addressesByUser, nil
+}
+
+// LinkAddress links the address to the user. An address which already has its own account can only be
+// linked when that account has no subscriptions and no channels, its liked daos move over and its sessions are revoked
+func (s *UserService) LinkAddress(userID string, address string) error {
+	address = strings.ToLower(address)
+
+	var owner dbmodels.UserAddress
+	err := s.db.Where("address = ?", address).First(&owner).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := s.db.Create(&dbmodels.UserAddress{
+			ID:      utils.NextIDString(),
+			UserID:  userID,
+			Address: address,
+		}).Error; err != nil {
+			return fmt.Errorf("error linking address: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error finding address: %w", err)
+	}
+	if owner.UserID == userID {
+		return nil
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// the rows of the other account are locked, so that nothing is added to it between the check and the move
+		var ownerAddresses []dbmodels.UserAddress
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", owner.UserID).Find(&ownerAddresses).Error; err != nil {
+			return fmt.Errorf("error locking address account: %w", err)
+		}
+		var ownerUser []dbmodels.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", owner.UserID).Find(&ownerUser).Error; err != nil {
+			return fmt.Errorf("error locking address account: %w", err)
+		}
+
+		var usage int64
+		err := tx.Raw(`
+			SELECT
+				(SELECT count(1) FROM dgv_user_subscribed_dao WHERE user_id = ? AND state = ?) +
+				(SELECT count(1) FROM dgv_user_subscribed_proposal WHERE user_id = ? AND state = ?) +
+				(SELECT count(1) FROM dgv_notification_channel WHERE user_id = ?)`,
+			owner.UserID, dbmodels.SubscribeStateActive,
+			owner.UserID, dbmodels.SubscribeStateActive,
+			owner.UserID,
+		).Scan(&usage).Error
+		if err != nil {
+			return fmt.Errorf("error checking address account: %w", err)
+		}
+		if usage > 0 {
+			return fmt.Errorf("address %s has its own account with subscriptions or channels, remove them before linking it", address)
+		}
+
+		// the other account has nothing left to use, all its addresses move over
+		if err := tx.Model(&dbmodels.UserAddress{}).Where("user_id = ?", owner.UserID).Update("user_id", userID).Error; err != nil {
+			return fmt.Errorf("error linking address: %w", err)
+		}
+		// liked daos move over too, a dao liked by both accounts is kept once
+		if err := tx.Model(&dbmodels.UserLikedDao{}).
+			Where("user_id = ? AND dao_code NOT IN (?)", owner.UserID, tx.Model(&dbmodels.UserLikedDao{}).Select("dao_code").Where("user_id = ?", userID)).
+			Update("user_id", userID).Error; err != nil {
+			return fmt.Errorf("error moving liked daos: %w", err)
+		}
+		if err := tx.Where("user_id = ?", owner.UserID).Delete(&dbmodels.UserLikedDao{}).Error; err != nil {
+			return fmt.Errorf("error moving liked daos: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// revoked through the session service, so that the access tokens of the other account stop working on every replica
+	if _, err := s.userSessionService.RevokeAll(owner.UserID); err != nil {
+		return fmt.Errorf("error revoking sessions of the linked account: %w", err)
+	}
+	return nil
+}
+
+// UnlinkAddress removes a linked address, the primary address of the user can not be unlinked
+func (s *UserService) UnlinkAddress(user *dbmodels.User, address string) error {
+	address = strings.ToLower(address)
+	if address == strings.ToLower(user.Address) {
+		return fmt.Errorf("the primary address can not be unlinked")
+	}
+
+	result := s.db.Where("user_id = ? AND address = ?", user.ID, address).Delete(&dbmodels.UserAddress{})
+	if result.Error != nil {
+		return fmt.Errorf("error unlinking address: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("address %s is not linked to this account", address)
+	}
+	return nil
+}
+
 func (s *UserService) Inspect(seed string) (*dbmodels.User, error) {
 	var user dbmodels.User
 	err := s.db.Where("address = ?", seed).Or("id = ?", seed).First(&user).Error
@@ -79,64 +235,45 @@ func (s *UserService) GetENSName(address string) (*string, error) {
 		return user.EnsName, nil
 	}
 
-	rpcURLs := []string{
-		"https://ethereum-rpc.publicnode.com",
-		// "https://eth-mainnet.public.blastapi.io",
-		// "https://ethereum.therpc.io",
-		// "https://eth.api.onfinality.io/public",
-		// "https://eth.nodeconnect.org",
-	}
-
-	envRPCs := os.Getenv("RPC_URL_1")
-	if envRPCs != "" {
-		userRPCs := strings.Split(envRPCs, ",")
-		rpcURLs = append(userRPCs, rpcURLs...)
-	}
-
+	// ens lives on mainnet, the pool fails over between RPC_URL_1 and the public mainnet rpcs
 	ethAddr := common.HexToAddress(address)
-	var lastErr error
-
-	for _, rpcURL := range rpcURLs {
-		if rpcURL == "" {
-			continue
-		}
-		slog.Debug("Trying to resolve ENS name via RPC", "rpcURL", rpcURL)
-
-		client, err := ethclient.DialContext(context.Background(), rpcURL)
-		if err != nil {
-			lastErr = fmt.Errorf("failed to connect to %s: %w", rpcURL, err)
-			slog.Warn("Failed to connect to Ethereum endpoint", "rpcURL", rpcURL, "err", err)
-			continue
-		}
-		defer client.Close()
-
-		ensName, err := ens.ReverseResolve(client, ethAddr)
+	var ensName string
+	resolved := true
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err = internal.GetRPCPool(1, nil).Call(ctx, func(ctx context.Context, client *ethclient.Client) error {
+		name, err := ens.ReverseResolve(client, ethAddr)
 		if err != nil {
 			if err.Error() == "no resolution" {
-				slog.Info("Address has no reverse resolution", "address", address, "rpcURL", rpcURL)
-				return nil, nil
+				resolved = false
+				return nil
 			}
-			lastErr = fmt.Errorf("failed to resolve via %s: %w", rpcURL, err)
-			slog.Warn("Failed to resolve ENS name", "rpcURL", rpcURL, "err", err)
-			continue
+			return err
 		}
+		ensName = name
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to resolve ENS name", "address", address, "err", err)
+		return nil, err
+	}
+	if !resolved {
+		slog.Info("Address has no reverse resolution", "address", address)
+		return nil, nil
+	}
 
-		slog.Info("Successfully resolved ENS name", "address", address, "ensName", ensName, "rpcURL", rpcURL)
+	slog.Info("Successfully resolved ENS name", "address", address, "ensName", ensName)
 
-		if user == nil {
-			user = &dbmodels.User{
-				Address: address,
-			}
-		}
-		user.EnsName = &ensName
-
-		if _, err := s.Modify(*user); err != nil {
-			slog.Error("Failed to save resolved ENS name to database", "address", address, "err", err)
+	if user == nil {
+		user = &dbmodels.User{
+			Address: address,
 		}
+	}
+	user.EnsName = &ensName
 
-		return &ensName, nil
+	if _, err := s.Modify(*user); err != nil {
+		slog.Error("Failed to save resolved ENS name to database", "address", address, "err", err)
 	}
 
-	slog.Error("Failed to resolve ENS name after trying all available RPCs", "address", address, "lastError", lastErr)
-	return nil, fmt.Errorf("all RPCs failed: %w", lastErr)
+	return &ensName, nil
 }
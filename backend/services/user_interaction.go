@@ -1,47 +1,52 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
+	"strings"
 	"time"
 
-	"github.com/patrickmn/go-cache"
 	"gorm.io/gorm"
 
 	"github.com/ringecosystem/degov-apps/database"
 	dbmodels "github.com/ringecosystem/degov-apps/database/models"
 	gqlmodels "github.com/ringecosystem/degov-apps/graph/models"
 	"github.com/ringecosystem/degov-apps/internal/config"
+	"github.com/ringecosystem/degov-apps/internal/kvstore"
 	"github.com/ringecosystem/degov-apps/internal/utils"
 	"github.com/ringecosystem/degov-apps/types"
 )
 
+// telegramLinkExpiration is how long a telegram link code stays valid
+const telegramLinkExpiration = 10 * time.Minute
+
 type UserInteractionService struct {
 	db              *gorm.DB
 	daoService      *DaoService
 	templateService *TemplateService
-	otpCache        *cache.Cache
-	rateLimitCache  *cache.Cache
+	store           kvstore.Store
 	notifierService *NotifierService
 	userService     *UserService
 }
 
 func NewUserInteractionService() *UserInteractionService {
-	otpCache := cache.New(3*time.Minute, 5*time.Minute)
-	rateLimitCache := cache.New(1*time.Minute, 2*time.Minute)
-
 	return &UserInteractionService{
 		db:              database.GetDB(),
 		daoService:      NewDaoService(),
 		templateService: NewTemplateService(),
-		otpCache:        otpCache,
-		rateLimitCache:  rateLimitCache,
+		store:           kvstore.GetStore(),
 		notifierService: NewNotifierService(),
 		userService:     NewUserService(),
 	}
 }
 
+func otpKey(userID string) string {
+	return "otp:" + userID
+}
+
 func (s *UserInteractionService) ModifyLikeDao(baseInput types.BasicInput[gqlmodels.ModifyLikeDaoInput]) (bool, error) {
 	user := baseInput.User
 	input := baseInput.Input
@@ -102,32 +107,40 @@ func (s *UserInteractionService) VerifyNotificationChannel(baseInput types.Basic
 	user := baseInput.User
 	input := baseInput.Input
 
-	cachedOTP, found := s.otpCache.Get(user.Id)
-	if !found {
+	switch dbmodels.NotificationChannelType(input.Type) {
+	case dbmodels.NotificationChannelTypeWebhook, dbmodels.NotificationChannelTypeDiscord, dbmodels.NotificationChannelTypeSlack:
+		return s.verifyWebhookChannel(baseInput)
+	case dbmodels.NotificationChannelTypeTelegram:
+		return s.verifyTelegramChannel(baseInput)
+	}
+
+	cachedOTP, err := s.store.Get(otpKey(user.Id))
+	if errors.Is(err, kvstore.ErrNotFound) {
 		return &gqlmodels.VerifyNotificationChannelOutput{
 			Code:    1,
 			Message: utils.StringPtr("OTP code has expired or does not exist"),
 		}, nil
 	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading OTP code: %w", err)
+	}
 
-	cachedOTPStr, ok := cachedOTP.(string)
-	if !ok {
+	if cachedOTP != input.OtpCode {
 		return &gqlmodels.VerifyNotificationChannelOutput{
 			Code:    1,
-			Message: utils.StringPtr("Invalid OTP code format"),
+			Message: utils.StringPtr("Invalid OTP code"),
 		}, nil
 	}
 
-	if cachedOTPStr != input.OtpCode {
+	// consume the code, a concurrent verification with the same code has already taken it
+	if _, err := s.store.Take(otpKey(user.Id)); err != nil {
 		return &gqlmodels.VerifyNotificationChannelOutput{
 			Code:    1,
-			Message: utils.StringPtr("Invalid OTP code"),
+			Message: utils.StringPtr("OTP code has expired or does not exist"),
 		}, nil
 	}
 
-	s.otpCache.Delete(user.Id)
-
-	err := s.db.Delete(&dbmodels.NotificationChannel{}, "user_id = ? AND channel_type = ?", user.Id, input.Type).Error
+	err = s.db.Delete(&dbmodels.NotificationChannel{}, "user_id = ? AND channel_type = ?", user.Id, input.Type).Error
 	if err != nil {
 		slog.Warn("error deleting existing unverified channel", "user_id", user.Id, "channel_type", input.Type, "err", err)
 	}
@@ -150,6 +163,174 @@ func (s *UserInteractionService) VerifyNotificationChannel(baseInput types.Basic
 	}, nil
 }
 
+// verifyWebhookChannel binds a webhook like channel (WEBHOOK, DISCORD, SLACK) without OTP. For WEBHOOK a signing secret
+// is generated and stored in the channel payload, it is returned once so the receiver can verify deliveries.
+// Binding the channel again rotates the secret
+func (s *UserInteractionService) verifyWebhookChannel(baseInput types.BasicInput[gqlmodels.VerifyNotificationChannelInput]) (*gqlmodels.VerifyNotificationChannelOutput, error) {
+	user := baseInput.User
+	input := baseInput.Input
+
+	webhookURL, err := url.Parse(input.Value)
+	if err != nil || (webhookURL.Scheme != "https" && webhookURL.Scheme != "http") || webhookURL.Host == "" {
+		return &gqlmodels.VerifyNotificationChannelOutput{
+			Code:    1,
+			Message: utils.StringPtr("Invalid webhook url, only http(s) urls are supported"),
+		}, nil
+	}
+	if webhookURL.Scheme == "http" && !config.GetAppEnv().IsDevelopment() {
+		return &gqlmodels.VerifyNotificationChannelOutput{
+			Code:    1,
+			Message: utils.StringPtr("Webhook url must use https"),
+		}, nil
+	}
+	if message := validateIncomingWebhookURL(dbmodels.NotificationChannelType(input.Type), webhookURL); message != nil {
+		return &gqlmodels.VerifyNotificationChannelOutput{
+			Code:    1,
+			Message: message,
+		}, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := checkWebhookHost(ctx, webhookURL.Hostname()); err != nil {
+		slog.Info("Rejected webhook url", "user_id", user.Id, "host", webhookURL.Hostname(), "error", err)
+		return &gqlmodels.VerifyNotificationChannelOutput{
+			Code:    1,
+			Message: utils.StringPtr("Webhook url must resolve to a public address"),
+		}, nil
+	}
+
+	var payload, webhookSecret *string
+	if input.Type == gqlmodels.NotificationChannelTypeWebhook {
+		secret, err := utils.NextSecret(32)
+		if err != nil {
+			return nil, err
+		}
+		webhookSecret = &secret
+		payload = utils.StringPtr(utils.ToJSON(types.NotificationChannelPayload{
+			WebhookSecret: webhookSecret,
+		}))
+	}
+
+	if err := s.db.Delete(&dbmodels.NotificationChannel{}, "user_id = ? AND channel_type = ?", user.Id, input.Type).Error; err != nil {
+		slog.Warn("error deleting existing webhook channel", "user_id", user.Id, "channel_type", input.Type, "err", err)
+	}
+
+	notificationChannel := dbmodels.NotificationChannel{
+		ID:           utils.NextIDString(),
+		UserID:       user.Id,
+		UserAddress:  user.Address,
+		Verified:     1,
+		ChannelType:  dbmodels.NotificationChannelType(input.Type),
+		ChannelValue: webhookURL.String(),
+		Payload:      payload,
+		CTime:        time.Now(),
+	}
+	if err := s.db.Create(&notificationChannel).Error; err != nil {
+		return nil, err
+	}
+
+	return &gqlmodels.VerifyNotificationChannelOutput{
+		Code:          0,
+		WebhookSecret: webhookSecret,
+	}, nil
+}
+
+// validateIncomingWebhookURL makes sure discord and slack channels point to their incoming webhook endpoints
+func validateIncomingWebhookURL(channelType dbmodels.NotificationChannelType, webhookURL *url.URL) *string {
+	host := strings.ToLower(webhookURL.Hostname())
+	switch channelType {
+	case dbmodels.NotificationChannelTypeDiscord:
+		if (host != "discord.com" && host != "discordapp.com" && !strings.HasSuffix(host, ".discord.com")) ||
+			!strings.HasPrefix(webhookURL.Path, "/api/webhooks/") {
+			return utils.StringPtr("Invalid discord webhook url, expected https://discord.com/api/webhooks/...")
+		}
+	case dbmodels.NotificationChannelTypeSlack:
+		if host != "hooks.slack.com" || !strings.HasPrefix(webhookURL.Path, "/services/") {
+			return utils.StringPtr("Invalid slack webhook url, expected https://hooks.slack.com/services/...")
+		}
+	}
+	return nil
+}
+
+// verifyTelegramChannel checks whether the bot has received the link code, the chat is bound by BindTelegramChat
+func (s *UserInteractionService) verifyTelegramChannel(baseInput types.BasicInput[gqlmodels.VerifyNotificationChannelInput]) (*gqlmodels.VerifyNotificationChannelOutput, error) {
+	user := baseInput.User
+
+	// a pending code is looked up before the verified chat, the chat linked before stays until the new one is bound
+	var channel dbmodels.NotificationChannel
+	err := s.db.
+		Where("user_id = ? AND channel_type = ?", user.Id, dbmodels.NotificationChannelTypeTelegram).
+		Order("verified asc, ctime desc").
+		First(&channel).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &gqlmodels.VerifyNotificationChannelOutput{
+				Code:    1,
+				Message: utils.StringPtr("Telegram link code has expired or does not exist"),
+			}, nil
+		}
+		return nil, fmt.Errorf("error querying telegram channel: %w", err)
+	}
+
+	if channel.Verified != 1 {
+		if time.Since(channel.CTime) > telegramLinkExpiration {
+			return &gqlmodels.VerifyNotificationChannelOutput{
+				Code:    1,
+				Message: utils.StringPtr("Telegram link code has expired or does not exist"),
+			}, nil
+		}
+		return &gqlmodels.VerifyNotificationChannelOutput{
+			Code:    1,
+			Message: utils.StringPtr("The code has not been received by the bot yet, please send it to the bot"),
+		}, nil
+	}
+
+	return &gqlmodels.VerifyNotificationChannelOutput{
+		Code: 0,
+	}, nil
+}
+
+// BindTelegramChat binds the chat which sent the link code to the user who requested it
+func (s *UserInteractionService) BindTelegramChat(input types.BindTelegramChatInput) (*dbmodels.NotificationChannel, error) {
+	var pending dbmodels.NotificationChannel
+	err := s.db.
+		Where("channel_type = ? AND verified = 0 AND channel_value = ? AND ctime > ?",
+			dbmodels.NotificationChannelTypeTelegram, input.Code, time.Now().Add(-telegramLinkExpiration)).
+		First(&pending).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error querying pending telegram channel: %w", err)
+	}
+
+	payload := utils.ToJSON(types.NotificationChannelPayload{
+		TelegramUsername: input.Username,
+	})
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&dbmodels.NotificationChannel{},
+			"user_id = ? AND channel_type = ? AND id <> ?", pending.UserID, dbmodels.NotificationChannelTypeTelegram, pending.ID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&dbmodels.NotificationChannel{}).
+			Where("id = ?", pending.ID).
+			Updates(map[string]interface{}{
+				"verified":      1,
+				"channel_value": input.ChatID,
+				"payload":       payload,
+			}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error binding telegram chat: %w", err)
+	}
+
+	pending.Verified = 1
+	pending.ChannelValue = input.ChatID
+	pending.Payload = &payload
+	return &pending, nil
+}
+
 func (s *UserInteractionService) ResendOTP(baseInput types.BasicInput[gqlmodels.BaseNotificationChannelInput]) (*gqlmodels.ResendOTPOutput, error) {
 	user := baseInput.User
 	input := baseInput.Input
@@ -165,10 +346,16 @@ func (s *UserInteractionService) resendOTPForChannel(baseInput types.BasicInput[
 	input := baseInput.Input
 
 	if !config.GetAppEnv().IsDevelopment() {
-		rateLimitKey := fmt.Sprintf("otp_rate_limit_%s", user.Id)
+		rateLimitKey := fmt.Sprintf("otp_rate_limit:%s", user.Id)
 
-		if cachedTime, found := s.rateLimitCache.Get(rateLimitKey); found {
-			if lastSentTime, ok := cachedTime.(time.Time); ok {
+		// the marker holds the time the last otp was sent, only one request per minute can set it
+		stored, err := s.store.SetNX(rateLimitKey, time.Now().Format(time.RFC3339Nano), 1*time.Minute)
+		if err != nil {
+			return nil, fmt.Errorf("error checking OTP rate limit: %w", err)
+		}
+		if !stored {
+			cachedTime, err := s.store.Get(rateLimitKey)
+			if lastSentTime, parseErr := time.Parse(time.RFC3339Nano, cachedTime); err == nil && parseErr == nil {
 				elapsed := time.Since(lastSentTime)
 				remaining := 60*time.Second - elapsed
 				if remaining > 0 {
@@ -179,16 +366,13 @@ func (s *UserInteractionService) resendOTPForChannel(baseInput types.BasicInput[
 						Message:   utils.StringPtr(fmt.Sprintf("OTP can only be sent once per minute. Please try again in %d seconds", remainingSeconds)),
 					}, nil
 				}
-			} else {
-				return &gqlmodels.ResendOTPOutput{
-					Code:      1,
-					RateLimit: utils.Int32Ptr(60),
-					Message:   utils.StringPtr("OTP can only be sent once per minute. Please try again later"),
-				}, nil
 			}
+			return &gqlmodels.ResendOTPOutput{
+				Code:      1,
+				RateLimit: utils.Int32Ptr(60),
+				Message:   utils.StringPtr("OTP can only be sent once per minute. Please try again later"),
+			}, nil
 		}
-
-		s.rateLimitCache.Set(rateLimitKey, time.Now(), 1*time.Minute)
 	}
 
 	ensName, err := s.userService.GetENSName(user.Address)
@@ -196,13 +380,15 @@ func (s *UserInteractionService) resendOTPForChannel(baseInput types.BasicInput[
 		slog.Warn("Failed to get ENS name", "address", user.Address, "err", err)
 	}
 
-	switch input.Type {
-	case gqlmodels.NotificationChannelTypeEmail:
+	switch dbmodels.NotificationChannelType(input.Type) {
+	case dbmodels.NotificationChannelTypeEmail:
 		otpCode, err := utils.NextOTPCode()
 		if err != nil {
 			return nil, fmt.Errorf("error generating OTP code: %w", err)
 		}
-		s.otpCache.Set(user.Id, otpCode, 3*time.Minute)
+		if err := s.store.Set(otpKey(user.Id), otpCode, 3*time.Minute); err != nil {
+			return nil, fmt.Errorf("error storing OTP code: %w", err)
+		}
 
 		templateOutput, err := s.templateService.GenerateTemplateOTP(types.GenerateTemplateOTPInput{
 			DegovSiteConfig: config.GetDegovSiteConfig(),
@@ -214,12 +400,16 @@ func (s *UserInteractionService) resendOTPForChannel(baseInput types.BasicInput[
 		if err != nil {
 			return nil, fmt.Errorf("error generating email content: %w", err)
 		}
-		if err := s.notifierService.Notify(types.NotifyInput{
+		if _, err := s.notifierService.Notify(types.NotifyInput{
 			Type:     dbmodels.NotificationChannelTypeEmail,
 			To:       input.Value,
 			Template: templateOutput,
 		}); err != nil {
 			slog.Warn("Failed to notify", "err", err)
+			return &gqlmodels.ResendOTPOutput{
+				Code:    1,
+				Message: utils.StringPtr("Failed to send OTP code, please try again later"),
+			}, nil
 		}
 
 		return &gqlmodels.ResendOTPOutput{
@@ -227,7 +417,48 @@ func (s *UserInteractionService) resendOTPForChannel(baseInput types.BasicInput[
 			Expiration: utils.Int32Ptr(3 * 60),
 		}, nil
 
-	case gqlmodels.NotificationChannelTypeWebhook:
+	case dbmodels.NotificationChannelTypeTelegram:
+		if config.GetString("TELEGRAM_BOT_TOKEN") == "" {
+			return &gqlmodels.ResendOTPOutput{
+				Code:    1,
+				Message: utils.StringPtr("telegram notification is not enabled"),
+			}, nil
+		}
+
+		linkCode, err := utils.NextSecret(8)
+		if err != nil {
+			return nil, fmt.Errorf("error generating telegram link code: %w", err)
+		}
+
+		// the pending channel holds the link code until the bot receives it from the user
+		if err := s.db.Delete(&dbmodels.NotificationChannel{}, "user_id = ? AND channel_type = ? AND verified = 0", user.Id, dbmodels.NotificationChannelTypeTelegram).Error; err != nil {
+			slog.Warn("error deleting existing pending telegram channel", "user_id", user.Id, "err", err)
+		}
+		pending := dbmodels.NotificationChannel{
+			ID:           utils.NextIDString(),
+			UserID:       user.Id,
+			UserAddress:  user.Address,
+			Verified:     0,
+			ChannelType:  dbmodels.NotificationChannelTypeTelegram,
+			ChannelValue: linkCode,
+			CTime:        time.Now(),
+		}
+		if err := s.db.Create(&pending).Error; err != nil {
+			return nil, fmt.Errorf("error creating pending telegram channel: %w", err)
+		}
+
+		output := &gqlmodels.ResendOTPOutput{
+			Code:       0,
+			Expiration: utils.Int32Ptr(int32(telegramLinkExpiration.Seconds())),
+			OtpCode:    &linkCode,
+			Message:    utils.StringPtr("send the code to the bot to link your telegram"),
+		}
+		if botUsername := config.GetString("TELEGRAM_BOT_USERNAME"); botUsername != "" {
+			output.Link = utils.StringPtr(fmt.Sprintf("https://t.me/%s?start=%s", botUsername, linkCode))
+		}
+		return output, nil
+
+	case dbmodels.NotificationChannelTypeWebhook, dbmodels.NotificationChannelTypeDiscord, dbmodels.NotificationChannelTypeSlack:
 		return &gqlmodels.ResendOTPOutput{
 			Code:    0,
 			Message: utils.StringPtr("this method do not need send OTP to verify"),
@@ -242,6 +473,15 @@ func (s *UserInteractionService) resendOTPForChannel(baseInput types.BasicInput[
 
 }
 
+// DeleteChannel deletes a channel of the user, deliveries already made to it are kept
+func (s *UserInteractionService) DeleteChannel(baseInput types.BasicInput[gqlmodels.DeleteNotificationChannelInput]) (bool, error) {
+	result := s.db.Where("id = ? AND user_id = ?", baseInput.Input.ChannelID, baseInput.User.Id).Delete(&dbmodels.NotificationChannel{})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to delete notification channel: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
 func (s UserInteractionService) ListChannel(
 	baseInput types.BasicInput[types.ListChannelInput],
 ) ([]dbmodels.NotificationChannel, error) {
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ringecosystem/degov-apps/database"
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	gqlmodels "github.com/ringecosystem/degov-apps/graph/models"
+	"github.com/ringecosystem/degov-apps/internal/config"
+	"github.com/ringecosystem/degov-apps/internal/utils"
+	"github.com/ringecosystem/degov-apps/types"
+	"gorm.io/gorm"
+)
+
+type UserRoleService struct {
+	db *gorm.DB
+}
+
+func NewUserRoleService() *UserRoleService {
+	return &UserRoleService{
+		db: database.GetDB(),
+	}
+}
+
+// configAdminAddresses returns the lowercase addresses of ADMIN_ADDRESSES (comma separated)
+func configAdminAddresses() []string {
+	var addresses []string
+	for _, address := range strings.Split(config.GetString("ADMIN_ADDRESSES"), ",") {
+		address = strings.ToLower(strings.TrimSpace(address))
+		if address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// RoleClaims returns the roles of the address which are put into the jwt.
+// Addresses in ADMIN_ADDRESSES are always admin, other roles come from dgv_user_role
+func (s *UserRoleService) RoleClaims(address string) ([]types.UserRoleClaim, error) {
+	address = strings.ToLower(address)
+
+	var claims []types.UserRoleClaim
+	isAdmin := false
+	for _, adminAddress := range configAdminAddresses() {
+		if adminAddress == address {
+			claims = append(claims, types.UserRoleClaim{Role: dbmodels.UserRoleTypeAdmin})
+			isAdmin = true
+			break
+		}
+	}
+
+	var roles []dbmodels.UserRole
+	if err := s.db.Where("address = ?", address).Order("ctime asc").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to query user roles: %w", err)
+	}
+	for _, role := range roles {
+		if role.Role == dbmodels.UserRoleTypeAdmin {
+			if isAdmin {
+				continue
+			}
+			isAdmin = true
+		}
+		claims = append(claims, types.UserRoleClaim{
+			Role:    role.Role,
+			DaoCode: role.DaoCode,
+		})
+	}
+	return claims, nil
+}
+
+// ListRoles lists the persisted roles, a dao admin can only list the roles of the dao it operates
+func (s *UserRoleService) ListRoles(baseInput types.BasicInput[*gqlmodels.ListUserRolesInput]) ([]dbmodels.UserRole, error) {
+	var daoCode *string
+	if baseInput.Input != nil && baseInput.Input.DaoCode != nil && *baseInput.Input.DaoCode != "" {
+		daoCode = baseInput.Input.DaoCode
+	}
+	if !baseInput.User.IsAdmin() && (daoCode == nil || !baseInput.User.IsDaoAdmin(*daoCode)) {
+		return nil, fmt.Errorf("permission denied")
+	}
+
+	query := s.db.Model(&dbmodels.UserRole{})
+	if daoCode != nil {
+		query = query.Where("dao_code = ?", *daoCode)
+	}
+	var roles []dbmodels.UserRole
+	if err := query.Order("ctime desc").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to list user roles: %w", err)
+	}
+	return roles, nil
+}
+
+// GrantRole grants the role to the address. ADMIN can only be granted by admins,
+// DAO_ADMIN can be granted by admins and by the admins of the same dao
+func (s *UserRoleService) GrantRole(baseInput types.BasicInput[gqlmodels.GrantUserRoleInput]) (*dbmodels.UserRole, error) {
+	input := baseInput.Input
+	role := dbmodels.UserRoleType(input.Role)
+	daoCode, err := s.checkRoleOperator(baseInput.User, role, input.DaoCode)
+	if err != nil {
+		return nil, err
+	}
+
+	address := strings.ToLower(strings.TrimSpace(input.Address))
+	if address == "" {
+		return nil, fmt.Errorf("address is required")
+	}
+
+	existing, err := s.findRole(address, role, daoCode)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	userRole := &dbmodels.UserRole{
+		ID:        utils.NextIDString(),
+		Address:   address,
+		Role:      role,
+		DaoCode:   daoCode,
+		GrantedBy: &baseInput.User.Address,
+		CTime:     time.Now(),
+	}
+	if err := s.db.Create(userRole).Error; err != nil {
+		return nil, fmt.Errorf("failed to grant user role: %w", err)
+	}
+	return userRole, nil
+}
+
+// RevokeRole revokes the role of the address, it takes effect for the tokens issued afterwards
+func (s *UserRoleService) RevokeRole(baseInput types.BasicInput[gqlmodels.RevokeUserRoleInput]) (bool, error) {
+	input := baseInput.Input
+	role := dbmodels.UserRoleType(input.Role)
+	daoCode, err := s.checkRoleOperator(baseInput.User, role, input.DaoCode)
+	if err != nil {
+		return false, err
+	}
+
+	existing, err := s.findRole(strings.ToLower(strings.TrimSpace(input.Address)), role, daoCode)
+	if err != nil {
+		return false, err
+	}
+	if existing == nil {
+		return false, nil
+	}
+	if err := s.db.Delete(existing).Error; err != nil {
+		return false, fmt.Errorf("failed to revoke user role: %w", err)
+	}
+	return true, nil
+}
+
+// checkRoleOperator validates the role scope and whether the operator is allowed to manage it, the normalized dao code is returned
+func (s *UserRoleService) checkRoleOperator(operator *types.UserSessInfo, role dbmodels.UserRoleType, daoCode *string) (*string, error) {
+	if daoCode != nil && *daoCode == "" {
+		daoCode = nil
+	}
+
+	switch role {
+	case dbmodels.UserRoleTypeAdmin:
+		if daoCode != nil {
+			return nil, fmt.Errorf("the ADMIN role can not be scoped to a dao")
+		}
+		if !operator.IsAdmin() {
+			return nil, fmt.Errorf("admin privileges required")
+		}
+	case dbmodels.UserRoleTypeDaoAdmin:
+		if daoCode == nil {
+			return nil, fmt.Errorf("daoCode is required for the DAO_ADMIN role")
+		}
+		if !operator.IsDaoAdmin(*daoCode) {
+			return nil, fmt.Errorf("dao admin privileges required")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported role: %s", role)
+	}
+	return daoCode, nil
+}
+
+func (s *UserRoleService) findRole(address string, role dbmodels.UserRoleType, daoCode *string) (*dbmodels.UserRole, error) {
+	query := s.db.Where("address = ? AND role = ?", address, role)
+	if daoCode == nil {
+		query = query.Where("dao_code IS NULL")
+	} else {
+		query = query.Where("dao_code = ?", *daoCode)
+	}
+
+	var userRole dbmodels.UserRole
+	err := query.First(&userRole).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user role: %w", err)
+	}
+	return &userRole, nil
+}
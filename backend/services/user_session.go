@@ -0,0 +1,228 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ringecosystem/degov-apps/database"
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	"github.com/ringecosystem/degov-apps/internal/config"
+	"github.com/ringecosystem/degov-apps/internal/kvstore"
+	"github.com/ringecosystem/degov-apps/internal/utils"
+	"github.com/ringecosystem/degov-apps/types"
+	"gorm.io/gorm"
+)
+
+// The active state of sessions is cached in the shared kv store. A revocation overwrites the cached state,
+// so a logout or a revoked refresh token is effective on every replica with the next request
+const (
+	sessionStateCacheTTL = time.Minute
+	sessionStateActive   = "active"
+	sessionStateInactive = "inactive"
+)
+
+func sessionStateKey(sessionID string) string {
+	return "session_state:" + sessionID
+}
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused, the session is revoked")
+)
+
+type UserSessionService struct {
+	db    *gorm.DB
+	store kvstore.Store
+}
+
+func NewUserSessionService() *UserSessionService {
+	return &UserSessionService{
+		db:    database.GetDB(),
+		store: kvstore.GetStore(),
+	}
+}
+
+func refreshTokenTTL() time.Duration {
+	ttl := config.GetDuration("AUTH_REFRESH_TOKEN_TTL")
+	if ttl <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return ttl
+}
+
+// Create starts a session for the user and returns it with its refresh token, the dao site of the login is recorded
+func (s *UserSessionService) Create(user *dbmodels.User, origin types.LoginOrigin) (*dbmodels.UserSession, string, error) {
+	now := time.Now()
+	session := &dbmodels.UserSession{
+		ID:          utils.NextIDString(),
+		UserID:      user.ID,
+		UserAddress: user.Address,
+		DaoCode:     origin.DaoCode,
+		Site:        origin.Site,
+		State:       dbmodels.UserSessionStateActive,
+		TimeExpire:  now.Add(refreshTokenTTL()),
+		CTime:       now,
+	}
+	refreshToken, hash, err := newRefreshToken(session.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	session.RefreshTokenHash = hash
+
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create session: %w", err)
+	}
+	return session, refreshToken, nil
+}
+
+// Rotate exchanges the refresh token for a new one. Presenting a refresh token which was already rotated
+// means it leaked, the whole session is revoked in that case
+func (s *UserSessionService) Rotate(refreshToken string) (*dbmodels.UserSession, string, error) {
+	sessionID, _, ok := strings.Cut(refreshToken, ".")
+	if !ok || sessionID == "" {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	var session dbmodels.UserSession
+	err := s.db.Where("id = ?", sessionID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query session: %w", err)
+	}
+	if session.State != dbmodels.UserSessionStateActive || time.Now().After(session.TimeExpire) {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	if subtle.ConstantTimeCompare([]byte(hashRefreshToken(refreshToken)), []byte(session.RefreshTokenHash)) != 1 {
+		slog.Warn("Refresh token reused, revoking session", "session_id", session.ID, "user_id", session.UserID)
+		if _, err := s.Revoke(session.ID, session.UserID); err != nil {
+			return nil, "", err
+		}
+		return nil, "", ErrRefreshTokenReused
+	}
+
+	newToken, hash, err := newRefreshToken(session.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	// compare and swap on the old hash, so that concurrent refreshes with the same token can not both succeed
+	result := s.db.Model(&dbmodels.UserSession{}).
+		Where("id = ? AND refresh_token_hash = ? AND state = ?", session.ID, session.RefreshTokenHash, dbmodels.UserSessionStateActive).
+		Updates(map[string]interface{}{
+			"refresh_token_hash": hash,
+			"time_expire":        now.Add(refreshTokenTTL()),
+			"utime":              now,
+		})
+	if result.Error != nil {
+		return nil, "", fmt.Errorf("failed to rotate refresh token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	session.RefreshTokenHash = hash
+	return &session, newToken, nil
+}
+
+// Revoke revokes one session of the user
+func (s *UserSessionService) Revoke(sessionID, userID string) (bool, error) {
+	affected, err := s.revoke(s.db.Where("id = ? AND user_id = ?", sessionID, userID))
+	if err == nil {
+		s.markInactive(sessionID)
+	}
+	return affected > 0, err
+}
+
+// RevokeAll revokes every session of the user and returns how many were active
+func (s *UserSessionService) RevokeAll(userID string) (int64, error) {
+	var sessionIDs []string
+	if err := s.db.Model(&dbmodels.UserSession{}).
+		Where("user_id = ? AND state = ?", userID, dbmodels.UserSessionStateActive).
+		Pluck("id", &sessionIDs).Error; err != nil {
+		return 0, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	affected, err := s.revoke(s.db.Where("user_id = ?", userID))
+	if err == nil {
+		for _, sessionID := range sessionIDs {
+			s.markInactive(sessionID)
+		}
+	}
+	return affected, err
+}
+
+// markInactive overwrites the cached state of a revoked session. The marker outlives the access tokens
+// of the session, a failure leaves the revocation to the expiry of the cached state
+func (s *UserSessionService) markInactive(sessionID string) {
+	if err := s.store.Set(sessionStateKey(sessionID), sessionStateInactive, max(accessTokenTTL(), sessionStateCacheTTL)); err != nil {
+		slog.Error("Failed to store revoked session state", "session_id", sessionID, "error", err)
+	}
+}
+
+func (s *UserSessionService) revoke(query *gorm.DB) (int64, error) {
+	now := time.Now()
+	result := query.Model(&dbmodels.UserSession{}).
+		Where("state = ?", dbmodels.UserSessionStateActive).
+		Updates(map[string]interface{}{
+			"state":        dbmodels.UserSessionStateRevoked,
+			"time_revoked": now,
+			"utime":        now,
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// IsActive reports whether the access tokens of the session are still accepted
+func (s *UserSessionService) IsActive(sessionID string) (bool, error) {
+	state, err := s.store.Get(sessionStateKey(sessionID))
+	if err == nil {
+		return state == sessionStateActive, nil
+	}
+	if !errors.Is(err, kvstore.ErrNotFound) {
+		slog.Warn("Failed to read cached session state", "session_id", sessionID, "error", err)
+	}
+
+	var session dbmodels.UserSession
+	err = s.db.Select("id", "state", "time_expire").Where("id = ?", sessionID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to query session: %w", err)
+	}
+
+	active := session.State == dbmodels.UserSessionStateActive && time.Now().Before(session.TimeExpire)
+	state = sessionStateInactive
+	if active {
+		state = sessionStateActive
+	}
+	// SetNX keeps a revocation stored while the session was read
+	if _, err := s.store.SetNX(sessionStateKey(sessionID), state, sessionStateCacheTTL); err != nil {
+		slog.Warn("Failed to cache session state", "session_id", sessionID, "error", err)
+	}
+	return active, nil
+}
+
+// newRefreshToken returns a token in the form <session id>.<random> and its hash
+func newRefreshToken(sessionID string) (string, string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	token := sessionID + "." + hex.EncodeToString(bytes)
+	return token, hashRefreshToken(token), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
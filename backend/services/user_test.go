@@ -0,0 +1,123 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	"github.com/ringecosystem/degov-apps/internal/kvstore"
+	"gorm.io/gorm"
+)
+
+func newLinkAddressTestService(t *testing.T) (*UserService, *gorm.DB) {
+	t.Helper()
+	db := newTestDB(t,
+		&dbmodels.User{},
+		&dbmodels.UserAddress{},
+		&dbmodels.UserSubscribedDao{},
+		&dbmodels.UserSubscribedProposal{},
+		&dbmodels.NotificationChannel{},
+		&dbmodels.UserSession{},
+	)
+	// the unique indexes of the migration, the model tags do not carry them
+	for _, statement := range []string{
+		`CREATE TABLE dgv_user_liked_dao (id varchar(50) PRIMARY KEY, dao_code varchar(255) NOT NULL, user_id varchar(50) NOT NULL, user_address varchar(255) NOT NULL, ctime datetime DEFAULT CURRENT_TIMESTAMP)`,
+		`CREATE UNIQUE INDEX uq_dgv_user_liked_dao_code_uid ON dgv_user_liked_dao (dao_code, user_id)`,
+		`CREATE UNIQUE INDEX uq_dgv_user_liked_dao_code_address ON dgv_user_liked_dao (dao_code, user_address)`,
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows := []interface{}{
+		&dbmodels.User{ID: "user", Address: "0xuser"},
+		&dbmodels.UserAddress{ID: "user-address", UserID: "user", Address: "0xuser"},
+		&dbmodels.User{ID: "other", Address: "0xother"},
+		&dbmodels.UserAddress{ID: "other-address", UserID: "other", Address: "0xother"},
+		&dbmodels.UserAddress{ID: "other-linked-address", UserID: "other", Address: "0xotherlinked"},
+	}
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &UserService{
+		db:                 db,
+		userSessionService: &UserSessionService{db: db, store: kvstore.NewMemoryStore()},
+	}, db
+}
+
+func addressOwners(t *testing.T, db *gorm.DB) string {
+	t.Helper()
+	var addresses []dbmodels.UserAddress
+	if err := db.Order("address").Find(&addresses).Error; err != nil {
+		t.Fatal(err)
+	}
+	owners := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		owners = append(owners, address.Address+"="+address.UserID)
+	}
+	return strings.Join(owners, ",")
+}
+
+func TestLinkAddressMergesUnusedAccount(t *testing.T) {
+	service, db := newLinkAddressTestService(t)
+
+	rows := []interface{}{
+		&dbmodels.UserLikedDao{ID: "like-both-user", DaoCode: "both", UserID: "user", UserAddress: "0xuser"},
+		&dbmodels.UserLikedDao{ID: "like-both-other", DaoCode: "both", UserID: "other", UserAddress: "0xother"},
+		&dbmodels.UserLikedDao{ID: "like-other", DaoCode: "other", UserID: "other", UserAddress: "0xother"},
+		&dbmodels.UserSession{ID: "session", UserID: "other", UserAddress: "0xother", State: dbmodels.UserSessionStateActive, TimeExpire: time.Now().Add(time.Hour)},
+	}
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the state is cached like on a request made with an access token of the session
+	if active, err := service.userSessionService.IsActive("session"); err != nil || !active {
+		t.Fatalf("expected the session to be active, got %v %v", active, err)
+	}
+
+	if err := service.LinkAddress("user", "0xOTHER"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := addressOwners(t, db), "0xother=user,0xotherlinked=user,0xuser=user"; got != want {
+		t.Fatalf("expected address owners %s, got %s", want, got)
+	}
+	var likes []dbmodels.UserLikedDao
+	if err := db.Order("dao_code").Find(&likes).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(likes) != 2 || likes[0].UserID != "user" || likes[0].DaoCode != "both" || likes[1].UserID != "user" || likes[1].DaoCode != "other" {
+		t.Fatalf("expected the liked daos to move over once, got %+v", likes)
+	}
+	if active, err := service.userSessionService.IsActive("session"); err != nil || active {
+		t.Fatalf("expected the session of the linked account to be revoked, got %v %v", active, err)
+	}
+}
+
+func TestLinkAddressRejectsUsedAccount(t *testing.T) {
+	tests := map[string]interface{}{
+		"subscribed dao":      &dbmodels.UserSubscribedDao{ID: "dao", DaoCode: "dao", UserID: "other", UserAddress: "0xother", State: dbmodels.SubscribeStateActive},
+		"subscribed proposal": &dbmodels.UserSubscribedProposal{ID: "proposal", DaoCode: "dao", ProposalID: "0x01", UserID: "other", UserAddress: "0xother", State: dbmodels.SubscribeStateActive},
+		"channel":             &dbmodels.NotificationChannel{ID: "channel", UserID: "other", UserAddress: "0xother", ChannelType: dbmodels.NotificationChannelTypeEmail, ChannelValue: "other@example.com"},
+	}
+	for name, row := range tests {
+		t.Run(name, func(t *testing.T) {
+			service, db := newLinkAddressTestService(t)
+			if err := db.Create(row).Error; err != nil {
+				t.Fatal(err)
+			}
+			if err := service.LinkAddress("user", "0xother"); err == nil {
+				t.Fatal("expected the address of a used account to be rejected")
+			}
+			if got, want := addressOwners(t, db), "0xother=other,0xotherlinked=other,0xuser=user"; got != want {
+				t.Fatalf("expected the addresses to stay, got %s", got)
+			}
+		})
+	}
+}
@@ -56,6 +56,14 @@ func GetTaskDefinitions() []TaskDefinition {
 			},
 			Constructor: func() Task { return NewTrackingVoteEndTask() },
 		},
+		{
+			Config: TaskConfig{
+				Name:     "tracking-timelock",
+				Interval: cfg.GetTaskTimelockTrackingInterval(),
+				Enabled:  cfg.GetTaskTimelockTrackingEnabled(),
+			},
+			Constructor: func() Task { return NewTrackingTimelockTask() },
+		},
 		{
 			Config: TaskConfig{
 				Name:     "notification-event",
@@ -72,6 +80,14 @@ func GetTaskDefinitions() []TaskDefinition {
 			},
 			Constructor: func() Task { return NewNotificationDispatcherTask() },
 		},
+		{
+			Config: TaskConfig{
+				Name:     "telegram-updates",
+				Interval: cfg.GetTaskTelegramUpdatesInterval(),
+				Enabled:  cfg.GetTaskTelegramUpdatesEnabled(),
+			},
+			Constructor: func() Task { return NewTelegramUpdatesTask() },
+		},
 	}
 }
 
@@ -3,6 +3,7 @@ package tasks
 import (
 	"fmt"
 	"log/slog"
+	"strings"
 
 	dbmodels "github.com/ringecosystem/degov-apps/database/models"
 	"github.com/ringecosystem/degov-apps/services"
@@ -74,14 +75,21 @@ func (t *NotificationDispatcherTask) dispatcherNotificationRecord() error {
 			continue
 		}
 
-		if err := t.dispatchNotificationRecordByRecord(&record, channels); err != nil {
+		// every user has the inbox, so subscriptions are useful even without a verified channel
+		channels = append(channels, services.NewInboxChannel(record.UserID, record.UserAddress))
+
+		results, err := t.dispatchNotificationRecordByRecord(&record, channels)
+		if err != nil {
 			slog.Error("Failed to dispatch notification record", "record_id", record.ID, "error", err)
 
 			var message string
 			if record.Message != nil {
-				message = fmt.Sprintf("%s\n\n-------\n[%d] Failed to build notification record: %s", *record.Message, timesRetry, err.Error())
+				message = fmt.Sprintf("%s\n\n-------\n[%d] Failed to dispatch notification record: %s", *record.Message, timesRetry, err.Error())
 			} else {
-				message = fmt.Sprintf("[%d] Failed to build notification record: %s", timesRetry, err.Error())
+				message = fmt.Sprintf("[%d] Failed to dispatch notification record: %s", timesRetry, err.Error())
+			}
+			if summary := t.summarizeResults(results); summary != "" {
+				message = message + "\n" + summary
 			}
 
 			if err := t.notificationService.UpdateRecordRetryTimes(types.UpdateRecordRetryTimes{
@@ -103,9 +111,17 @@ func (t *NotificationDispatcherTask) dispatcherNotificationRecord() error {
 			continue
 		}
 
+		var message *string
+		if summary := t.summarizeResults(results); summary != "" {
+			if record.Message != nil {
+				summary = fmt.Sprintf("%s\n\n-------\n%s", *record.Message, summary)
+			}
+			message = &summary
+		}
 		if err := t.notificationService.UpdateRecordState(types.UpdateRecordStateInput{
-			ID:    record.ID,
-			State: dbmodels.NotificationRecordStateSentOk,
+			ID:      record.ID,
+			State:   dbmodels.NotificationRecordStateSentOk,
+			Message: message,
 		}); err != nil {
 			slog.Error("Failed to update record state to send_ok", "record_id", record.ID, "error", err)
 			continue
@@ -114,28 +130,90 @@ func (t *NotificationDispatcherTask) dispatcherNotificationRecord() error {
 	return nil
 }
 
-func (t *NotificationDispatcherTask) dispatchNotificationRecordByRecord(record *dbmodels.NotificationRecord, channels []dbmodels.NotificationChannel) error {
+// dispatchNotificationRecordByRecord delivers the record to every channel which has not received it yet and returns
+// the result of each attempted delivery, the returned error is not nil when at least one delivery failed
+func (t *NotificationDispatcherTask) dispatchNotificationRecordByRecord(record *dbmodels.NotificationRecord, channels []dbmodels.NotificationChannel) ([]types.NotifyResult, error) {
+	deliveries, err := t.notificationService.EnsureDeliveries(record, channels)
+	if err != nil {
+		return nil, err
+	}
+	if t.notificationService.DeriveRecordState(deliveries) == dbmodels.NotificationRecordStateSentOk {
+		return nil, nil
+	}
+
 	templateOutput, err := t.templateService.GenerateTemplateByNotificationRecord(record)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	slog.Debug("Dispatch notification record", "record_id", record.ID, "template", templateOutput)
 
+	channelByID := make(map[string]dbmodels.NotificationChannel, len(channels))
 	for _, channel := range channels {
-		if err := t.notifierService.Notify(types.NotifyInput{
-			Type:     channel.ChannelType,
-			To:       channel.ChannelValue,
-			Template: templateOutput,
-		}); err != nil {
-			// todo: The best practice is to record the failure of a channel to avoid repeated pushes, but there will not be multiple channels for the time being
+		channelByID[channel.ID] = channel
+	}
+
+	results := make([]types.NotifyResult, 0, len(deliveries))
+	var failedChannels []string
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		if delivery.State == dbmodels.NotificationDeliveryStateSentOk {
+			continue
+		}
+		channel := channelByID[delivery.ChannelID]
+
+		output, err := t.notifierService.Notify(types.NotifyInput{
+			Type:           channel.ChannelType,
+			To:             channel.ChannelValue,
+			Template:       templateOutput.For(channel.ChannelType),
+			Record:         record,
+			ChannelPayload: channel.Payload,
+		})
+		update := types.UpdateDeliveryInput{
+			ID:           delivery.ID,
+			State:        dbmodels.NotificationDeliveryStateSentOk,
+			TimesAttempt: delivery.TimesAttempt + 1,
+		}
+		if err != nil {
 			slog.Warn(
 				"Failed to notify",
+				"record_id", record.ID,
+				"delivery_id", delivery.ID,
 				"channel_type", channel.ChannelType,
-				"channel_to", channel.ChannelValue,
 				"error", err,
 			)
-			return err
+			lastError := err.Error()
+			update.State = dbmodels.NotificationDeliveryStateSentFail
+			update.LastError = &lastError
+			failedChannels = append(failedChannels, string(channel.ChannelType))
+		} else if output != nil {
+			update.ProviderMessageID = output.ProviderMessageID
+		}
+		if err := t.notificationService.UpdateDelivery(update); err != nil {
+			slog.Error("Failed to update delivery", "delivery_id", delivery.ID, "error", err)
+		}
+		delivery.State = update.State
+
+		results = append(results, types.NotifyResult{
+			ChannelType: channel.ChannelType,
+			To:          channel.ChannelValue,
+			Error:       err,
+		})
+	}
+	if t.notificationService.DeriveRecordState(deliveries) != dbmodels.NotificationRecordStateSentOk {
+		return results, fmt.Errorf("failed to notify channels: %s", strings.Join(failedChannels, ", "))
+	}
+	return results, nil
+}
+
+// summarizeResults formats the delivery result of each channel, one line per channel
+func (t *NotificationDispatcherTask) summarizeResults(results []types.NotifyResult) string {
+	lines := make([]string, 0, len(results))
+	for _, result := range results {
+		if result.Error != nil {
+			lines = append(lines, fmt.Sprintf("[%s] %s: failed, %s", result.ChannelType, result.To, result.Error.Error()))
+		} else {
+			lines = append(lines, fmt.Sprintf("[%s] %s: delivered", result.ChannelType, result.To))
 		}
 	}
-	return nil
+	return strings.Join(lines, "\n")
 }
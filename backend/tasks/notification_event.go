@@ -3,24 +3,33 @@ package tasks
 import (
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	"github.com/ringecosystem/degov-apps/internal"
 	"github.com/ringecosystem/degov-apps/services"
 	"github.com/ringecosystem/degov-apps/types"
 )
 
+// voteEndVotersChunkSize is the number of addresses checked per indexer query of the unvoted strategy
+const voteEndVotersChunkSize = 100
+
 type NotificationEventTask struct {
 	daoService          *services.DaoService
+	daoConfigService    *services.DaoConfigService
 	notificationService *services.NotificationService
 	subscribeService    *services.SubscribeService
+	userService         *services.UserService
 }
 
 func NewNotificationEventTask() *NotificationEventTask {
 	return &NotificationEventTask{
 		daoService:          services.NewDaoService(),
+		daoConfigService:    services.NewDaoConfigService(),
 		notificationService: services.NewNotificationService(),
 		subscribeService:    services.NewSubscribeService(),
+		userService:         services.NewUserService(),
 	}
 }
 
@@ -85,20 +94,28 @@ func (t *NotificationEventTask) buildNotificationRecordByEvent(event *dbmodels.N
 		recordsBuf = make([]dbmodels.NotificationRecord, 0, 256)
 		batchSize  = 200
 	)
+	strategies, strategyTokens := t.allowStrategies(event)
 	for {
 		subscribedUsers, err := t.subscribeService.ListSubscribedUser(types.ListSubscribeUserInput{
-			Feature:    event.Type,
-			Strategies: t.allowStrategies(event.Type),
-			DaoCode:    event.DaoCode,
-			ProposalID: &event.ProposalID,
-			TimeEvent:  &event.TimeEvent,
-			Limit:      limit,
-			Offset:     offset,
+			Feature:        event.Type,
+			Strategies:     strategies,
+			StrategyTokens: strategyTokens,
+			DaoCode:        event.DaoCode,
+			ProposalID:     &event.ProposalID,
+			TimeEvent:      &event.TimeEvent,
+			Limit:          limit,
+			Offset:         offset,
 		})
 		if err != nil {
 			return err
 		}
-		for _, user := range subscribedUsers {
+		notifiedUsers := subscribedUsers
+		if event.Type == dbmodels.SubscribeFeatureVoteEnd {
+			if notifiedUsers, err = t.filterVoteEndUsers(event, subscribedUsers); err != nil {
+				return err
+			}
+		}
+		for _, user := range notifiedUsers {
 			rec := dbmodels.NotificationRecord{
 				Code:        event.ID + "_" + user.UserID,
 				EventID:     event.ID,
@@ -137,17 +154,120 @@ func (t *NotificationEventTask) buildNotificationRecordByEvent(event *dbmodels.N
 	return nil
 }
 
-func (t *NotificationEventTask) allowStrategies(feature dbmodels.SubscribeFeatureName) []string {
-	switch feature {
+// allowStrategies returns the strategies and the strategy tokens of the subscriptions notified by the event.
+// VOTE_END events are matched by their lead time, the default strategy only matches the default lead time
+func (t *NotificationEventTask) allowStrategies(event *dbmodels.NotificationEvent) ([]string, []string) {
+	switch event.Type {
 	case dbmodels.SubscribeFeatureProposalNew:
-		return []string{"true"}
+		return []string{services.SubscribeStrategyDefault}, nil
 	case dbmodels.SubscribeFeatureProposalStateChanged:
-		return []string{"true"}
+		return []string{services.SubscribeStrategyDefault}, nil
 	case dbmodels.SubscribeFeatureVoteEmitted:
-		return []string{"true"}
+		return []string{services.SubscribeStrategyDefault}, nil
+	case dbmodels.SubscribeFeatureProposalExecutable, dbmodels.SubscribeFeatureProposalExpiring:
+		return []string{services.SubscribeStrategyDefault}, nil
 	case dbmodels.SubscribeFeatureVoteEnd:
-		return []string{"true"}
+		leadTime := services.VoteEndEventLeadTime(event)
+		if leadTime == services.FormatLeadTime(services.VoteEndDefaultLeadTime) {
+			return []string{services.SubscribeStrategyDefault}, []string{leadTime}
+		}
+		return nil, []string{leadTime}
 	default:
-		return nil
+		return nil, nil
+	}
+}
+
+// filterVoteEndUsers drops the users whose reminder is covered by a shorter lead time of theirs which was due when
+// the event was emitted, e.g. a proposal first seen one hour before its end, and the users of the unvoted strategy
+// which have voted already with any of their addresses
+func (t *NotificationEventTask) filterVoteEndUsers(event *dbmodels.NotificationEvent, users []types.ListSubscribedUserOutput) ([]types.ListSubscribedUserOutput, error) {
+	leadTime, err := services.ParseLeadTime(services.VoteEndEventLeadTime(event))
+	if err != nil {
+		return nil, err
+	}
+	remaining := event.TimeEvent.Sub(event.CTime)
+
+	filtered := make([]types.ListSubscribedUserOutput, 0, len(users))
+	unvotedUsers := make(map[string]string)
+	for _, user := range users {
+		strategy, err := services.ParseVoteEndStrategy(user.Strategy)
+		if err != nil {
+			slog.Warn("Skip user with invalid vote end strategy", "user_id", user.UserID, "strategy", user.Strategy, "error", err)
+			continue
+		}
+		covered := false
+		for _, userLeadTime := range strategy.LeadTimes {
+			if userLeadTime < leadTime && remaining <= userLeadTime {
+				covered = true
+				break
+			}
+		}
+		if covered {
+			continue
+		}
+		filtered = append(filtered, user)
+		if strategy.Unvoted {
+			unvotedUsers[user.UserID] = user.UserAddress
+		}
+	}
+	if len(unvotedUsers) == 0 {
+		return filtered, nil
+	}
+
+	votedUsers, err := t.votedUsers(event, unvotedUsers)
+	if err != nil {
+		return nil, err
+	}
+	output := filtered[:0]
+	for _, user := range filtered {
+		if !votedUsers[user.UserID] {
+			output = append(output, user)
+		}
+	}
+	return output, nil
+}
+
+// votedUsers returns the users which have voted on the proposal of the event, users are given by id with their primary address
+func (t *NotificationEventTask) votedUsers(event *dbmodels.NotificationEvent, users map[string]string) (map[string]bool, error) {
+	userIDs := make([]string, 0, len(users))
+	for userID := range users {
+		userIDs = append(userIDs, userID)
+	}
+	addressesByUser, err := t.userService.ListUsersAddresses(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	userByAddress := make(map[string]string)
+	for userID, primaryAddress := range users {
+		userByAddress[strings.ToLower(primaryAddress)] = userID
+		for _, address := range addressesByUser[userID] {
+			userByAddress[strings.ToLower(address)] = userID
+		}
+	}
+	addresses := make([]string, 0, len(userByAddress))
+	for address := range userByAddress {
+		addresses = append(addresses, address)
+	}
+
+	daoConfig, err := t.daoConfigService.StandardConfig(event.DaoCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dao config: %w", err)
+	}
+	indexer := internal.NewDegovIndexer(daoConfig.Indexer.Endpoint)
+
+	voted := make(map[string]bool)
+	for start := 0; start < len(addresses); start += voteEndVotersChunkSize {
+		end := min(start+voteEndVotersChunkSize, len(addresses))
+		voters, err := indexer.QueryVotedVoters(event.ProposalID, addresses[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, voter := range voters {
+			if userID, ok := userByAddress[strings.ToLower(voter)]; ok {
+				voted[userID] = true
+			}
+		}
 	}
+	return voted, nil
 }
@@ -0,0 +1,146 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ringecosystem/degov-apps/internal"
+	"github.com/ringecosystem/degov-apps/internal/kvstore"
+	"github.com/ringecosystem/degov-apps/services"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+const (
+	telegramUpdatesLockKey   = "telegram_updates:lock"
+	telegramUpdatesOffsetKey = "telegram_updates:offset"
+	// telegramUpdatesLockTTL bounds how long a replica which stopped while polling blocks the others
+	telegramUpdatesLockTTL = time.Minute
+	// the bot api keeps unconfirmed updates for 24 hours, an older offset is of no use
+	telegramUpdatesOffsetTTL = 7 * 24 * time.Hour
+)
+
+// TelegramUpdatesTask polls the bot updates and binds the chats which sent a link code. The updates are
+// polled by one replica at a time and the offset is kept in the shared store, so an update confirmed by
+// one replica is not handled again by another
+type TelegramUpdatesTask struct {
+	userInteractionService *services.UserInteractionService
+	store                  kvstore.Store
+}
+
+func NewTelegramUpdatesTask() *TelegramUpdatesTask {
+	return &TelegramUpdatesTask{
+		userInteractionService: services.NewUserInteractionService(),
+		store:                  kvstore.GetStore(),
+	}
+}
+
+func (t *TelegramUpdatesTask) Name() string {
+	return "telegram-updates"
+}
+
+func (t *TelegramUpdatesTask) Execute() error {
+	bot := services.NewTelegramBot()
+	if bot == nil {
+		return nil
+	}
+	return t.pollUpdates(bot)
+}
+
+func (t *TelegramUpdatesTask) pollUpdates(bot *internal.TelegramBot) error {
+	locked, err := t.store.SetNX(telegramUpdatesLockKey, strconv.FormatInt(time.Now().Unix(), 10), telegramUpdatesLockTTL)
+	if err != nil {
+		return fmt.Errorf("failed to lock telegram updates: %w", err)
+	}
+	if !locked {
+		return nil
+	}
+	defer func() {
+		if err := t.store.Delete(telegramUpdatesLockKey); err != nil {
+			slog.Warn("Failed to unlock telegram updates", "error", err)
+		}
+	}()
+
+	offset, err := t.loadOffset()
+	if err != nil {
+		return err
+	}
+	updates, err := bot.GetUpdates(offset)
+	if err != nil {
+		return err
+	}
+	for _, update := range updates {
+		if update.UpdateID >= offset {
+			offset = update.UpdateID + 1
+		}
+		if update.Message == nil {
+			continue
+		}
+		if err := t.handleMessage(bot, update.Message); err != nil {
+			slog.Warn("Failed to handle telegram message", "update_id", update.UpdateID, "error", err)
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := t.store.Set(telegramUpdatesOffsetKey, strconv.FormatInt(offset, 10), telegramUpdatesOffsetTTL); err != nil {
+		return fmt.Errorf("failed to store telegram updates offset: %w", err)
+	}
+	return nil
+}
+
+func (t *TelegramUpdatesTask) loadOffset() (int64, error) {
+	value, err := t.store.Get(telegramUpdatesOffsetKey)
+	if errors.Is(err, kvstore.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read telegram updates offset: %w", err)
+	}
+	offset, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		slog.Warn("Invalid telegram updates offset, polling from the first unconfirmed update", "offset", value)
+		return 0, nil
+	}
+	return offset, nil
+}
+
+func (t *TelegramUpdatesTask) handleMessage(bot *internal.TelegramBot, message *internal.TelegramMessage) error {
+	chatID := strconv.FormatInt(message.Chat.ID, 10)
+
+	// the deep link sends "/start <code>", users may also paste the code directly
+	code := strings.TrimSpace(message.Text)
+	if strings.HasPrefix(code, "/start") {
+		code = strings.TrimSpace(strings.TrimPrefix(code, "/start"))
+	}
+	if code == "" {
+		return t.reply(bot, chatID, "Please send the link code shown in DeGov to receive governance notifications here.")
+	}
+
+	var username *string
+	if message.From != nil && message.From.Username != "" {
+		username = &message.From.Username
+	}
+	channel, err := t.userInteractionService.BindTelegramChat(types.BindTelegramChatInput{
+		Code:     code,
+		ChatID:   chatID,
+		Username: username,
+	})
+	if err != nil {
+		return err
+	}
+	if channel == nil {
+		return t.reply(bot, chatID, "The link code is invalid or has expired, please request a new one in DeGov.")
+	}
+
+	slog.Info("Telegram chat linked", "user_id", channel.UserID, "chat_id", chatID)
+	return t.reply(bot, chatID, fmt.Sprintf("Linked to <code>%s</code>, you will receive notifications of your subscribed DAOs here.", channel.UserAddress))
+}
+
+func (t *TelegramUpdatesTask) reply(bot *internal.TelegramBot, chatID string, html string) error {
+	_, err := bot.SendMessage(chatID, html)
+	return err
+}
@@ -0,0 +1,232 @@
+package tasks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/ringecosystem/degov-apps/database"
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	gqlmodels "github.com/ringecosystem/degov-apps/graph/models"
+	"github.com/ringecosystem/degov-apps/internal"
+	"github.com/ringecosystem/degov-apps/services"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+const testTelegramBotToken = "test-token"
+
+// fakeTelegramBot serves getUpdates and sendMessage of the bot api, getUpdates returns the queued updates from the offset
+type fakeTelegramBot struct {
+	t *testing.T
+
+	mu      sync.Mutex
+	updates []internal.TelegramUpdate
+	offsets []int64
+	replies []string
+}
+
+func (b *fakeTelegramBot) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	method, ok := strings.CutPrefix(r.URL.Path, "/bot"+testTelegramBotToken+"/")
+	if !ok {
+		w.Write([]byte(`{"ok":false,"error_code":401,"description":"Unauthorized"}`))
+		return
+	}
+	var params struct {
+		Offset int64  `json:"offset"`
+		Text   string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		b.t.Error(err)
+	}
+
+	var result any
+	switch method {
+	case "getUpdates":
+		b.offsets = append(b.offsets, params.Offset)
+		updates := []internal.TelegramUpdate{}
+		for _, update := range b.updates {
+			if update.UpdateID >= params.Offset {
+				updates = append(updates, update)
+			}
+		}
+		result = updates
+	case "sendMessage":
+		b.replies = append(b.replies, params.Text)
+		result = internal.TelegramMessage{MessageID: int64(len(b.replies))}
+	default:
+		w.Write([]byte(`{"ok":false,"error_code":404,"description":"Not Found"}`))
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+func (b *fakeTelegramBot) send(updateID int64, chatID int64, text string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.updates = append(b.updates, internal.TelegramUpdate{
+		UpdateID: updateID,
+		Message: &internal.TelegramMessage{
+			MessageID: updateID,
+			From:      &internal.TelegramUser{ID: chatID, Username: "member"},
+			Chat:      internal.TelegramChat{ID: chatID, Type: "private"},
+			Text:      text,
+		},
+	})
+}
+
+func (b *fakeTelegramBot) requests() (offsets []int64, replies []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]int64(nil), b.offsets...), append([]string(nil), b.replies...)
+}
+
+// useTestDB replaces the database of the services with a sqlite database holding the tables of the models
+func useTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the models default their times to now(), which sqlite only takes as CURRENT_TIMESTAMP
+	if err := db.Callback().Raw().Before("gorm:raw").Register("test:default_now", func(tx *gorm.DB) {
+		sql := strings.ReplaceAll(tx.Statement.SQL.String(), "DEFAULT now()", "DEFAULT CURRENT_TIMESTAMP")
+		tx.Statement.SQL.Reset()
+		tx.Statement.SQL.WriteString(sql)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestTelegramLinkFlow(t *testing.T) {
+	t.Setenv("KV_STORE", "memory")
+	t.Setenv("APP_ENV", "development")
+	t.Setenv("TELEGRAM_BOT_TOKEN", testTelegramBotToken)
+	t.Setenv("JWT_SECRET", "test-secret")
+	db := useTestDB(t, &dbmodels.User{}, &dbmodels.NotificationChannel{})
+
+	ensName := "member.eth"
+	if err := db.Create(&dbmodels.User{ID: "user", Address: "0xuser", EnsName: &ensName}).Error; err != nil {
+		t.Fatal(err)
+	}
+	user := &types.UserSessInfo{Id: "user", Address: "0xuser"}
+
+	fake := &fakeTelegramBot{t: t}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	bot := internal.NewTelegramBot(server.URL, testTelegramBotToken)
+
+	service := services.NewUserInteractionService()
+	requestCode := func() string {
+		t.Helper()
+		output, err := service.ResendOTP(types.BasicInput[gqlmodels.BaseNotificationChannelInput]{
+			User:  user,
+			Input: gqlmodels.BaseNotificationChannelInput{Type: gqlmodels.NotificationChannelTypeTelegram},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output.Code != 0 || output.OtpCode == nil {
+			t.Fatalf("expected a link code, got %+v", output)
+		}
+		return *output.OtpCode
+	}
+	verify := func() int32 {
+		t.Helper()
+		output, err := service.VerifyNotificationChannel(types.BasicInput[gqlmodels.VerifyNotificationChannelInput]{
+			User:  user,
+			Input: gqlmodels.VerifyNotificationChannelInput{Type: gqlmodels.NotificationChannelTypeTelegram},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return output.Code
+	}
+	linkedChats := func() []string {
+		t.Helper()
+		var chats []string
+		if err := db.Model(&dbmodels.NotificationChannel{}).
+			Where("user_id = ? AND channel_type = ? AND verified = 1", "user", dbmodels.NotificationChannelTypeTelegram).
+			Pluck("channel_value", &chats).Error; err != nil {
+			t.Fatal(err)
+		}
+		return chats
+	}
+
+	code := requestCode()
+	if verify() == 0 {
+		t.Fatal("expected the channel to be unverified before the bot received the code")
+	}
+	fake.send(10, 100, "/start "+code)
+	if err := NewTelegramUpdatesTask().pollUpdates(bot); err != nil {
+		t.Fatal(err)
+	}
+	if verify() != 0 {
+		t.Fatal("expected the channel to be verified once the bot received the code")
+	}
+	if chats := linkedChats(); len(chats) != 1 || chats[0] != "100" {
+		t.Fatalf("expected chat 100 to be linked, got %v", chats)
+	}
+
+	// linking another chat, the chat linked before must not verify the new code
+	code = requestCode()
+	if verify() == 0 {
+		t.Fatal("expected the new code to be pending while the old chat is linked")
+	}
+	fake.send(11, 200, code)
+	// another replica continues from the offset confirmed by the first one
+	if err := NewTelegramUpdatesTask().pollUpdates(bot); err != nil {
+		t.Fatal(err)
+	}
+	if verify() != 0 {
+		t.Fatal("expected the new chat to be verified")
+	}
+	if chats := linkedChats(); len(chats) != 1 || chats[0] != "200" {
+		t.Fatalf("expected chat 200 to replace chat 100, got %v", chats)
+	}
+
+	offsets, replies := fake.requests()
+	if len(offsets) != 2 || offsets[0] != 0 || offsets[1] != 11 {
+		t.Fatalf("expected the second poll to continue at update 11, got offsets %v", offsets)
+	}
+	if len(replies) != 2 || !strings.Contains(replies[0], "Linked") || !strings.Contains(replies[1], "Linked") {
+		t.Fatalf("expected one confirmation per linked chat, got %v", replies)
+	}
+
+	// an unknown code is answered without linking
+	fake.send(12, 300, "not-a-code")
+	if err := NewTelegramUpdatesTask().pollUpdates(bot); err != nil {
+		t.Fatal(err)
+	}
+	if _, replies := fake.requests(); len(replies) != 3 || !strings.Contains(replies[2], "invalid or has expired") {
+		t.Fatalf("expected the unknown code to be rejected, got %v", replies)
+	}
+	if chats := linkedChats(); len(chats) != 1 || chats[0] != "200" {
+		t.Fatalf("expected the linked chat to stay, got %v", chats)
+	}
+}
@@ -2,6 +2,7 @@ package tasks
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"strconv"
@@ -15,20 +16,26 @@ import (
 )
 
 type TrackingProposalTask struct {
-	daoService          *services.DaoService
-	daoConfigService    *services.DaoConfigService
-	proposalService     *services.ProposalService
-	chipService         *services.DaoChipService
-	notificationService *services.NotificationService
+	daoService            *services.DaoService
+	daoConfigService      *services.DaoConfigService
+	proposalService       *services.ProposalService
+	proposalActionService *services.ProposalActionService
+	chipService           *services.DaoChipService
+	notificationService   *services.NotificationService
+	liveEventService      *services.LiveEventService
+	trackingSourceService *services.TrackingSourceService
 }
 
 func NewTrackingProposalTask() *TrackingProposalTask {
 	return &TrackingProposalTask{
-		daoService:          services.NewDaoService(),
-		daoConfigService:    services.NewDaoConfigService(),
-		proposalService:     services.NewProposalService(),
-		chipService:         services.NewDaoChipService(),
-		notificationService: services.NewNotificationService(),
+		daoService:            services.NewDaoService(),
+		daoConfigService:      services.NewDaoConfigService(),
+		proposalService:       services.NewProposalService(),
+		proposalActionService: services.NewProposalActionService(),
+		chipService:           services.NewDaoChipService(),
+		notificationService:   services.NewNotificationService(),
+		liveEventService:      services.NewLiveEventService(),
+		trackingSourceService: services.NewTrackingSourceService(),
 	}
 }
 
@@ -86,7 +93,7 @@ func (t *TrackingProposalTask) trackingProposal() error {
 }
 
 func (t *TrackingProposalTask) storeProposals(dao *gqlmodels.Dao, daoConfig *types.DaoConfig) error {
-	indexer := internal.NewDegovIndexer(daoConfig.Indexer.Endpoint)
+	source := t.trackingSourceService.Source(dao.Code, daoConfig)
 
 	offsetTrackingProposal := int(dao.OffsetTrackingProposal)
 
@@ -98,8 +105,11 @@ func (t *TrackingProposalTask) storeProposals(dao *gqlmodels.Dao, daoConfig *typ
 
 	for {
 
-		// Query proposals after the last tracked block (correct parameter order)
-		proposals, err := indexer.QueryProposalsOffset(lastOffsetTrackingProposal)
+		// Query proposals after the last tracked block (correct parameter order),
+		// the chain log source may scan the governor logs first
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		proposals, err := source.QueryProposalsOffset(ctx, lastOffsetTrackingProposal)
+		cancel()
 
 		if err != nil {
 			return fmt.Errorf("failed to query proposals: %w", err)
@@ -165,6 +175,14 @@ func (t *TrackingProposalTask) storeProposals(dao *gqlmodels.Dao, daoConfig *typ
 					"proposal_id", proposal.ProposalID,
 					"block_number", blockNumber,
 					"proposal_link", proposalLink)
+
+				// the actions are decoded and stored once, lists of proposals return the stored actions
+				if _, err := t.proposalActionService.Actions(context.Background(), dao.Code, proposal.ProposalID); err != nil {
+					slog.Warn("Failed to decode proposal actions",
+						"dao_code", dao.Code,
+						"proposal_id", proposal.ProposalID,
+						"error", err)
+				}
 			} else {
 				slog.Debug("Proposal already exists, skipping",
 					"dao_code", dao.Code,
@@ -219,35 +237,36 @@ func (t *TrackingProposalTask) updateProposalsStates(dao *gqlmodels.Dao, daoConf
 		return nil
 	}
 
-	// Get RPC URL
-	rpcURL := internal.GetRPCURL(daoConfig.Chain.RPCs, daoConfig.Chain.ID)
-	if rpcURL == "" {
+	// Read through the rpc pool of the chain, a failing rpc is rotated out
+	rpcPool := internal.GetRPCPool(daoConfig.Chain.ID, daoConfig.Chain.RPCs)
+	if rpcPool.Size() == 0 {
 		slog.Warn("No RPC URL available for chain", "dao_code", dao.Code, "chain_id", daoConfig.Chain.ID)
 		return nil
 	}
-
-	// Create Governor contract client
-	governorContract, err := internal.NewGovernorContract(rpcURL)
-	if err != nil {
-		slog.Error("Failed to create Governor contract client", "dao_code", dao.Code, "rpc_url", rpcURL, "error", err)
-		return err
-	}
-	defer governorContract.Close()
+	governorContract := internal.NewGovernorContract(rpcPool)
 
 	slog.Info("Updating proposal states",
 		"dao_code", dao.Code,
 		"count", len(proposals),
 		"governor_address", governorAddress,
-		"rpc_url", rpcURL)
+		"rpc_count", rpcPool.Size())
 
-	// Process each proposal individually
+	// Read the states of all proposals in batches
+	proposalIDs := make([]string, 0, len(proposals))
 	for _, proposal := range proposals {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-
-		// Get proposal state from contract
-		newState, err := governorContract.GetProposalState(ctx, governorAddress, proposal.ProposalID)
-		cancel()
+		proposalIDs = append(proposalIDs, proposal.ProposalID)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	stateResults, err := governorContract.GetProposalStates(ctx, governorAddress, proposalIDs)
+	cancel()
+	if err != nil {
+		slog.Error("Failed to read proposal states", "dao_code", dao.Code, "count", len(proposalIDs), "error", err)
+		return err
+	}
 
+	timelock := newTimelockReader(governorContract, governorAddress, daoConfig.Contracts.TimeLock)
+	for i, proposal := range proposals {
+		newState, err := stateResults[i].State, stateResults[i].Err
 		if err != nil {
 			// Update tracking info with error
 			if updateErr := t.proposalService.UpdateProposalTrackingError(proposal.ProposalID, dao.Code, err.Error()); updateErr != nil {
@@ -265,6 +284,16 @@ func (t *TrackingProposalTask) updateProposalsStates(dao *gqlmodels.Dao, daoConf
 			continue
 		}
 
+		// Queued proposals get their timelock eta once, it is fixed until the proposal is executed or expires
+		if newState == dbmodels.ProposalStateQueued && proposal.Eta == nil {
+			if err := t.storeProposalTimelock(timelock, proposal); err != nil {
+				slog.Warn("Failed to read proposal timelock",
+					"dao_code", dao.Code,
+					"proposal_id", proposal.ProposalID,
+					"error", err)
+			}
+		}
+
 		// Check if state has changed
 		if newState != proposal.State {
 			// Update proposal state in database
@@ -280,10 +309,13 @@ func (t *TrackingProposalTask) updateProposalsStates(dao *gqlmodels.Dao, daoConf
 				continue
 			}
 
-			payload := "{\"old_state\": \"" + string(proposal.State) + "\", \"new_state\": \"" + string(newState) + "\"}"
-			if proposal.State == dbmodels.ProposalStateUnknown {
-				payload = "{\"new_state\": \"" + string(newState) + "\"}"
+			statePayload := types.ProposalStateChangedPayload{NewState: string(newState)}
+			if proposal.State != dbmodels.ProposalStateUnknown {
+				oldState := string(proposal.State)
+				statePayload.OldState = &oldState
 			}
+			payloadBytes, _ := json.Marshal(statePayload)
+			payload := string(payloadBytes)
 			if err := t.notificationService.SaveEvent(dbmodels.NotificationEvent{
 				ChainID:    proposal.ChainId,
 				DaoCode:    proposal.DaoCode,
@@ -300,6 +332,18 @@ func (t *TrackingProposalTask) updateProposalsStates(dao *gqlmodels.Dao, daoConf
 				continue
 			}
 
+			oldState := proposal.State
+			updatedProposal := *proposal
+			updatedProposal.State = newState
+			t.liveEventService.Publish(types.LiveEvent{
+				Type:       types.LiveEventProposalStateChanged,
+				DaoCode:    proposal.DaoCode,
+				ProposalID: proposal.ProposalID,
+				Proposal:   &updatedProposal,
+				OldState:   &oldState,
+				TimeEvent:  time.Now(),
+			})
+
 			slog.Info("Updated proposal state",
 				"dao_code", dao.Code,
 				"proposal_id", proposal.ProposalID,
@@ -311,6 +355,68 @@ func (t *TrackingProposalTask) updateProposalsStates(dao *gqlmodels.Dao, daoConf
 	return nil
 }
 
+// timelockReader reads the eta of queued proposals, the grace period of the timelock is read once per run
+type timelockReader struct {
+	governor         *internal.GovernorContract
+	governorAddress  string
+	timelockAddress  string
+	gracePeriod      time.Duration
+	hasGracePeriod   bool
+	gracePeriodKnown bool
+}
+
+func newTimelockReader(governor *internal.GovernorContract, governorAddress, timelockAddress string) *timelockReader {
+	return &timelockReader{
+		governor:        governor,
+		governorAddress: governorAddress,
+		timelockAddress: timelockAddress,
+	}
+}
+
+// read returns the eta of the proposal and the time it expires, expiresAt is nil for timelocks without grace period
+func (r *timelockReader) read(ctx context.Context, proposalID string) (eta time.Time, expiresAt *time.Time, err error) {
+	eta, err = r.governor.GetProposalEta(ctx, r.governorAddress, proposalID)
+	if err != nil || eta.IsZero() {
+		return eta, nil, err
+	}
+
+	if !r.gracePeriodKnown && r.timelockAddress != "" {
+		r.gracePeriod, r.hasGracePeriod, err = r.governor.GetTimelockGracePeriod(ctx, r.timelockAddress)
+		if err != nil {
+			return time.Time{}, nil, err
+		}
+		r.gracePeriodKnown = true
+	}
+	if r.hasGracePeriod {
+		expires := eta.Add(r.gracePeriod)
+		expiresAt = &expires
+	}
+	return eta, expiresAt, nil
+}
+
+func (t *TrackingProposalTask) storeProposalTimelock(timelock *timelockReader, proposal *dbmodels.ProposalTracking) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	eta, expiresAt, err := timelock.read(ctx, proposal.ProposalID)
+	if err != nil {
+		return err
+	}
+	if eta.IsZero() {
+		// a zero eta means the governor reports no timelock for the proposal
+		return nil
+	}
+	if err := t.proposalService.UpdateProposalTimelock(proposal.ProposalID, proposal.DaoCode, eta, expiresAt); err != nil {
+		return fmt.Errorf("failed to store proposal timelock: %w", err)
+	}
+	slog.Info("Stored proposal timelock",
+		"dao_code", proposal.DaoCode,
+		"proposal_id", proposal.ProposalID,
+		"eta", eta,
+		"expires_at", expiresAt)
+	return nil
+}
+
 func (t *TrackingProposalTask) updateDaoChips() error {
 	// Get proposal state counts for all active DAOs
 	counts, err := t.proposalService.ProposalStateCount()
@@ -0,0 +1,105 @@
+package tasks
+
+import (
+	"log/slog"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	"github.com/ringecosystem/degov-apps/internal/config"
+	"github.com/ringecosystem/degov-apps/services"
+	"github.com/ringecosystem/degov-apps/types"
+)
+
+// TrackingTimelockTask notifies the executors of queued proposals, once when the timelock eta is reached
+// and once when the grace period of the timelock is ending. The eta is stored by the proposal tracking task
+type TrackingTimelockTask struct {
+	daoService          *services.DaoService
+	proposalService     *services.ProposalService
+	notificationService *services.NotificationService
+}
+
+func NewTrackingTimelockTask() *TrackingTimelockTask {
+	return &TrackingTimelockTask{
+		daoService:          services.NewDaoService(),
+		proposalService:     services.NewProposalService(),
+		notificationService: services.NewNotificationService(),
+	}
+}
+
+// Name returns the task name
+func (t *TrackingTimelockTask) Name() string {
+	return "tracking-timelock"
+}
+
+// Execute emits the timelock events of the queued proposals
+func (t *TrackingTimelockTask) Execute() error {
+	return t.trackingTimelock()
+}
+
+func (t *TrackingTimelockTask) trackingTimelock() error {
+	daos, err := t.daoService.ListDaos(types.BasicInput[*types.ListDaosInput]{})
+	if err != nil {
+		slog.Error("Failed to list DAOs", "error", err)
+		return err
+	}
+
+	expiringLeadTime := config.GetDuration("PROPOSAL_EXPIRING_LEAD_TIME")
+	now := time.Now()
+	for _, dao := range daos {
+		proposals, err := t.proposalService.QueuedProposals(dao.Code)
+		if err != nil {
+			slog.Warn("Failed to list queued proposals", "dao_code", dao.Code, "error", err)
+			continue
+		}
+
+		notificationEvents := []dbmodels.NotificationEvent{}
+		for _, proposal := range proposals {
+			// a proposal past its grace period can not be executed anymore
+			if !now.Before(*proposal.Eta) && (proposal.ExpiresAt == nil || now.Before(*proposal.ExpiresAt)) {
+				if event, ok := t.timelockEvent(proposal, dbmodels.SubscribeFeatureProposalExecutable, *proposal.Eta); ok {
+					notificationEvents = append(notificationEvents, event)
+				}
+			}
+			// the proposal expires at the end of the grace period, there is nothing to remind of afterwards
+			if proposal.ExpiresAt != nil && now.Before(*proposal.ExpiresAt) && !now.Before(proposal.ExpiresAt.Add(-expiringLeadTime)) {
+				if event, ok := t.timelockEvent(proposal, dbmodels.SubscribeFeatureProposalExpiring, *proposal.ExpiresAt); ok {
+					notificationEvents = append(notificationEvents, event)
+				}
+			}
+		}
+		if err := t.notificationService.SaveEvents(notificationEvents); err != nil {
+			slog.Warn("Failed to save notification events", "dao_code", dao.Code, "error", err)
+		}
+	}
+	return nil
+}
+
+// timelockEvent builds the event of the proposal unless it was emitted already
+func (t *TrackingTimelockTask) timelockEvent(proposal *dbmodels.ProposalTracking, eventType dbmodels.SubscribeFeatureName, timeEvent time.Time) (dbmodels.NotificationEvent, bool) {
+	existingEvents, err := t.notificationService.ListEventsWithProposal(types.InspectNotificationEventInput{
+		DaoCode:    proposal.DaoCode,
+		ProposalID: proposal.ProposalID,
+		Type:       eventType,
+	})
+	if err != nil {
+		slog.Warn("Failed to list timelock events", "dao_code", proposal.DaoCode, "proposal_id", proposal.ProposalID, "type", eventType, "error", err)
+		return dbmodels.NotificationEvent{}, false
+	}
+	if len(existingEvents) > 0 {
+		return dbmodels.NotificationEvent{}, false
+	}
+
+	slog.Info("Proposal timelock event",
+		"dao_code", proposal.DaoCode,
+		"proposal_id", proposal.ProposalID,
+		"type", eventType,
+		"eta", proposal.Eta,
+		"expires_at", proposal.ExpiresAt)
+	return dbmodels.NotificationEvent{
+		ChainID:    proposal.ChainId,
+		DaoCode:    proposal.DaoCode,
+		Type:       eventType,
+		ProposalID: proposal.ProposalID,
+		TimeEvent:  timeEvent,
+	}, true
+}
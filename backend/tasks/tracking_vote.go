@@ -15,18 +15,22 @@ import (
 )
 
 type TrackingVoteTask struct {
-	daoService          *services.DaoService
-	proposalService     *services.ProposalService
-	daoConfigService    *services.DaoConfigService
-	notificationService *services.NotificationService
+	daoService            *services.DaoService
+	proposalService       *services.ProposalService
+	daoConfigService      *services.DaoConfigService
+	notificationService   *services.NotificationService
+	liveEventService      *services.LiveEventService
+	trackingSourceService *services.TrackingSourceService
 }
 
 func NewTrackingVoteTask() *TrackingVoteTask {
 	return &TrackingVoteTask{
-		daoService:          services.NewDaoService(),
-		proposalService:     services.NewProposalService(),
-		daoConfigService:    services.NewDaoConfigService(),
-		notificationService: services.NewNotificationService(),
+		daoService:            services.NewDaoService(),
+		proposalService:       services.NewProposalService(),
+		daoConfigService:      services.NewDaoConfigService(),
+		notificationService:   services.NewNotificationService(),
+		liveEventService:      services.NewLiveEventService(),
+		trackingSourceService: services.NewTrackingSourceService(),
 	}
 }
 
@@ -41,7 +45,7 @@ func (t *TrackingVoteTask) Execute() error {
 }
 
 type trackingVoteInput struct {
-	indexer   *internal.DegovIndexer
+	source    internal.GovernanceSource
 	daoConfig *types.DaoConfig
 	dao       *gqlmodels.Dao
 	proposal  *dbmodels.ProposalTracking
@@ -74,10 +78,10 @@ func (t *TrackingVoteTask) trackingVote() error {
 			slog.Error("Failed to track vote, reasoning failed to fetch proposals", "error", err)
 			return err
 		}
-		indexer := internal.NewDegovIndexer(daoConfig.Indexer.Endpoint)
+		source := t.trackingSourceService.Source(dao.Code, daoConfig)
 		for _, proposal := range proposals {
 			if err := t.trackingVoteByProposal(trackingVoteInput{
-				indexer:   indexer,
+				source:    source,
 				daoConfig: daoConfig,
 				dao:       dao,
 				proposal:  proposal,
@@ -109,7 +113,7 @@ func (t *TrackingVoteTask) trackingVoteByProposal(input trackingVoteInput) error
 
 func (t *TrackingVoteTask) fetchAllAndProcessVotes(input trackingVoteInput) ([]processedVote, error) {
 	var (
-		indexer        = input.indexer
+		source         = input.source
 		proposal       = input.proposal
 		lastOffsetVote = proposal.OffsetTrackingVote
 		processedVotes = make([]processedVote, 0)
@@ -117,7 +121,7 @@ func (t *TrackingVoteTask) fetchAllAndProcessVotes(input trackingVoteInput) ([]p
 
 	for {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		votes, err := indexer.QueryVotesOffset(ctx, lastOffsetVote, proposal.ProposalID)
+		votes, err := source.QueryVotesOffset(ctx, lastOffsetVote, proposal.ProposalID)
 		cancel()
 
 		if err != nil {
@@ -157,7 +161,30 @@ func (t *TrackingVoteTask) generateAndStoreNotificationEvents(proposal *dbmodels
 		}
 		notificationEvents = append(notificationEvents, ne)
 	}
-	return t.notificationService.SaveEvents(notificationEvents)
+	if err := t.notificationService.SaveEvents(notificationEvents); err != nil {
+		return err
+	}
+
+	for _, vote := range processedVotes {
+		t.liveEventService.Publish(types.LiveEvent{
+			Type:       types.LiveEventVoteCast,
+			DaoCode:    proposal.DaoCode,
+			ProposalID: proposal.ProposalID,
+			Proposal:   proposal,
+			Vote: &types.LiveVote{
+				ID:              vote.Vote.ID,
+				Voter:           vote.Vote.Voter,
+				Support:         vote.Vote.Support,
+				Weight:          vote.Vote.Weight,
+				Reason:          vote.Vote.Reason,
+				TransactionHash: vote.Vote.TransactionHash,
+				BlockNumber:     vote.Vote.BlockNumber,
+				BlockTimestamp:  vote.Timestamp,
+			},
+			TimeEvent: vote.Timestamp,
+		})
+	}
+	return nil
 }
 
 type processedVote struct {
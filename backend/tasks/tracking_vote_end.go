@@ -1,7 +1,9 @@
 package tasks
 
 import (
+	"encoding/json"
 	"log/slog"
+	"time"
 
 	dbmodels "github.com/ringecosystem/degov-apps/database/models"
 	"github.com/ringecosystem/degov-apps/internal"
@@ -14,6 +16,7 @@ type TrackingVoteEndTask struct {
 	daoService          *services.DaoService
 	daoConfigService    *services.DaoConfigService
 	notificationService *services.NotificationService
+	subscribeService    *services.SubscribeService
 }
 
 func NewTrackingVoteEndTask() *TrackingVoteEndTask {
@@ -21,6 +24,7 @@ func NewTrackingVoteEndTask() *TrackingVoteEndTask {
 		daoService:          services.NewDaoService(),
 		daoConfigService:    services.NewDaoConfigService(),
 		notificationService: services.NewNotificationService(),
+		subscribeService:    services.NewSubscribeService(),
 	}
 }
 
@@ -50,7 +54,14 @@ func (t *TrackingVoteEndTask) trackingVoteEnd() error {
 
 		indexer := internal.NewDegovIndexer(daoConfig.Indexer.Endpoint)
 
-		proposals, err := indexer.QueryExpiringProposals()
+		// one event is emitted per lead time of the vote end subscriptions of the dao
+		leadTimes, err := t.subscribeService.VoteEndLeadTimes(dao.Code)
+		if err != nil {
+			slog.Warn("Failed to list vote end lead times", "dao_code", dao.Code, "error", err)
+			continue
+		}
+
+		proposals, err := indexer.QueryExpiringProposals(leadTimes[0])
 
 		if err != nil {
 			// return nil, fmt.Errorf("failed to query votes: %w", err)
@@ -60,35 +71,53 @@ func (t *TrackingVoteEndTask) trackingVoteEnd() error {
 
 		notificationEvents := []dbmodels.NotificationEvent{}
 		for _, proposal := range proposals {
-			slog.Info(
-				"Proposal is expiring soon",
-				"dao_code", dao.Code,
-				"proposal_id", proposal.ProposalID,
-				"vote_end_time", proposal.VoteEndTimestamp,
-			)
-			existingEvent, _ := t.notificationService.InspectEventWithProposal(types.InspectNotificationEventInput{
-				DaoCode:    dao.Code,
-				ProposalID: proposal.ProposalID,
-				Type:       dbmodels.SubscribeFeatureVoteEnd,
-			})
-			if existingEvent != nil {
-				slog.Info("Existing notification event found", "event", existingEvent)
-				continue
-			}
-
 			voteEndTime, err := utils.ParseTimestamp(proposal.VoteEndTimestamp)
 			if err != nil {
 				slog.Warn("Failed to parse VoteEndTimestamp", "proposal_id", proposal.ProposalID, "timestamp", proposal.VoteEndTimestamp, "error", err)
 				continue
 			}
-			ne := dbmodels.NotificationEvent{
-				ChainID:    int(dao.ChainID),
+			existingEvents, err := t.notificationService.ListEventsWithProposal(types.InspectNotificationEventInput{
 				DaoCode:    dao.Code,
-				Type:       dbmodels.SubscribeFeatureVoteEnd,
 				ProposalID: proposal.ProposalID,
-				TimeEvent:  voteEndTime,
+				Type:       dbmodels.SubscribeFeatureVoteEnd,
+			})
+			if err != nil {
+				slog.Warn("Failed to list vote end events", "dao_code", dao.Code, "proposal_id", proposal.ProposalID, "error", err)
+				continue
+			}
+			emitted := make(map[string]bool, len(existingEvents))
+			for _, event := range existingEvents {
+				emitted[services.VoteEndEventLeadTime(&event)] = true
+			}
+
+			remaining := time.Until(voteEndTime)
+			for _, leadTime := range leadTimes {
+				lead := services.FormatLeadTime(leadTime)
+				if remaining > leadTime || emitted[lead] {
+					continue
+				}
+				slog.Info(
+					"Proposal is expiring soon",
+					"dao_code", dao.Code,
+					"proposal_id", proposal.ProposalID,
+					"vote_end_time", proposal.VoteEndTimestamp,
+					"lead_time", lead,
+				)
+				payload, err := json.Marshal(types.VoteEndEventPayload{LeadTime: lead})
+				if err != nil {
+					slog.Warn("Failed to marshal vote end payload", "proposal_id", proposal.ProposalID, "error", err)
+					continue
+				}
+				payloadStr := string(payload)
+				notificationEvents = append(notificationEvents, dbmodels.NotificationEvent{
+					ChainID:    int(dao.ChainID),
+					DaoCode:    dao.Code,
+					Type:       dbmodels.SubscribeFeatureVoteEnd,
+					ProposalID: proposal.ProposalID,
+					Payload:    &payloadStr,
+					TimeEvent:  voteEndTime,
+				})
 			}
-			notificationEvents = append(notificationEvents, ne)
 		}
 		if err := t.notificationService.SaveEvents(notificationEvents); err != nil {
 			slog.Warn("Failed to save notification events", "dao_code", dao.Code, "error", err)
@@ -1,13 +1,60 @@
 package types
 
-import "time"
+import (
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+)
 
 type AuthenticatedUserKeyType struct{}
 
 type UserSessInfo struct {
-	Id      string     `json:"id"`
-	Address string     `json:"address"`
-	Email   *string    `json:"email,omitempty"`
-	CTime   time.Time  `json:"ctime"`
-	UTime   *time.Time `json:"utime"`
+	Id      string          `json:"id"`
+	Address string          `json:"address"`
+	Email   *string         `json:"email,omitempty"`
+	CTime   time.Time       `json:"ctime"`
+	UTime   *time.Time      `json:"utime"`
+	Roles   []UserRoleClaim `json:"roles,omitempty"`
+}
+
+// LoginOrigin is the dao site a login request came from, as inferred by the degov middleware
+type LoginOrigin struct {
+	DaoCode *string
+	// Site is the host of the x-degov-site, origin or referer header
+	Site *string
+}
+
+// UserRoleClaim is a role carried in the jwt, DaoCode is set for DAO_ADMIN roles
+type UserRoleClaim struct {
+	Role    dbmodels.UserRoleType `json:"role"`
+	DaoCode *string               `json:"daoCode,omitempty"`
+}
+
+// IsAdmin reports whether the session has the global admin role
+func (u *UserSessInfo) IsAdmin() bool {
+	if u == nil {
+		return false
+	}
+	for _, role := range u.Roles {
+		if role.Role == dbmodels.UserRoleTypeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDaoAdmin reports whether the session can operate the dao, global admins can operate every dao
+func (u *UserSessInfo) IsDaoAdmin(daoCode string) bool {
+	if u == nil {
+		return false
+	}
+	for _, role := range u.Roles {
+		if role.Role == dbmodels.UserRoleTypeAdmin {
+			return true
+		}
+		if role.Role == dbmodels.UserRoleTypeDaoAdmin && role.DaoCode != nil && *role.DaoCode == daoCode {
+			return true
+		}
+	}
+	return false
 }
@@ -34,8 +34,9 @@ type UpdateEventRetryTimes struct {
 }
 
 type UpdateRecordStateInput struct {
-	ID    string
-	State dbmodels.NotificationRecordState
+	ID      string
+	State   dbmodels.NotificationRecordState
+	Message *string
 }
 
 type UpdateRecordRetryTimes struct {
@@ -44,6 +45,14 @@ type UpdateRecordRetryTimes struct {
 	Message    string
 }
 
+type UpdateDeliveryInput struct {
+	ID                string
+	State             dbmodels.NotificationDeliveryState
+	TimesAttempt      int
+	LastError         *string
+	ProviderMessageID *string
+}
+
 type ListChannelInput struct {
 	Verified *bool
 }
@@ -52,4 +61,146 @@ type NotifyInput struct {
 	Type     dbmodels.NotificationChannelType
 	To       string
 	Template *TemplateOutput
+	// Record is the notification record being delivered, nil for system messages such as OTP
+	Record *dbmodels.NotificationRecord
+	// ChannelPayload is the raw payload of the target channel (e.g. webhook secret)
+	ChannelPayload *string
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	// TLSMode is one of starttls (default), tls (implicit tls, usually port 465) and none
+	TLSMode   string
+	FromUser  string
+	FromEmail string
+}
+
+type NotifyOutput struct {
+	// ProviderMessageID is the id assigned to the message by the provider, nil if the provider does not return one
+	ProviderMessageID *string
+}
+
+type NotifyResult struct {
+	ChannelType dbmodels.NotificationChannelType
+	To          string
+	Error       error
+}
+
+// NotificationChannelPayload is the json stored in dgv_notification_channel.payload
+type NotificationChannelPayload struct {
+	WebhookSecret    *string `json:"webhook_secret,omitempty"`
+	TelegramUsername *string `json:"telegram_username,omitempty"`
+}
+
+type BindTelegramChatInput struct {
+	Code     string
+	ChatID   string
+	Username *string
+}
+
+const WebhookPayloadVersion = "1"
+
+type WebhookPayload struct {
+	Version      string              `json:"version"`
+	ID           string              `json:"id"`
+	Event        WebhookPayloadEvent `json:"event"`
+	Notification WebhookPayloadBody  `json:"notification"`
+	Timestamp    int64               `json:"timestamp"`
+}
+
+type WebhookPayloadEvent struct {
+	ID         string                        `json:"id,omitempty"`
+	Type       dbmodels.SubscribeFeatureName `json:"type,omitempty"`
+	ChainID    int                           `json:"chain_id,omitempty"`
+	DaoCode    string                        `json:"dao_code,omitempty"`
+	ProposalID string                        `json:"proposal_id,omitempty"`
+	VoteID     *string                       `json:"vote_id,omitempty"`
+}
+
+type WebhookPayloadBody struct {
+	Title    string `json:"title"`
+	Markdown string `json:"markdown"`
+}
+
+// DiscordWebhookPayload https://discord.com/developers/docs/resources/webhook#execute-webhook
+type DiscordWebhookPayload struct {
+	Username string         `json:"username,omitempty"`
+	Content  string         `json:"content,omitempty"`
+	Embeds   []DiscordEmbed `json:"embeds,omitempty"`
+}
+
+type DiscordEmbed struct {
+	Title       string              `json:"title,omitempty"`
+	URL         string              `json:"url,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Color       int                 `json:"color,omitempty"`
+	Fields      []DiscordEmbedField `json:"fields,omitempty"`
+	Footer      *DiscordEmbedFooter `json:"footer,omitempty"`
+	Timestamp   string              `json:"timestamp,omitempty"`
+}
+
+type DiscordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type DiscordEmbedFooter struct {
+	Text string `json:"text"`
+}
+
+// SlackWebhookPayload https://api.slack.com/messaging/webhooks, blocks use the Block Kit layout
+type SlackWebhookPayload struct {
+	Text   string       `json:"text"`
+	Blocks []SlackBlock `json:"blocks,omitempty"`
+}
+
+type SlackBlock struct {
+	Type     string         `json:"type"`
+	Text     *SlackText     `json:"text,omitempty"`
+	Fields   []SlackText    `json:"fields,omitempty"`
+	Elements []SlackElement `json:"elements,omitempty"`
+}
+
+type SlackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type SlackElement struct {
+	Type string     `json:"type"`
+	Text *SlackText `json:"text,omitempty"`
+	URL  string     `json:"url,omitempty"`
+}
+
+type ListFeedInput struct {
+	First *int32
+	After *string
+}
+
+// ProposalStateChangedPayload is the payload of the PROPOSAL_STATE_CHANGED notification events
+type ProposalStateChangedPayload struct {
+	OldState *string `json:"old_state,omitempty"`
+	NewState string  `json:"new_state"`
+}
+
+// VoteEndEventPayload is the payload of the VOTE_END notification events, events without payload
+// were emitted before lead times existed and use the default lead time
+type VoteEndEventPayload struct {
+	LeadTime string `json:"lead_time"`
+}
+
+type ListInboxInput struct {
+	First      *int32
+	After      *string
+	UnreadOnly bool
+}
+
+type ListNotificationHistoryInput struct {
+	First *int32
+	After *string
+	State *dbmodels.NotificationRecordState
 }
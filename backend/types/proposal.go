@@ -4,6 +4,7 @@ import (
 	"time"
 
 	dbmodels "github.com/ringecosystem/degov-apps/database/models"
+	gqlmodels "github.com/ringecosystem/degov-apps/graph/models"
 )
 
 type ProposalTrackingInput struct {
@@ -33,3 +34,46 @@ type InspectProposalInput struct {
 	DaoCode    string
 	ProposalID string
 }
+
+type LiveEventType string
+
+const (
+	LiveEventProposalCreated      LiveEventType = "PROPOSAL_CREATED"
+	LiveEventProposalStateChanged LiveEventType = "PROPOSAL_STATE_CHANGED"
+	LiveEventVoteCast             LiveEventType = "VOTE_CAST"
+)
+
+// LiveEvent is published to the graphql subscriptions at the same points where notification events are stored
+type LiveEvent struct {
+	Type       LiveEventType
+	DaoCode    string
+	ProposalID string
+	Proposal   *dbmodels.ProposalTracking
+	OldState   *dbmodels.ProposalState
+	Vote       *LiveVote
+	TimeEvent  time.Time
+}
+
+type LiveVote struct {
+	ID              string
+	Voter           string
+	Support         int
+	Weight          string
+	Reason          string
+	TransactionHash string
+	BlockNumber     string
+	BlockTimestamp  time.Time
+}
+
+// LiveEventFilter selects the events of a subscription, an empty ProposalID matches every proposal of the dao
+type LiveEventFilter struct {
+	Type       LiveEventType
+	DaoCode    string
+	ProposalID string
+}
+
+type ListProposalsInput struct {
+	Filter *gqlmodels.ProposalFilter
+	First  *int32
+	After  *string
+}
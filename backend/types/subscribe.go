@@ -9,8 +9,10 @@ import (
 type ListSubscribeUserInput struct {
 	Feature    dbmodels.SubscribeFeatureName
 	Strategies []string
-	DaoCode    string
-	ProposalID *string
+	// StrategyTokens additionally matches the strategies containing any of the comma separated tokens, e.g. the lead time "24h" of VOTE_END
+	StrategyTokens []string
+	DaoCode        string
+	ProposalID     *string
 	// TimeEvent is the timestamp of the event; only users who subscribed
 	// before or at this time should be returned.
 	TimeEvent *time.Time
@@ -23,6 +25,7 @@ type ListSubscribedUserOutput struct {
 	UserAddress string
 	ChainID     int
 	DaoCode     string
+	Strategy    string
 	CTime       time.Time `gorm:"column:ctime"`
 }
 
@@ -30,3 +33,38 @@ type ListFeaturesInput struct {
 	DaoCode    string
 	ProposalID *string
 }
+
+type UnsubscribeScope string
+
+const (
+	UnsubscribeScopeDao      UnsubscribeScope = "DAO"
+	UnsubscribeScopeProposal UnsubscribeScope = "PROPOSAL"
+	UnsubscribeScopeFeature  UnsubscribeScope = "FEATURE"
+	UnsubscribeScopeAllEmail UnsubscribeScope = "ALL_EMAIL"
+)
+
+// UnsubscribeClaims is the signed content of an unsubscribe token
+type UnsubscribeClaims struct {
+	UserID     string                        `json:"u"`
+	Scope      UnsubscribeScope              `json:"s"`
+	DaoCode    string                        `json:"d,omitempty"`
+	ProposalID string                        `json:"p,omitempty"`
+	Feature    dbmodels.SubscribeFeatureName `json:"f,omitempty"`
+	Expiry     int64                         `json:"e"`
+}
+
+// UnsubscribeLinks are the one-click unsubscribe urls of a notification, exposed to the templates
+type UnsubscribeLinks struct {
+	DaoURL      string
+	ProposalURL string
+	FeatureURL  string
+	AllEmailURL string
+}
+
+// VoteEndStrategy is the parsed strategy of the VOTE_END feature, e.g. "24h,1h,unvoted"
+type VoteEndStrategy struct {
+	// LeadTimes are the durations before the vote end at which a reminder is sent, longest first
+	LeadTimes []time.Duration
+	// Unvoted skips the reminder once any of the addresses of the user has voted on the proposal
+	Unvoted bool
+}
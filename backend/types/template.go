@@ -1,5 +1,7 @@
 package types
 
+import dbmodels "github.com/ringecosystem/degov-apps/database/models"
+
 type GenerateTemplateOTPInput struct {
 	DegovSiteConfig DegovSiteConfig `json:"degov_site_config"`
 	EmailStyle      *EmailStyle      `json:"email_style"`
@@ -13,4 +15,43 @@ type TemplateOutput struct {
 	Title            string `json:"title"`
 	RichTextContent  string `json:"rich_text_content"`
 	PlainTextContent string `json:"plain_text_content"`
+	// Summary is the structured content of a notification record, used by channels with their own layout (discord, slack).
+	// it is nil for system messages such as OTP
+	Summary *TemplateSummary `json:"summary,omitempty"`
+	// ListUnsubscribeURL is the one-click unsubscribe url set as the List-Unsubscribe header of emails, empty for system messages
+	// and for the renderings of the other channels
+	ListUnsubscribeURL string `json:"list_unsubscribe_url,omitempty"`
+}
+
+// NotificationTemplateOutput is a notification record rendered for its channels. Only the email rendering carries the
+// one-click unsubscribe links, the text of the other channels may be read by others, e.g. in a shared chat or by a webhook receiver
+type NotificationTemplateOutput struct {
+	Email   *TemplateOutput
+	Default *TemplateOutput
+}
+
+// For returns the rendering sent through the channel type
+func (o *NotificationTemplateOutput) For(channelType dbmodels.NotificationChannelType) *TemplateOutput {
+	if channelType == dbmodels.NotificationChannelTypeEmail {
+		return o.Email
+	}
+	return o.Default
+}
+
+type TemplateSummary struct {
+	Type           dbmodels.SubscribeFeatureName `json:"type"`
+	Headline       string                        `json:"headline"`
+	DaoName        string                        `json:"dao_name"`
+	ProposalTitle  string                        `json:"proposal_title"`
+	ProposalLink   string                        `json:"proposal_link"`
+	State          dbmodels.ProposalState        `json:"state"`
+	PercentFor     float64                       `json:"percent_for"`
+	PercentAgainst float64                       `json:"percent_against"`
+	PercentAbstain float64                       `json:"percent_abstain"`
+	Links          []TemplateSummaryLink         `json:"links"`
+}
+
+type TemplateSummaryLink struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
 }